@@ -5,7 +5,11 @@
 module github.com/trustbloc/aries-framework-go
 
 require (
+	github.com/btcsuite/btcutil v0.0.0-20180706230648-ab6388e0c60a
+	github.com/google/uuid v1.1.0
+	github.com/gorilla/websocket v1.4.1
 	github.com/pkg/errors v0.8.1
 	github.com/stretchr/testify v1.3.0
 	github.com/trustbloc/did-common-go v0.0.0-20190617150254-6d44f70946da
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
@@ -0,0 +1,77 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package basicmessage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+const (
+	// StoreName name of the store holding the basic message history
+	StoreName = "basicmessage"
+
+	msgKeyPrefix = "msg_"
+)
+
+// MessageRecord is a basic message sent or received on a connection
+type MessageRecord struct {
+	ConnectionID string                    `json:"connectionID,omitempty"`
+	Inbound      bool                      `json:"inbound,omitempty"`
+	Message      *didexchange.BasicMessage `json:"message,omitempty"`
+	Time         time.Time                 `json:"time,omitempty"`
+}
+
+// history persists the basic messages of every connection in the order they were sent or received
+type history struct {
+	store storage.Store
+}
+
+// save saves the message record, it is keyed by connection ID and time so that records are iterated in order
+func (h *history) save(record *MessageRecord) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Message Record Error")
+	}
+
+	key := fmt.Sprintf("%s%020d_%s", connectionKeyPrefix(record.ConnectionID), record.Time.UnixNano(), record.Message.ID)
+	if err := h.store.Put(key, recordBytes); err != nil {
+		return errors.Wrapf(err, "failed to save basic message %s", record.Message.ID)
+	}
+
+	return nil
+}
+
+// list returns the message records of the connection, oldest first
+func (h *history) list(connectionID string) ([]*MessageRecord, error) {
+	var records []*MessageRecord
+	err := h.store.Iterate(connectionKeyPrefix(connectionID), func(k string, v []byte) error {
+		record := &MessageRecord{}
+		if err := json.Unmarshal(v, record); err != nil {
+			return errors.Wrapf(err, "Unmarshal Message Record Error")
+		}
+		// the prefix also matches the connection IDs starting with the given one
+		if record.ConnectionID == connectionID {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func connectionKeyPrefix(connectionID string) string {
+	return msgKeyPrefix + connectionID + "_"
+}
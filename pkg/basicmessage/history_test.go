@@ -0,0 +1,54 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package basicmessage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+func TestHistory(t *testing.T) {
+	store := memstore.NewStore()
+	h := &history{store: store}
+
+	start := time.Now().UTC()
+	for i, record := range []*MessageRecord{
+		{ConnectionID: "conn", Message: &didexchange.BasicMessage{ID: "2", Content: "second"}, Time: start.Add(time.Second)},
+		{ConnectionID: "conn", Message: &didexchange.BasicMessage{ID: "1", Content: "first"}, Time: start, Inbound: true},
+		{ConnectionID: "conn_other", Message: &didexchange.BasicMessage{ID: "3"}, Time: start},
+		{ConnectionID: "other", Message: &didexchange.BasicMessage{ID: "4"}, Time: start},
+	} {
+		require.NoError(t, h.save(record), i)
+	}
+
+	// records are listed oldest first, without the ones of other connections
+	records, err := h.list("conn")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "first", records[0].Message.Content)
+	require.True(t, records[0].Inbound)
+	require.True(t, start.Equal(records[0].Time))
+	require.Equal(t, "second", records[1].Message.Content)
+	require.False(t, records[1].Inbound)
+
+	records, err = h.list("conn_other")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	records, err = h.list("unknown")
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	// invalid records
+	require.NoError(t, store.Put(connectionKeyPrefix("conn")+"invalid", []byte("not json")))
+	_, err = h.list("conn")
+	require.Error(t, err)
+}
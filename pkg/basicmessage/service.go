@@ -0,0 +1,295 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package basicmessage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const (
+	// ServiceName name of the basic message protocol service
+	ServiceName = "basicmessage"
+
+	// MsgTypePrefix type prefix of the basic message protocol messages
+	MsgTypePrefix = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/basicmessage/1.0/"
+
+	basicMessage = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/basicmessage/1.0/message"
+)
+
+// InboundHandler is called with the basic message received on the connection with the given ID
+type InboundHandler func(connectionID string, msg *didexchange.BasicMessage) error
+
+// Service basic message protocol service (RFC 0095), sends human-readable messages on connections and passes
+// the ones received to the inbound handler. Messages are authcrypted, the connection of a received message is
+// the one holding its sender key as one of their keys.
+type Service struct {
+	transport   transport.OutboundTransport
+	store       *connection.ConnectionStore
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	handler     InboundHandler
+	history     *history
+}
+
+// serviceOpts holds the options for the basic message protocol service
+type serviceOpts struct {
+	packer       pack.Packer
+	didProvider  didprovider.Provider
+	historyStore storage.Store
+}
+
+// ServiceOpt is a basic message protocol service option
+type ServiceOpt func(opts *serviceOpts)
+
+// WithPacker the basic messages are packed with the given packer,
+// legacy RFC 0019 and JWE envelopes are supported by default
+func WithPacker(packer pack.Packer) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.packer = packer
+	}
+}
+
+// WithDIDProvider the basic messages are authcrypted with the verkey of our DID of the connection
+// held by the DID provider
+func WithDIDProvider(didProvider didprovider.Provider) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithHistory the basic messages sent and received are saved in the given store, see History
+func WithHistory(store storage.Store) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.historyStore = store
+	}
+}
+
+type header struct {
+	Type string `json:"@type,omitempty"`
+}
+
+// NewService creates a new basic message protocol service sending messages on the connections of the given store,
+// inbound messages are passed to the handler if not nil
+func NewService(transport transport.OutboundTransport, store *connection.ConnectionStore, handler InboundHandler,
+	opts ...ServiceOpt) (*Service, error) {
+	if transport == nil || store == nil {
+		return nil, errors.New("transport and connection store are mandatory")
+	}
+
+	svcOpts := &serviceOpts{}
+	// Apply options
+	for _, opt := range opts {
+		opt(svcOpts)
+	}
+	if svcOpts.packer == nil {
+		svcOpts.packer = packers.NewDefault(svcOpts.didProvider)
+	}
+
+	svc := &Service{
+		transport:   transport,
+		store:       store,
+		packer:      svcOpts.packer,
+		didProvider: svcOpts.didProvider,
+		handler:     handler,
+	}
+	if svcOpts.historyStore != nil {
+		svc.history = &history{store: svcOpts.historyStore}
+	}
+
+	return svc, nil
+}
+
+// Name returns the name of the basic message protocol service
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// MsgTypes returns the message types accepted by the basic message protocol service
+func (s *Service) MsgTypes() []string {
+	return []string{MsgTypePrefix}
+}
+
+// HandleInbound rejects the basic message, its connection can't be found without its envelope
+func (s *Service) HandleInbound(payload []byte) error {
+	_, err := s.HandleInboundEnvelope(&pack.Envelope{Message: payload})
+	return err
+}
+
+// HandleInboundEnvelope saves the basic message in the history of its connection if enabled
+// and passes it to the inbound handler, nothing is sent back
+func (s *Service) HandleInboundEnvelope(envelope *pack.Envelope) ([]byte, error) {
+	received := time.Now().UTC()
+	msgHeader := &header{}
+	if err := json.Unmarshal(envelope.Message, msgHeader); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Basic Message Error")
+	}
+	if msgHeader.Type != basicMessage {
+		return nil, errors.Errorf("unrecognized msgType: %s", msgHeader.Type)
+	}
+
+	msg := &didexchange.BasicMessage{}
+	if err := json.Unmarshal(envelope.Message, msg); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Basic Message Error")
+	}
+
+	record, err := s.connectionOf(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.history != nil {
+		msgRecord := &MessageRecord{ConnectionID: record.ConnectionID, Inbound: true, Message: msg, Time: received}
+		if e := s.history.save(msgRecord); e != nil {
+			return nil, e
+		}
+	}
+
+	if s.handler == nil {
+		return nil, nil
+	}
+
+	return nil, s.handler(record.ConnectionID, msg)
+}
+
+// HandleOutbound packs the basic message for the destination and sends it, it is authcrypted with the verkey
+// of our DID of the connection holding the recipient keys of the destination and saved in its history if enabled
+func (s *Service) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	if destination == nil || destination.ServiceEndpoint == "" {
+		return errors.New("destination service endpoint is mandatory")
+	}
+
+	basicMsg, ok := msg.(*didexchange.BasicMessage)
+	if !ok {
+		return errors.Errorf("unsupported basic message %T", msg)
+	}
+
+	record, err := s.store.GetConnectionRecordByTheirKey(destination.RecipientKeys...)
+	if err != nil {
+		return errors.Wrapf(err, "no connection found to send to %s", destination.ServiceEndpoint)
+	}
+
+	return s.send(context.Background(), basicMsg, record, destination)
+}
+
+// Send sends the basic message to the other party of the connection and saves it in the history if enabled,
+// its ID and sent time are set if empty
+func (s *Service) Send(connectionID string, msg *didexchange.BasicMessage) error {
+	return s.SendContext(context.Background(), connectionID, msg)
+}
+
+// SendContext sends the basic message as Send does, giving up once the context is done
+func (s *Service) SendContext(ctx context.Context, connectionID string, msg *didexchange.BasicMessage) error {
+	if msg == nil {
+		return errors.New("basic message is mandatory")
+	}
+
+	record, err := s.store.GetConnectionRecord(connectionID)
+	if err != nil {
+		return errors.Wrapf(err, "no connection found for connection id %s", connectionID)
+	}
+	if record.ServiceEndpoint == "" || len(record.RecipientKeys) == 0 {
+		return errors.Errorf("connection %s has no service endpoint to send to", connectionID)
+	}
+
+	return s.send(ctx, msg, record, &dispatcher.Destination{
+		ServiceEndpoint: record.ServiceEndpoint,
+		RecipientKeys:   record.RecipientKeys,
+	})
+}
+
+// send packs the basic message for the destination in the envelope format of the connection, authcrypted with
+// the verkey of our DID of the connection, sends it and saves it in the history of the connection if enabled
+func (s *Service) send(ctx context.Context, msg *didexchange.BasicMessage, record *connection.ConnectionRecord,
+	destination *dispatcher.Destination) error {
+	senderKey, err := connection.SenderKey(s.didProvider, record.MyDID)
+	if err != nil {
+		return errors.Wrapf(err, "connection %s has no DID to send from", record.ConnectionID)
+	}
+
+	msgJSON, err := marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := pack.PackWithFormat(s.packer, record.EnvelopeFormat, msgJSON, senderKey,
+		destination.RecipientKeys)
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack basic message")
+	}
+
+	sent := time.Now().UTC()
+	_, err = transport.SendEnvelope(ctx, s.transport, envelope, destination.ServiceEndpoint)
+	if err != nil {
+		return err
+	}
+
+	if s.history == nil {
+		return nil
+	}
+
+	return s.history.save(&MessageRecord{ConnectionID: record.ConnectionID, Message: msg, Time: sent})
+}
+
+// History returns the basic messages sent and received on the connection, oldest first
+func (s *Service) History(connectionID string) ([]*MessageRecord, error) {
+	if s.history == nil {
+		return nil, errors.New("basic message history is not enabled")
+	}
+
+	return s.history.list(connectionID)
+}
+
+// connectionOf returns the connection of the sender of the envelope, the one holding the sender key as one of
+// their keys (see connection.ConnectionRecord.TheirKeys). Basic messages can't be anonymous and are accepted
+// on completed connections only.
+func (s *Service) connectionOf(envelope *pack.Envelope) (*connection.ConnectionRecord, error) {
+	if envelope.SenderKey == "" {
+		return nil, errors.New("basic messages from anonymous senders aren't accepted")
+	}
+
+	record, err := s.store.GetConnectionRecordByTheirKey(envelope.SenderKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no connection found for sender %s", envelope.SenderKey)
+	}
+	if record.State != connection.StateIDCompleted {
+		return nil, errors.Errorf("connection %s of sender %s isn't completed", record.ConnectionID,
+			envelope.SenderKey)
+	}
+
+	return record, nil
+}
+
+// marshal sets the type of the basic message, its ID and sent time if empty, and marshals it
+func marshal(msg *didexchange.BasicMessage) ([]byte, error) {
+	msg.Type = basicMessage
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.SentTime == "" {
+		msg.SentTime = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Basic Message Error")
+	}
+
+	return msgJSON, nil
+}
@@ -0,0 +1,320 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package basicmessage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	mockagent "github.com/trustbloc/aries-framework-go/pkg/mocks/agent"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/mem"
+)
+
+const (
+	aliceConnection = "alice-connection"
+	bobConnection   = "bob-connection"
+)
+
+// inbox records the basic messages received by an agent and the connections they were received on,
+// receiving fails with err if set
+type inbox struct {
+	messages    []*didexchange.BasicMessage
+	connections []string
+	err         error
+}
+
+func (i *inbox) receive(connectionID string, msg *didexchange.BasicMessage) error {
+	i.messages = append(i.messages, msg)
+	i.connections = append(i.connections, connectionID)
+	return i.err
+}
+
+// newService creates the basic message service with history of the agent, delivering to the returned inbox
+func newService(t *testing.T, a *mockagent.Agent, ot transport.OutboundTransport,
+	opts ...ServiceOpt) (*Service, *inbox) {
+	received := &inbox{}
+	opts = append([]ServiceOpt{WithPacker(a.Packer), WithDIDProvider(a.DIDProvider),
+		WithHistory(memstore.NewStore())}, opts...)
+	svc, err := NewService(ot, a.Store, received.receive, opts...)
+	require.NoError(t, err)
+
+	return svc, received
+}
+
+func TestNewService(t *testing.T) {
+	store, err := connection.NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	svc, err := NewService(mock.NewRecordingTransport(), store, nil)
+	require.NoError(t, err)
+	require.Equal(t, ServiceName, svc.Name())
+	require.Equal(t, []string{MsgTypePrefix}, svc.MsgTypes())
+	require.NotNil(t, svc.packer)
+
+	// the history is optional
+	_, err = svc.History(aliceConnection)
+	require.Error(t, err)
+
+	_, err = NewService(nil, store, nil)
+	require.Error(t, err)
+	_, err = NewService(mock.NewRecordingTransport(), nil, nil)
+	require.Error(t, err)
+}
+
+func TestService_Send(t *testing.T) {
+	hub := mem.NewHub()
+	alice := mockagent.New(t)
+	aliceSvc, aliceInbox := newService(t, alice, hub.Outbound())
+	defer alice.Serve(t, hub, "alice", aliceSvc.HandleInboundEnvelope)()
+
+	bob := mockagent.New(t)
+	bobSvc, bobInbox := newService(t, bob, hub.Outbound())
+	defer bob.Serve(t, hub, "bob", bobSvc.HandleInboundEnvelope)()
+
+	// alice invites bob
+	aliceConnID, bobConnID := mockagent.Connect(t, alice, bob, "mem://alice", "mem://bob")
+
+	msg := &didexchange.BasicMessage{
+		Content:      "Your hovercraft is full of eels.",
+		Localization: &didexchange.Localization{Locale: "en"},
+	}
+	require.NoError(t, bobSvc.Send(bobConnID, msg))
+	require.Equal(t, basicMessage, msg.Type)
+	require.NotEmpty(t, msg.ID)
+	require.NotEmpty(t, msg.SentTime)
+
+	// alice finds the connection holding the sender key
+	require.Len(t, aliceInbox.messages, 1)
+	require.Equal(t, *msg, *aliceInbox.messages[0])
+	require.Equal(t, []string{aliceConnID}, aliceInbox.connections)
+
+	aliceHistory, err := aliceSvc.History(aliceConnID)
+	require.NoError(t, err)
+	require.Len(t, aliceHistory, 1)
+	require.True(t, aliceHistory[0].Inbound)
+	require.Equal(t, aliceConnID, aliceHistory[0].ConnectionID)
+	require.Equal(t, msg.ID, aliceHistory[0].Message.ID)
+
+	bobHistory, err := bobSvc.History(bobConnID)
+	require.NoError(t, err)
+	require.Len(t, bobHistory, 1)
+	require.False(t, bobHistory[0].Inbound)
+	require.Equal(t, bobConnID, bobHistory[0].ConnectionID)
+
+	// the inviter sends on its side of the connection, the ID and sent time are kept
+	msg = &didexchange.BasicMessage{ID: "msg-id", Content: "hi", SentTime: "2019-01-15 18:42:01Z"}
+	require.NoError(t, aliceSvc.SendContext(context.Background(), aliceConnID, msg))
+	require.Len(t, bobInbox.messages, 1)
+	require.Equal(t, "msg-id", bobInbox.messages[0].ID)
+	require.Equal(t, "2019-01-15 18:42:01Z", bobInbox.messages[0].SentTime)
+	require.Equal(t, []string{bobConnID}, bobInbox.connections)
+
+	aliceHistory, err = aliceSvc.History(aliceConnID)
+	require.NoError(t, err)
+	require.Len(t, aliceHistory, 2)
+	require.False(t, aliceHistory[1].Inbound)
+	require.Equal(t, "hi", aliceHistory[1].Message.Content)
+
+	// JWE envelopes
+	record, err := bob.Store.GetConnectionRecord(bobConnID)
+	require.NoError(t, err)
+	record.EnvelopeFormat = pack.FormatJWE
+	require.NoError(t, bob.Store.SaveConnectionRecord(record))
+	require.NoError(t, bobSvc.Send(bobConnID, &didexchange.BasicMessage{Content: "jwe"}))
+	require.Len(t, aliceInbox.messages, 2)
+	require.Equal(t, "jwe", aliceInbox.messages[1].Content)
+}
+
+func TestService_SendErrors(t *testing.T) {
+	hub := mem.NewHub()
+	alice := mockagent.New(t)
+	aliceSvc, aliceInbox := newService(t, alice, hub.Outbound())
+	defer alice.Serve(t, hub, "alice", aliceSvc.HandleInboundEnvelope)()
+
+	t.Run("rejected by the other party", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		alice.ConnectTo(t, bobConnection, bob, "mem://bob", "")
+		aliceInbox.err = errors.New("rejected")
+		defer func() { aliceInbox.err = nil }()
+
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		ot := mock.NewRecordingTransport().FailAny(mock.AnyCall, errors.New("unreachable"))
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, ot)
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		require.EqualError(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}), "unreachable")
+
+		// messages which couldn't be sent aren't saved
+		history, err := bobSvc.History(aliceConnection)
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+
+	t.Run("invalid connection", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, hub.Outbound())
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+
+		require.NoError(t, bob.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+			ConnectionID: aliceConnection,
+			MyDID:        bob.DID.DID,
+		}))
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		require.Error(t, bobSvc.Send(aliceConnection, nil))
+	})
+
+	t.Run("no DID to send from", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		record, err := bob.Store.GetConnectionRecord(aliceConnection)
+		require.NoError(t, err)
+
+		record.MyDID = "did:example:unknown"
+		require.NoError(t, bob.Store.SaveConnectionRecord(record))
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+
+		record.MyDID = ""
+		require.NoError(t, bob.Store.SaveConnectionRecord(record))
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+	})
+
+	t.Run("unsupported envelope format", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc, _ := newService(t, bob, hub.Outbound(), WithPacker(legacy.New(nil)))
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", pack.FormatJWE)
+
+		require.Error(t, bobSvc.Send(aliceConnection, &didexchange.BasicMessage{Content: "hi"}))
+	})
+}
+
+func TestService_HandleInbound(t *testing.T) {
+	alice, bob := mockagent.New(t), mockagent.New(t)
+	aliceSvc, aliceInbox := newService(t, alice, mock.NewRecordingTransport())
+	msg := []byte(`{"@type":"` + basicMessage + `","@id":"msg-id","content":"hi"}`)
+	envelope := &pack.Envelope{Message: msg, SenderKey: bob.VerKey(), RecipientKey: alice.VerKey()}
+
+	// unknown sender
+	_, err := aliceSvc.HandleInboundEnvelope(envelope)
+	require.Error(t, err)
+
+	// anonymous sender
+	require.Error(t, aliceSvc.HandleInbound(msg))
+
+	// connections of the DID the message was packed for don't make the sender known
+	require.NoError(t, alice.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+		ConnectionID: "other-connection",
+		MyDID:        alice.DID.DID,
+	}))
+	_, err = aliceSvc.HandleInboundEnvelope(envelope)
+	require.Error(t, err)
+	require.Empty(t, aliceInbox.messages)
+
+	// nor do connections to bob which aren't completed
+	require.NoError(t, alice.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+		ConnectionID:  bobConnection,
+		State:         connection.StateIDAbandoned,
+		MyDID:         alice.DID.DID,
+		RecipientKeys: []string{bob.VerKey()},
+	}))
+	_, err = aliceSvc.HandleInboundEnvelope(envelope)
+	require.Error(t, err)
+	require.Empty(t, aliceInbox.messages)
+
+	// received on the completed connection holding the sender key
+	alice.ConnectTo(t, bobConnection, bob, "mem://bob", "")
+	reply, err := aliceSvc.HandleInboundEnvelope(envelope)
+	require.NoError(t, err)
+	require.Empty(t, reply)
+	require.Equal(t, []string{bobConnection}, aliceInbox.connections)
+
+	// received without history nor handler
+	svc, err := NewService(mock.NewRecordingTransport(), alice.Store, nil)
+	require.NoError(t, err)
+	reply, err = svc.HandleInboundEnvelope(envelope)
+	require.NoError(t, err)
+	require.Empty(t, reply)
+
+	// invalid messages
+	for _, payload := range []string{
+		"not json",
+		`{"@type":"` + MsgTypePrefix + `unknown"}`,
+		`{"@type":"` + basicMessage + `","content":1}`,
+	} {
+		_, err = aliceSvc.HandleInboundEnvelope(&pack.Envelope{Message: []byte(payload), SenderKey: bob.VerKey()})
+		require.Error(t, err)
+	}
+}
+
+func TestService_HandleOutbound(t *testing.T) {
+	ot := mock.NewRecordingTransport()
+	alice := mockagent.New(t)
+	bob := mockagent.New(t)
+	bobSvc, _ := newService(t, bob, ot)
+	destination := &dispatcher.Destination{
+		ServiceEndpoint: "https://alice.example.com",
+		RecipientKeys:   []string{alice.VerKey()},
+	}
+
+	// no connection holding the recipient keys
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.BasicMessage{Content: "hi"}, destination))
+
+	bob.ConnectTo(t, aliceConnection, alice, destination.ServiceEndpoint, "")
+	require.NoError(t, bobSvc.HandleOutbound(&didexchange.BasicMessage{Content: "hi"}, destination))
+
+	sent, err := ot.Last(mock.ToDestination(destination.ServiceEndpoint))
+	require.NoError(t, err)
+	envelope, err := alice.Packer.Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	// authcrypted with the verkey of the DID of bob
+	require.Equal(t, bob.VerKey(), envelope.SenderKey)
+
+	unpacked, err := sent.Unpack(alice.Packer)
+	require.NoError(t, err)
+	require.Equal(t, basicMessage, unpacked.Type())
+
+	msg := &didexchange.BasicMessage{}
+	require.NoError(t, unpacked.Decode(msg))
+	require.Equal(t, "hi", msg.Content)
+	require.NotEmpty(t, msg.ID)
+
+	history, err := bobSvc.History(aliceConnection)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, msg.ID, history[0].Message.ID)
+
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Ping{}, destination))
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.BasicMessage{}, nil))
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.BasicMessage{},
+		&dispatcher.Destination{ServiceEndpoint: destination.ServiceEndpoint}))
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+// inviteOpts holds the options for invitation generation
+type inviteOpts struct {
+	baseURL    string
+	scheme     string
+	queryParam string
+}
+
+// InviteOpt is an invitation generation option
+type InviteOpt func(opts *inviteOpts)
+
+// WithBaseURL the invitation is generated as a URL made of the base URL and the encoded invitation,
+// e.g. https://agent.example.com/invite?c_i=<encoded invitation>
+func WithBaseURL(baseURL string) InviteOpt {
+	return func(opts *inviteOpts) {
+		opts.baseURL = baseURL
+	}
+}
+
+// WithScheme the invitation is generated as a URL with the given scheme, overriding the scheme of the base URL.
+// Without a base URL, a deep link is generated, e.g. didcomm://invite?c_i=<encoded invitation>
+func WithScheme(scheme string) InviteOpt {
+	return func(opts *inviteOpts) {
+		opts.scheme = scheme
+	}
+}
+
+// WithOOBQueryParam the invitation URL carries the encoded invitation in the alternate oob query parameter
+// instead of c_i
+func WithOOBQueryParam() InviteOpt {
+	return func(opts *inviteOpts) {
+		opts.queryParam = oobQueryParam
+	}
+}
+
+// exchangeOpts holds the options for the DID exchange
+type exchangeOpts struct {
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	didResolver *resolver.Resolver
+}
+
+// ExchangeOpt is a DID exchange option
+type ExchangeOpt func(opts *exchangeOpts)
+
+// WithPacker the exchange messages are packed with the given packer instead of the legacy RFC 0019 packer
+func WithPacker(packer pack.Packer) ExchangeOpt {
+	return func(opts *exchangeOpts) {
+		opts.packer = packer
+	}
+}
+
+// WithDIDProvider the exchange messages are authcrypted with the verkey of our DID held by the DID provider,
+// they are anoncrypted without DID provider
+func WithDIDProvider(didProvider didprovider.Provider) ExchangeOpt {
+	return func(opts *exchangeOpts) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithDIDResolver the keys of public DID invitations are resolved with the given resolver to verify the signer
+// of the exchange response, responses to public DID invitations are rejected without resolver
+func WithDIDResolver(didResolver *resolver.Resolver) ExchangeOpt {
+	return func(opts *exchangeOpts) {
+		opts.didResolver = didResolver
+	}
+}
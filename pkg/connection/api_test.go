@@ -0,0 +1,34 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBaseURL(t *testing.T) {
+	opt := WithBaseURL("https://agent.example.com/invite")
+	inviteOpts := &inviteOpts{}
+	opt(inviteOpts)
+	require.Equal(t, "https://agent.example.com/invite", inviteOpts.baseURL)
+}
+
+func TestWithScheme(t *testing.T) {
+	opt := WithScheme("didcomm")
+	inviteOpts := &inviteOpts{}
+	opt(inviteOpts)
+	require.Equal(t, "didcomm", inviteOpts.scheme)
+}
+
+func TestWithOOBQueryParam(t *testing.T) {
+	opt := WithOOBQueryParam()
+	inviteOpts := &inviteOpts{queryParam: invitationQueryParam}
+	opt(inviteOpts)
+	require.Equal(t, oobQueryParam, inviteOpts.queryParam)
+}
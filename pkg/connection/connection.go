@@ -7,69 +7,145 @@ SPDX-License-Identifier: Apache-2.0
 package connection
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"net/url"
+	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/internal/base64url"
 	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
 	"github.com/trustbloc/aries-framework-go/pkg/transport"
 )
 
+// MsgTypePrefix type prefix of the connection protocol messages
+const MsgTypePrefix = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/"
+
 const (
 	connectionInvite   = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/invitation"
 	connectionRequest  = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"
 	connectionResponse = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/response"
+	connectionAck      = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/ack"
+	// connectionProblemReport is received when the other party gives up the exchange
+	connectionProblemReport = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/problem_report"
+
+	// invitationQueryParam query parameter holding the encoded invitation in invitation URLs
+	invitationQueryParam = "c_i"
+	// oobQueryParam alternate out-of-band style query parameter holding the encoded invitation
+	oobQueryParam = "oob"
+	// deepLinkHost host of invitation deep links generated without a base URL
+	deepLinkHost = "invite"
 )
 
 // GenerateInviteWithPublicDID generates the DID exchange invitation string with public DID
-func GenerateInviteWithPublicDID(inviteMessage *didexchange.InviteMessage) (string, error) {
-	if inviteMessage.ID == "" || inviteMessage.DID == "" {
-		return "", errors.New("ID and DID are mandatory")
+// The invitation is returned as an invitation URL if a base URL or scheme option is given
+func GenerateInviteWithPublicDID(inviteMessage *didexchange.InviteMessage, opts ...InviteOpt) (string, error) {
+	if err := validateInviteWithPublicDID(inviteMessage); err != nil {
+		return "", err
 	}
 
-	return encodedExchangeInvitation(inviteMessage)
+	return encodedExchangeInvitation(inviteMessage, opts...)
 }
 
 // GenerateInviteWithKeyAndEndpoint generates the DID exchange invitation string with recipient key and endpoint
-func GenerateInviteWithKeyAndEndpoint(inviteMessage *didexchange.InviteMessage) (string, error) {
-	if inviteMessage.ID == "" || inviteMessage.ServiceEndpoint == "" || len(inviteMessage.RecipientKeys) == 0 {
-		return "", errors.New("ID, Service Endpoint and Recipient Key are mandatory")
+// The invitation is returned as an invitation URL if a base URL or scheme option is given
+func GenerateInviteWithKeyAndEndpoint(inviteMessage *didexchange.InviteMessage, opts ...InviteOpt) (string, error) {
+	if err := validateInviteWithKeyAndEndpoint(inviteMessage); err != nil {
+		return "", err
+	}
+
+	return encodedExchangeInvitation(inviteMessage, opts...)
+}
+
+// ParseInvitation decodes and validates the DID exchange invitation, the invitation is either the base64url
+// encoded invitation string or an invitation URL carrying it in the c_i (or oob) query parameter
+func ParseInvitation(invitation string) (*didexchange.InviteMessage, error) {
+	encodedInvitation, err := extractEncodedInvitation(strings.TrimSpace(invitation))
+	if err != nil {
+		return nil, err
+	}
+
+	invitationJSON, err := base64url.Decode(encodedInvitation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Base64 Decode Invitation Error")
+	}
+
+	inviteMessage := &didexchange.InviteMessage{}
+	if err := json.Unmarshal(invitationJSON, inviteMessage); err != nil {
+		return nil, errors.Wrapf(err, "JSON Unmarshal Error")
+	}
+
+	if inviteMessage.Type != connectionInvite {
+		return nil, errors.Errorf("invalid invitation type %s", inviteMessage.Type)
+	}
+
+	if inviteMessage.DID != "" {
+		err = validateInviteWithPublicDID(inviteMessage)
+	} else {
+		err = validateInviteWithKeyAndEndpoint(inviteMessage)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return encodedExchangeInvitation(inviteMessage)
+	return inviteMessage, nil
+}
+
+// SendExchangeRequest sends exchange request packed for the recipient keys of the destination,
+// authcrypted with the verkey of the request's DID if the DID provider option holds it
+func SendExchangeRequest(exchangeRequest *didexchange.Request, destination *dispatcher.Destination,
+	transport transport.OutboundTransport, opts ...SendOpt) error {
+	return SendExchangeRequestContext(context.Background(), exchangeRequest, destination, transport, opts...)
 }
 
-// SendExchangeRequest sends exchange request
-func SendExchangeRequest(exchangeRequest *didexchange.Request, destination string, transport transport.OutboundTransport) error {
+// SendExchangeRequestContext sends exchange request, giving up once the context is done
+func SendExchangeRequestContext(ctx context.Context, exchangeRequest *didexchange.Request,
+	destination *dispatcher.Destination, ot transport.OutboundTransport, opts ...SendOpt) error {
 	if exchangeRequest == nil {
 		return errors.New("exchangeRequest cannot be nil")
 	}
 	exchangeRequest.Type = connectionRequest
-	exchangeRequestJSON, err := json.Marshal(exchangeRequest)
-	if err != nil {
-		return errors.Wrapf(err, "Marshal Send Exchange Request Error")
-	}
 
-	_, err = transport.Send(string(exchangeRequestJSON), destination)
+	if exchangeRequest.Connection != nil {
+		opts = append([]SendOpt{WithSenderDID(exchangeRequest.Connection.DID)}, opts...)
+	}
+	_, err := SendPackedContext(ctx, exchangeRequest, destination, ot, opts...)
 	return err
 }
 
-// SendExchangeResponse sends exchange response
-func SendExchangeResponse(exchangeResponse *didexchange.Response, destination string, transport transport.OutboundTransport) error {
+// SendExchangeResponse sends exchange response packed for the recipient keys of the destination,
+// authcrypted with the verkey of the DID of its connection~sig if the DID provider option holds it
+func SendExchangeResponse(exchangeResponse *didexchange.Response, destination *dispatcher.Destination,
+	transport transport.OutboundTransport, opts ...SendOpt) error {
+	return SendExchangeResponseContext(context.Background(), exchangeResponse, destination, transport, opts...)
+}
+
+// SendExchangeResponseContext sends exchange response, giving up once the context is done
+func SendExchangeResponseContext(ctx context.Context, exchangeResponse *didexchange.Response,
+	destination *dispatcher.Destination, ot transport.OutboundTransport, opts ...SendOpt) error {
 	if exchangeResponse == nil {
 		return errors.New("exchangeResponse cannot be nil")
 	}
 	exchangeResponse.Type = connectionResponse
-	exchangeResponseJSON, err := json.Marshal(exchangeResponse)
-	if err != nil {
-		return errors.Wrapf(err, "Marshal Send Exchange Response Error")
-	}
 
-	_, err = transport.Send(string(exchangeResponseJSON), destination)
+	if exchangeResponse.ConnectionSignature != nil {
+		if connection, err := unpackSignedConnection(exchangeResponse.ConnectionSignature); err == nil {
+			opts = append([]SendOpt{WithSenderDID(connection.DID)}, opts...)
+		}
+	}
+	_, err := SendPackedContext(ctx, exchangeResponse, destination, ot, opts...)
 	return err
 }
 
-func encodedExchangeInvitation(inviteMessage *didexchange.InviteMessage) (string, error) {
+func encodedExchangeInvitation(inviteMessage *didexchange.InviteMessage, opts ...InviteOpt) (string, error) {
+	inviteOpts := &inviteOpts{queryParam: invitationQueryParam}
+	// Apply options
+	for _, opt := range opts {
+		opt(inviteOpts)
+	}
+
 	inviteMessage.Type = connectionInvite
 
 	invitationJSON, err := json.Marshal(inviteMessage)
@@ -77,5 +153,82 @@ func encodedExchangeInvitation(inviteMessage *didexchange.InviteMessage) (string
 		return "", errors.Wrapf(err, "JSON Marshal Error")
 	}
 
-	return base64.URLEncoding.EncodeToString(invitationJSON), nil
+	encodedInvitation := base64.URLEncoding.EncodeToString(invitationJSON)
+	if inviteOpts.baseURL == "" && inviteOpts.scheme == "" {
+		return encodedInvitation, nil
+	}
+
+	return invitationURL(inviteOpts, encodedInvitation)
+}
+
+// invitationURL builds the invitation URL carrying the encoded invitation
+func invitationURL(inviteOpts *inviteOpts, encodedInvitation string) (string, error) {
+	baseURL := &url.URL{Host: deepLinkHost}
+	if inviteOpts.baseURL != "" {
+		var err error
+		baseURL, err = url.Parse(inviteOpts.baseURL)
+		if err != nil {
+			return "", errors.Wrapf(err, "Parse Invitation Base URL Error")
+		}
+	}
+
+	if inviteOpts.scheme != "" {
+		baseURL.Scheme = inviteOpts.scheme
+	}
+	if baseURL.Scheme == "" {
+		return "", errors.New("invitation base URL must be absolute")
+	}
+
+	// the base64url alphabet is query safe, the encoded invitation is appended as is to keep URLs short
+	query := inviteOpts.queryParam + "=" + encodedInvitation
+	if baseURL.RawQuery != "" {
+		query = baseURL.RawQuery + "&" + query
+	}
+	baseURL.RawQuery = query
+
+	return baseURL.String(), nil
+}
+
+func validateInviteWithPublicDID(inviteMessage *didexchange.InviteMessage) error {
+	if inviteMessage.ID == "" || inviteMessage.DID == "" {
+		return errors.New("ID and DID are mandatory")
+	}
+
+	return nil
+}
+
+func validateInviteWithKeyAndEndpoint(inviteMessage *didexchange.InviteMessage) error {
+	if inviteMessage.ID == "" || inviteMessage.ServiceEndpoint == "" || len(inviteMessage.RecipientKeys) == 0 {
+		return errors.New("ID, Service Endpoint and Recipient Key are mandatory")
+	}
+
+	return nil
+}
+
+// extractEncodedInvitation returns the encoded invitation from an invitation URL, or the invitation as is
+// if it isn't a URL
+func extractEncodedInvitation(invitation string) (string, error) {
+	if invitation == "" {
+		return "", errors.New("invitation is mandatory")
+	}
+
+	if !strings.Contains(invitation, "?") {
+		return invitation, nil
+	}
+
+	invitationURL, err := url.Parse(invitation)
+	if err != nil {
+		return "", errors.Wrapf(err, "Parse Invitation URL Error")
+	}
+
+	encodedInvitation := invitationURL.Query().Get(invitationQueryParam)
+	if encodedInvitation == "" {
+		encodedInvitation = invitationURL.Query().Get(oobQueryParam)
+	}
+	if encodedInvitation == "" {
+		return "", errors.Errorf("invitation URL is missing the %s or %s query parameter",
+			invitationQueryParam, oobQueryParam)
+	}
+
+	return encodedInvitation, nil
 }
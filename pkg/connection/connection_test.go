@@ -7,11 +7,19 @@ SPDX-License-Identifier: Apache-2.0
 package connection
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
 	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
 	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
 )
 
 const destinationURL = "https://localhost:8090"
@@ -87,20 +95,248 @@ func TestSendRequest(t *testing.T) {
 		Label: "Bob",
 	}
 
-	require.NoError(t, SendExchangeRequest(req, destinationURL, oTr))
-	require.Error(t, SendExchangeRequest(nil, destinationURL, oTr))
+	require.NoError(t, SendExchangeRequest(req, destinationOf(t, didbasic.NewProvider()), oTr))
+	require.Error(t, SendExchangeRequest(nil, destinationOf(t, didbasic.NewProvider()), oTr))
+
+	// no recipient keys to pack for
+	require.Error(t, SendExchangeRequest(req, &dispatcher.Destination{ServiceEndpoint: destinationURL}, oTr))
+	require.Error(t, SendExchangeRequest(req, nil, oTr))
+}
+
+func TestSendRequest_Recorded(t *testing.T) {
+	oTr := mock.NewRecordingTransport().Fail(destinationURL, 1, errors.New("connection refused"))
+	didProvider, theirDIDProvider := didbasic.NewProvider(), didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	dest := destinationOf(t, theirDIDProvider)
+	opts := []SendOpt{WithSenderDIDProvider(didProvider)}
+
+	req := &didexchange.Request{
+		ID:         "5678876542345",
+		Label:      "Bob",
+		Connection: &didexchange.Connection{DID: myDID.DID},
+	}
+	require.NoError(t, SendExchangeRequest(req, dest, oTr, opts...))
+
+	// authcrypted with the verkey of the request's DID
+	sent, err := oTr.Last(mock.ToDestination(destinationURL))
+	require.NoError(t, err)
+	envelope, err := legacy.New(theirDIDProvider).Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	unpacked, err := sent.Unpack(legacy.New(theirDIDProvider))
+	require.NoError(t, err)
+	require.Equal(t, connectionRequest, unpacked.Type())
+	recorded, err := unpacked.ExchangeRequest()
+	require.NoError(t, err)
+	require.Equal(t, req.ID, recorded.ID)
+	require.Equal(t, req.Label, recorded.Label)
+
+	require.EqualError(t, SendExchangeRequest(req, dest, oTr, opts...), "connection refused")
+	require.Len(t, oTr.Sent(mock.ToDestination(destinationURL)), 2)
+
+	// our DID isn't held by the DID provider
+	req.Connection.DID = "did:example:unknown"
+	require.Error(t, SendExchangeRequest(req, dest, oTr, opts...))
 }
 
 func TestSendResponse(t *testing.T) {
+	oTr := mock.NewRecordingTransport()
+	didProvider, theirDIDProvider := didbasic.NewProvider(), didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	resp := &didexchange.Response{ID: "12345678900987654321"}
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: myDID.DID}, myDID.VerKey, myDID.Secret))
+
+	dest := destinationOf(t, theirDIDProvider)
+	require.NoError(t, SendExchangeResponse(resp, dest, oTr, WithSenderDIDProvider(didProvider)))
+	require.Error(t, SendExchangeResponse(nil, dest, oTr))
+	require.Error(t, SendExchangeResponse(resp, &dispatcher.Destination{ServiceEndpoint: destinationURL}, oTr))
+
+	// authcrypted with the verkey of the DID of the connection~sig
+	sent, err := oTr.Last()
+	require.NoError(t, err)
+	envelope, err := legacy.New(theirDIDProvider).Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	// anoncrypted without DID provider
+	require.NoError(t, SendExchangeResponse(resp, dest, oTr))
+	sent, err = oTr.Last()
+	require.NoError(t, err)
+	envelope, err = legacy.New(theirDIDProvider).Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Empty(t, envelope.SenderKey)
+}
+
+func TestSendExchange_Context(t *testing.T) {
 	oTr := mock.NewOutboundTransport(successResponse)
 
-	resp := &didexchange.Response{
-		ID: "12345678900987654321",
-		ConnectionSignature: &didexchange.ConnectionSignature{
-			Type: "did:trustbloc:RQkehfoFssiwQRuihskwoPSR;spec/ed25519Sha512_single/1.0/ed25519Sha512_single",
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dest := destinationOf(t, didbasic.NewProvider())
+	err := SendExchangeRequestContext(ctx, &didexchange.Request{ID: "5678876542345"}, dest, oTr)
+	require.Equal(t, context.Canceled, err)
+
+	err = SendExchangeResponseContext(ctx, &didexchange.Response{ID: "12345678900987654321"}, dest, oTr)
+	require.Equal(t, context.Canceled, err)
+}
+
+// destinationOf returns the destination reached at destinationURL with the verkey of a new DID of the given provider
+func destinationOf(t *testing.T, theirDIDProvider *didbasic.Provider) *dispatcher.Destination {
+	theirDID, err := theirDIDProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	return &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+}
+
+func TestParseInvitation(t *testing.T) {
+	keyInvitation := &didexchange.InviteMessage{
+		ID:              "12345678900987654321",
+		Label:           "Alice",
+		RecipientKeys:   []string{"8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K"},
+		ServiceEndpoint: "https://example.com/endpoint",
+	}
+	invite, err := GenerateInviteWithKeyAndEndpoint(keyInvitation)
+	require.NoError(t, err)
+
+	invitation, err := ParseInvitation(invite)
+	require.NoError(t, err)
+	require.Equal(t, keyInvitation, invitation)
+
+	// unpadded and URL forms
+	invitation, err = ParseInvitation(strings.TrimRight(invite, "="))
+	require.NoError(t, err)
+	require.Equal(t, keyInvitation, invitation)
+
+	invitation, err = ParseInvitation("https://agent.example.com/invite?c_i=" + invite)
+	require.NoError(t, err)
+	require.Equal(t, keyInvitation, invitation)
+
+	didInvitation := &didexchange.InviteMessage{
+		ID:    "12345678900987654321",
+		Label: "Alice",
+		DID:   "did:trustbloc:ZadolSRQkehfo",
+	}
+	invite, err = GenerateInviteWithPublicDID(didInvitation)
+	require.NoError(t, err)
+
+	invitation, err = ParseInvitation(invite)
+	require.NoError(t, err)
+	require.Equal(t, didInvitation, invitation)
+}
+
+func TestParseInvitation_Errors(t *testing.T) {
+	encode := func(data string) string {
+		return base64.URLEncoding.EncodeToString([]byte(data))
+	}
+
+	tcs := []struct {
+		name       string
+		invitation string
+		err        string
+	}{
+		{name: "empty invitation", invitation: " ", err: "invitation is mandatory"},
+		{name: "invalid URL", invitation: "https://%zz?c_i=abc", err: "Parse Invitation URL Error"},
+		{name: "missing query parameter", invitation: "https://example.com/invite?x=abc", err: "c_i or oob query parameter"},
+		{name: "invalid base64", invitation: "!!!", err: "Base64 Decode Invitation Error"},
+		{name: "invalid JSON", invitation: encode("{"), err: "JSON Unmarshal Error"},
+		{name: "invalid type", invitation: encode(`{"@type":"invalid"}`), err: "invalid invitation type"},
+		{
+			name:       "missing ID with public DID",
+			invitation: encode(`{"@type":"` + connectionInvite + `","did":"did:example:123"}`),
+			err:        "ID and DID are mandatory",
+		},
+		{
+			name:       "missing recipient keys",
+			invitation: encode(`{"@type":"` + connectionInvite + `","@id":"1","serviceEndpoint":"https://example.com"}`),
+			err:        "ID, Service Endpoint and Recipient Key are mandatory",
 		},
 	}
 
-	require.NoError(t, SendExchangeResponse(resp, destinationURL, oTr))
-	require.Error(t, SendExchangeResponse(nil, destinationURL, oTr))
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			invitation, err := ParseInvitation(tc.invitation)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+			require.Nil(t, invitation)
+		})
+	}
+}
+
+func TestGenerateInvitationURL(t *testing.T) {
+	inviteMessage := &didexchange.InviteMessage{
+		ID:    "12345678900987654321",
+		Label: "Alice",
+		DID:   "did:trustbloc:ZadolSRQkehfo",
+	}
+	encodedInvite, err := GenerateInviteWithPublicDID(inviteMessage)
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name string
+		opts []InviteOpt
+		url  string
+	}{
+		{
+			name: "base URL",
+			opts: []InviteOpt{WithBaseURL("https://agent.example.com/invite")},
+			url:  "https://agent.example.com/invite?c_i=" + encodedInvite,
+		},
+		{
+			name: "base URL with query",
+			opts: []InviteOpt{WithBaseURL("https://agent.example.com/invite?lang=en")},
+			url:  "https://agent.example.com/invite?lang=en&c_i=" + encodedInvite,
+		},
+		{
+			name: "deep link",
+			opts: []InviteOpt{WithScheme("didcomm")},
+			url:  "didcomm://invite?c_i=" + encodedInvite,
+		},
+		{
+			name: "base URL with scheme",
+			opts: []InviteOpt{WithBaseURL("https://agent.example.com/invite"), WithScheme("didcomm")},
+			url:  "didcomm://agent.example.com/invite?c_i=" + encodedInvite,
+		},
+		{
+			name: "oob query parameter",
+			opts: []InviteOpt{WithBaseURL("https://agent.example.com/invite"), WithOOBQueryParam()},
+			url:  "https://agent.example.com/invite?oob=" + encodedInvite,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			invite, err := GenerateInviteWithPublicDID(inviteMessage, tc.opts...)
+			require.NoError(t, err)
+			require.Equal(t, tc.url, invite)
+
+			invitation, err := ParseInvitation(invite)
+			require.NoError(t, err)
+			require.Equal(t, inviteMessage, invitation)
+		})
+	}
+
+	invite, err := GenerateInviteWithKeyAndEndpoint(&didexchange.InviteMessage{
+		ID:              "12345678900987654321",
+		RecipientKeys:   []string{"8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K"},
+		ServiceEndpoint: "https://example.com/endpoint",
+	}, WithScheme("didcomm"), WithOOBQueryParam())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(invite, "didcomm://invite?oob="))
+
+	// invalid base URLs
+	invite, err = GenerateInviteWithPublicDID(inviteMessage, WithBaseURL("https://%zz"))
+	require.Error(t, err)
+	require.Empty(t, invite)
+
+	invite, err = GenerateInviteWithPublicDID(inviteMessage, WithBaseURL("/invite"))
+	require.Error(t, err)
+	require.Empty(t, invite)
 }
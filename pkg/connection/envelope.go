@@ -0,0 +1,117 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// sendOpts holds the options of the package level helpers sending protocol messages
+type sendOpts struct {
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	senderDID   string
+}
+
+// SendOpt is an option of the package level helpers sending protocol messages
+type SendOpt func(opts *sendOpts)
+
+// WithEnvelopePacker the message is packed with the given packer instead of the legacy RFC 0019 packer
+func WithEnvelopePacker(packer pack.Packer) SendOpt {
+	return func(opts *sendOpts) {
+		opts.packer = packer
+	}
+}
+
+// WithSenderDIDProvider the message is authcrypted with the verkey of the sender DID held by the DID provider,
+// it is anoncrypted without DID provider
+func WithSenderDIDProvider(didProvider didprovider.Provider) SendOpt {
+	return func(opts *sendOpts) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithSenderDID the message is authcrypted with the verkey of the given DID, our DID of the connection.
+// The exchange messages default to the DID they carry.
+func WithSenderDID(myDID string) SendOpt {
+	return func(opts *sendOpts) {
+		opts.senderDID = myDID
+	}
+}
+
+// SendPackedContext packs the message for the recipient keys of the destination and sends it to its service
+// endpoint, giving up once the context is done. The reply of the destination if any is returned.
+func SendPackedContext(ctx context.Context, msg interface{}, destination *dispatcher.Destination,
+	ot transport.OutboundTransport, opts ...SendOpt) (string, error) {
+	if destination == nil || destination.ServiceEndpoint == "" || len(destination.RecipientKeys) == 0 {
+		return "", errors.New("destination service endpoint and recipient keys are mandatory to send the message")
+	}
+
+	sendOpts := &sendOpts{}
+	// Apply options
+	for _, opt := range opts {
+		opt(sendOpts)
+	}
+	if sendOpts.packer == nil {
+		sendOpts.packer = legacy.New(sendOpts.didProvider)
+	}
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return "", errors.Wrapf(err, "Marshal Message Error")
+	}
+
+	senderKey, err := sendOpts.senderKey()
+	if err != nil {
+		return "", err
+	}
+
+	envelope, err := sendOpts.packer.Pack(msgJSON, senderKey, destination.RecipientKeys)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to pack message")
+	}
+
+	reply, err := transport.SendEnvelope(ctx, ot, envelope, destination.ServiceEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	return string(reply), nil
+}
+
+// senderKey returns the verkey of the sender DID the message is authcrypted with, empty to anoncrypt it
+func (o *sendOpts) senderKey() (string, error) {
+	if o.didProvider == nil || o.senderDID == "" {
+		return "", nil
+	}
+
+	return SenderKey(o.didProvider, o.senderDID)
+}
+
+// SenderKey returns the base58 verkey of our DID held by the DID provider,
+// the messages sent on a connection are authcrypted with the verkey of our DID of the connection
+func SenderKey(didProvider didprovider.Provider, myDID string) (string, error) {
+	if didProvider == nil || myDID == "" {
+		return "", errors.New("DID provider and DID are mandatory to get the sender key")
+	}
+
+	didInfo, err := didProvider.GetLocalDIDInfo(myDID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the keys of %s", myDID)
+	}
+
+	return base58.Encode(didInfo.VerKey), nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/jwe"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+func TestSendPackedContext(t *testing.T) {
+	oTr := mock.NewRecordingTransport().Respond(destinationURL, mock.AnyCall, successResponse)
+	didProvider, theirDIDProvider := didbasic.NewProvider(), didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	dest := destinationOf(t, theirDIDProvider)
+
+	reply, err := SendPackedContext(context.Background(), map[string]string{"@type": "test"}, dest, oTr,
+		WithEnvelopePacker(jwe.New(didProvider)), WithSenderDIDProvider(didProvider), WithSenderDID(myDID.DID))
+	require.NoError(t, err)
+	require.Equal(t, successResponse, reply)
+
+	sent, err := oTr.Last()
+	require.NoError(t, err)
+	require.Equal(t, transport.MediaTypeEnvelope, sent.MediaType)
+	format, err := pack.DetectFormat([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, pack.FormatJWE, format)
+	envelope, err := jwe.New(theirDIDProvider).Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	// can't be marshalled
+	_, err = SendPackedContext(context.Background(), make(chan int), dest, oTr)
+	require.Error(t, err)
+
+	// invalid recipient key
+	_, err = SendPackedContext(context.Background(), "msg",
+		&dispatcher.Destination{ServiceEndpoint: destinationURL, RecipientKeys: []string{"invalid"}}, oTr)
+	require.Error(t, err)
+
+	// no recipient keys to pack for
+	_, err = SendPackedContext(context.Background(), "msg", &dispatcher.Destination{ServiceEndpoint: destinationURL}, oTr)
+	require.Error(t, err)
+	_, err = SendPackedContext(context.Background(), "msg", nil, oTr)
+	require.Error(t, err)
+}
+
+func TestSenderKey(t *testing.T) {
+	didProvider := didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	senderKey, err := SenderKey(didProvider, myDID.DID)
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), senderKey)
+
+	_, err = SenderKey(didProvider, "did:example:unknown")
+	require.Error(t, err)
+	_, err = SenderKey(didProvider, "")
+	require.Error(t, err)
+	_, err = SenderKey(nil, myDID.DID)
+	require.Error(t, err)
+}
@@ -0,0 +1,593 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/document"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// Exchange drives the DID exchange state machine of each connection from the messages sent and received,
+// rejecting messages that arrive out of order. The progress of every connection is saved in a connection record.
+// Connections are tracked by thread ID: the invitation ID while invited, the request ID from then on.
+// Messages are packed for the recipient keys of their destination before being sent.
+type Exchange struct {
+	transport   transport.OutboundTransport
+	store       *ConnectionStore
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	didResolver *resolver.Resolver
+	lock        sync.Mutex
+}
+
+// header is used to peek at the type of an inbound message
+type header struct {
+	Type string `json:"@type,omitempty"`
+}
+
+// NewExchange creates a new DID exchange state machine sending its messages through the given transport
+// and saving connection records in the given store
+func NewExchange(transport transport.OutboundTransport, store *ConnectionStore,
+	opts ...ExchangeOpt) (*Exchange, error) {
+	if store == nil {
+		return nil, errors.New("connection store is mandatory")
+	}
+
+	exchangeOpts := &exchangeOpts{}
+	// Apply options
+	for _, opt := range opts {
+		opt(exchangeOpts)
+	}
+	if exchangeOpts.packer == nil {
+		exchangeOpts.packer = packers.NewDefault(exchangeOpts.didProvider)
+	}
+
+	return &Exchange{
+		transport:   transport,
+		store:       store,
+		packer:      exchangeOpts.packer,
+		didProvider: exchangeOpts.didProvider,
+		didResolver: exchangeOpts.didResolver,
+	}, nil
+}
+
+// State returns the current state of the connection with the given thread ID
+func (e *Exchange) State(threadID string) string {
+	record, err := e.store.GetConnectionRecordByThreadID(threadID)
+	if err != nil {
+		return StateIDNull
+	}
+
+	return record.State
+}
+
+// ConnectionStore returns the store holding the connection records of this exchange
+func (e *Exchange) ConnectionStore() *ConnectionStore {
+	return e.store
+}
+
+// GenerateInviteWithPublicDID generates the DID exchange invitation string with public DID
+// and moves the connection to the invited state
+func (e *Exchange) GenerateInviteWithPublicDID(inviteMessage *didexchange.InviteMessage,
+	opts ...InviteOpt) (string, error) {
+	invite, err := GenerateInviteWithPublicDID(inviteMessage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return invite, e.transition(connectionInvite, inviteMessage.ID, "", true, func(record *ConnectionRecord) {
+		record.InvitationID = inviteMessage.ID
+		record.MyDID = inviteMessage.DID
+	})
+}
+
+// GenerateInviteWithKeyAndEndpoint generates the DID exchange invitation string with recipient key and endpoint
+// and moves the connection to the invited state
+func (e *Exchange) GenerateInviteWithKeyAndEndpoint(inviteMessage *didexchange.InviteMessage,
+	opts ...InviteOpt) (string, error) {
+	invite, err := GenerateInviteWithKeyAndEndpoint(inviteMessage, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return invite, e.transition(connectionInvite, inviteMessage.ID, "", true, func(record *ConnectionRecord) {
+		record.InvitationID = inviteMessage.ID
+	})
+}
+
+// ReceiveInvitation moves the connection to the invited state for an invitation received out of band
+func (e *Exchange) ReceiveInvitation(inviteMessage *didexchange.InviteMessage) error {
+	if inviteMessage == nil || inviteMessage.ID == "" {
+		return errors.New("invitation id is mandatory")
+	}
+
+	return e.transition(connectionInvite, inviteMessage.ID, "", false, func(record *ConnectionRecord) {
+		record.InvitationID = inviteMessage.ID
+		record.TheirLabel = inviteMessage.Label
+		record.TheirDID = inviteMessage.DID
+		record.RecipientKeys = inviteMessage.RecipientKeys
+		record.RoutingKeys = inviteMessage.RoutingKeys
+		record.ServiceEndpoint = inviteMessage.ServiceEndpoint
+	})
+}
+
+// SendExchangeRequest sends exchange request and moves the connection to the requested state
+func (e *Exchange) SendExchangeRequest(exchangeRequest *didexchange.Request,
+	destination *dispatcher.Destination) error {
+	if exchangeRequest == nil || exchangeRequest.ID == "" {
+		return errors.New("exchange request id is mandatory")
+	}
+
+	sendFunc := func() error {
+		exchangeRequest.Type = connectionRequest
+		myDID := ""
+		if exchangeRequest.Connection != nil {
+			myDID = exchangeRequest.Connection.DID
+		}
+		format := e.envelopeFormat(exchangeRequest.ID, parentThreadID(exchangeRequest.Thread))
+		return e.sendMessage(exchangeRequest, myDID, format, destination)
+	}
+
+	return e.send(connectionRequest, exchangeRequest.ID, parentThreadID(exchangeRequest.Thread), sendFunc,
+		func(record *ConnectionRecord) {
+			if exchangeRequest.Connection != nil {
+				record.MyDID = exchangeRequest.Connection.DID
+			}
+		})
+}
+
+// SendExchangeResponse sends exchange response and moves the connection to the responded state,
+// the destination is saved as the one the messages sent on the connection are sent to
+func (e *Exchange) SendExchangeResponse(exchangeResponse *didexchange.Response,
+	destination *dispatcher.Destination) error {
+	if exchangeResponse == nil || exchangeResponse.Thread == nil || exchangeResponse.Thread.ID == "" {
+		return errors.New("exchange response thread id is mandatory")
+	}
+
+	// our own signature, no need to verify it
+	myDID := ""
+	if exchangeResponse.ConnectionSignature != nil {
+		connection, err := unpackSignedConnection(exchangeResponse.ConnectionSignature)
+		if err == nil {
+			myDID = connection.DID
+		}
+	}
+
+	sendFunc := func() error {
+		exchangeResponse.Type = connectionResponse
+		return e.sendMessage(exchangeResponse, myDID, e.envelopeFormat(exchangeResponse.Thread.ID), destination)
+	}
+
+	return e.send(connectionResponse, exchangeResponse.Thread.ID, "", sendFunc, func(record *ConnectionRecord) {
+		if myDID != "" {
+			record.MyDID = myDID
+		}
+		// the inviter reaches the invitee where the response is sent
+		if destination != nil {
+			record.ServiceEndpoint = destination.ServiceEndpoint
+			record.RecipientKeys = destination.RecipientKeys
+			record.RoutingKeys = destination.RoutingKeys
+		}
+	})
+}
+
+// SendExchangeAck sends exchange acknowledgement and moves the connection to the completed state
+func (e *Exchange) SendExchangeAck(exchangeAck *didexchange.Ack, destination *dispatcher.Destination) error {
+	if exchangeAck == nil || exchangeAck.Thread == nil || exchangeAck.Thread.ID == "" {
+		return errors.New("exchange ack thread id is mandatory")
+	}
+
+	sendFunc := func() error {
+		exchangeAck.Type = connectionAck
+		myDID, format := "", ""
+		if record, err := e.store.GetConnectionRecordByThreadID(exchangeAck.Thread.ID); err == nil {
+			myDID, format = record.MyDID, record.EnvelopeFormat
+		}
+		return e.sendMessage(exchangeAck, myDID, format, destination)
+	}
+
+	return e.send(connectionAck, exchangeAck.Thread.ID, "", sendFunc, nil)
+}
+
+// HandleInbound moves the connection to the next state for the given inbound exchange message,
+// a problem report abandons the connection
+func (e *Exchange) HandleInbound(payload []byte) error {
+	msgHeader := &header{}
+	if err := json.Unmarshal(payload, msgHeader); err != nil {
+		return errors.Wrapf(err, "Unmarshal Exchange Message Error")
+	}
+
+	switch msgHeader.Type {
+	case connectionInvite:
+		return e.handleInboundInvitation(payload)
+	case connectionRequest:
+		return e.handleInboundRequest(payload)
+	case connectionResponse:
+		response := &didexchange.Response{}
+		if err := json.Unmarshal(payload, response); err != nil {
+			return errors.Wrapf(err, "Unmarshal Exchange Response Error")
+		}
+		return e.handleInboundResponse(response)
+	case connectionAck:
+		ack := &didexchange.Ack{}
+		if err := json.Unmarshal(payload, ack); err != nil {
+			return errors.Wrapf(err, "Unmarshal Exchange Ack Error")
+		}
+		return e.transition(connectionAck, threadID(ack.Thread), "", false, nil)
+	case connectionProblemReport:
+		report := &didexchange.ProblemReport{}
+		if err := json.Unmarshal(payload, report); err != nil {
+			return errors.Wrapf(err, "Unmarshal Exchange Problem Report Error")
+		}
+		return e.Abandon(threadID(report.Thread))
+	default:
+		return errors.Errorf("unrecognized msgType: %s", msgHeader.Type)
+	}
+}
+
+// handleInboundInvitation moves the connection to the invited state for the invitation received
+func (e *Exchange) handleInboundInvitation(payload []byte) error {
+	invitation := &didexchange.InviteMessage{}
+	if err := json.Unmarshal(payload, invitation); err != nil {
+		return errors.Wrapf(err, "Unmarshal Exchange Invitation Error")
+	}
+
+	return e.ReceiveInvitation(invitation)
+}
+
+// handleInboundRequest moves the connection to the requested state for the exchange request received
+func (e *Exchange) handleInboundRequest(payload []byte) error {
+	request := &didexchange.Request{}
+	if err := json.Unmarshal(payload, request); err != nil {
+		return errors.Wrapf(err, "Unmarshal Exchange Request Error")
+	}
+	if request.ID == "" {
+		return errors.New("exchange request id is mandatory")
+	}
+
+	return e.transition(connectionRequest, request.ID, parentThreadID(request.Thread), false,
+		func(record *ConnectionRecord) {
+			record.TheirLabel = request.Label
+			if request.Connection != nil {
+				record.TheirDID = request.Connection.DID
+				record.TheirDIDDoc = request.Connection.DIDDoc
+				// the invitee is reached at the service endpoint of its DID document until the response is sent
+				record.ServiceEndpoint = didDocServiceEndpoint(request.Connection.DIDDoc)
+				record.RecipientKeys = didDocKeys(request.Connection.DIDDoc)
+			}
+		})
+}
+
+// handleInboundResponse verifies the connection~sig of the exchange response before moving the connection
+// to the responded state. The response must be signed with one of the invitation's recipient keys,
+// or one of the keys of the invitation's public DID.
+func (e *Exchange) handleInboundResponse(response *didexchange.Response) error {
+	connection, err := VerifyConnectionSignature(response.ConnectionSignature)
+	if err != nil {
+		return err
+	}
+
+	thID := threadID(response.Thread)
+	record, err := e.store.GetConnectionRecordByThreadID(thID)
+	if err == nil {
+		signerKeys, keysErr := e.signerKeys(record)
+		if keysErr != nil {
+			return keysErr
+		}
+		if !contains(signerKeys, response.ConnectionSignature.SignVerKey) {
+			return invalidSignature("signer %s is not a key of the invitation",
+				response.ConnectionSignature.SignVerKey)
+		}
+	}
+
+	return e.transition(connectionResponse, thID, "", false, func(record *ConnectionRecord) {
+		record.TheirDID = connection.DID
+		record.TheirDIDDoc = connection.DIDDoc
+	})
+}
+
+// signerKeys returns the keys the exchange response of the connection can be signed with, the recipient keys
+// of the invitation or else the keys of the DID document of its public DID
+func (e *Exchange) signerKeys(record *ConnectionRecord) ([]string, error) {
+	if len(record.RecipientKeys) > 0 {
+		return record.RecipientKeys, nil
+	}
+	if record.TheirDID == "" {
+		return nil, invalidSignature("the invitation has neither recipient keys nor public DID to verify the signer")
+	}
+	if e.didResolver == nil {
+		return nil, invalidSignature("no DID resolver to get the keys of %s", record.TheirDID)
+	}
+
+	resolved, err := e.didResolver.Resolve(record.TheirDID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s", record.TheirDID)
+	}
+
+	var keys []string
+	didDoc := document.DIDDocument(resolved)
+	for _, publicKey := range didDoc.PublicKeys() {
+		if key := publicKey.PublicKeyBase58(); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// SetEnvelopeFormat sets the format of the envelopes packing the messages sent on the connection
+// with the given thread ID, such as pack.FormatJWE for partners expecting JWE envelopes
+func (e *Exchange) SetEnvelopeFormat(threadID, format string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	record, err := e.store.GetConnectionRecordByThreadID(threadID)
+	if err != nil {
+		return errors.Wrapf(err, "no connection found for thread id %s", threadID)
+	}
+	record.EnvelopeFormat = format
+
+	return e.store.SaveConnectionRecord(record)
+}
+
+// Abandon moves the connection with the given thread ID to the abandoned state
+func (e *Exchange) Abandon(threadID string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	record, err := e.store.GetConnectionRecordByThreadID(threadID)
+	if err != nil {
+		return errors.Wrapf(err, "no connection found for thread id %s", threadID)
+	}
+
+	current, err := stateFromName(record.State)
+	if err != nil {
+		return err
+	}
+
+	next := &abandoned{}
+	if !current.CanTransitionTo(next) {
+		return errors.Errorf("invalid state transition: %s -> %s", current.Name(), next.Name())
+	}
+	record.State = next.Name()
+
+	return e.store.SaveConnectionRecord(record)
+}
+
+// send moves the connection to the state of the given message type before sending it, so that the message
+// can't be sent twice on the same thread, and moves it back if the message couldn't be sent
+func (e *Exchange) send(msgType, thID, pthID string, sendFunc func() error, update func(*ConnectionRecord)) error {
+	previous, err := e.reserve(msgType, thID, pthID, update)
+	if err != nil {
+		return err
+	}
+
+	if err := sendFunc(); err != nil {
+		if rollbackErr := e.rollback(msgType, thID, previous); rollbackErr != nil {
+			return errors.Wrapf(err, "failed to roll back the connection: %s", rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// reserve moves the connection to the state of the given message type as transition does,
+// the connection record before the transition is returned, nil if it didn't exist
+func (e *Exchange) reserve(msgType, thID, pthID string, update func(*ConnectionRecord)) (*ConnectionRecord, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	record, err := e.nextState(msgType, thID, pthID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := e.store.GetConnectionRecord(record.ConnectionID)
+	if err != nil && errors.Cause(err) != storage.ErrDataNotFound {
+		return nil, err
+	}
+
+	if update != nil {
+		update(record)
+	}
+
+	return previous, e.store.SaveConnectionRecord(record)
+}
+
+// rollback restores the connection record saved before reserving the state of the given message type,
+// unless the connection moved on in the meantime
+func (e *Exchange) rollback(msgType, thID string, previous *ConnectionRecord) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	reserved, err := stateFromMsgType(msgType)
+	if err != nil {
+		return err
+	}
+
+	record, err := e.store.GetConnectionRecordByThreadID(thID)
+	if err != nil {
+		return err
+	}
+	if record.State != reserved.Name() {
+		return nil
+	}
+
+	if previous == nil {
+		return e.store.deleteConnectionRecord(record)
+	}
+
+	return e.store.SaveConnectionRecord(previous)
+}
+
+// sendMessage packs the message for the recipient keys of the destination and sends it to its service endpoint,
+// the message is authcrypted with the verkey of myDID if the DID provider holds it.
+// The envelope format of the connection is used if set, the default format of the packer otherwise.
+func (e *Exchange) sendMessage(msg interface{}, myDID, format string, destination *dispatcher.Destination) error {
+	if destination == nil || destination.ServiceEndpoint == "" {
+		return errors.New("destination service endpoint is mandatory")
+	}
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Exchange Message Error")
+	}
+
+	senderKey, err := e.senderKey(myDID)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := pack.PackWithFormat(e.packer, format, msgJSON, senderKey, destination.RecipientKeys)
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack exchange message")
+	}
+
+	_, err = transport.SendEnvelope(context.Background(), e.transport, envelope, destination.ServiceEndpoint)
+	return err
+}
+
+// envelopeFormat returns the envelope format of the first connection found for the given thread IDs
+func (e *Exchange) envelopeFormat(threadIDs ...string) string {
+	for _, thID := range threadIDs {
+		if thID == "" {
+			continue
+		}
+		if record, err := e.store.GetConnectionRecordByThreadID(thID); err == nil {
+			return record.EnvelopeFormat
+		}
+	}
+
+	return ""
+}
+
+// senderKey returns the verkey of myDID, empty if there is no DID provider to anoncrypt the messages
+func (e *Exchange) senderKey(myDID string) (string, error) {
+	if e.didProvider == nil || myDID == "" {
+		return "", nil
+	}
+
+	return SenderKey(e.didProvider, myDID)
+}
+
+// transition moves the connection with the given thread ID to the state of the given message type,
+// update is called to fill in the connection record from the message before it's saved
+func (e *Exchange) transition(msgType, thID, pthID string, outbound bool, update func(*ConnectionRecord)) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	record, err := e.nextState(msgType, thID, pthID, outbound)
+	if err != nil {
+		return err
+	}
+
+	if update != nil {
+		update(record)
+	}
+
+	return e.store.SaveConnectionRecord(record)
+}
+
+// nextState returns the connection record of the given thread moved to the state of the given message type,
+// callers must hold the lock.
+// A connection that is not tracked yet starts from the state of its parent thread (the invitation) if any.
+func (e *Exchange) nextState(msgType, thID, pthID string, outbound bool) (*ConnectionRecord, error) {
+	if thID == "" {
+		return nil, errors.New("thread id is mandatory")
+	}
+
+	next, err := stateFromMsgType(msgType)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := e.store.GetConnectionRecordByThreadID(thID)
+	if err != nil && errors.Cause(err) != storage.ErrDataNotFound {
+		return nil, err
+	}
+	if record == nil {
+		record, err = e.newConnectionRecord(thID, pthID, outbound)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := stateFromName(record.State)
+	if err != nil {
+		return nil, err
+	}
+	if !current.CanTransitionTo(next) {
+		return nil, errors.Errorf("invalid state transition: %s -> %s", current.Name(), next.Name())
+	}
+	record.State = next.Name()
+
+	return record, nil
+}
+
+// newConnectionRecord creates the connection record for a thread that is not tracked yet.
+// The invitee, sending the request, carries on with the connection created from the received invitation,
+// while the inviter creates a new connection for every request received so that invitations can be reused.
+func (e *Exchange) newConnectionRecord(thID, pthID string, outbound bool) (*ConnectionRecord, error) {
+	if pthID == "" {
+		return &ConnectionRecord{ConnectionID: uuid.New().String(), ThreadID: thID, State: StateIDNull}, nil
+	}
+
+	invitation, err := e.store.GetConnectionRecordByThreadID(pthID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no invitation found for parent thread id %s", pthID)
+	}
+
+	if outbound {
+		invitation.ThreadID = thID
+		return invitation, nil
+	}
+
+	return &ConnectionRecord{
+		ConnectionID: uuid.New().String(),
+		ThreadID:     thID,
+		InvitationID: invitation.InvitationID,
+		MyDID:        invitation.MyDID,
+		State:        invitation.State,
+	}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func threadID(thread *didexchange.Thread) string {
+	if thread == nil {
+		return ""
+	}
+	return thread.ID
+}
+
+func parentThreadID(thread *didexchange.Thread) string {
+	if thread == nil {
+		return ""
+	}
+	return thread.PID
+}
@@ -0,0 +1,592 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/jwe"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/did-common-go/pkg/diddoc"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	invitationID = "12345678900987654321"
+	requestID    = "5678876542345"
+	publicDID    = "did:trustbloc:ZadolSRQkehfo"
+)
+
+func TestExchange_Inviter(t *testing.T) {
+	e := newExchange(t)
+
+	_, err := e.GenerateInviteWithKeyAndEndpoint(&didexchange.InviteMessage{
+		ID:              invitationID,
+		Label:           "Alice",
+		RecipientKeys:   []string{"8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K"},
+		ServiceEndpoint: "https://example.com/endpoint",
+	})
+	require.NoError(t, err)
+	require.Equal(t, StateIDInvited, e.State(invitationID))
+
+	// response can't be sent before the request was received
+	resp := &didexchange.Response{ID: "response-id", Thread: &didexchange.Thread{ID: requestID}}
+	require.Error(t, e.SendExchangeResponse(resp, newDestination(t)))
+
+	didDoc := &diddoc.DIDDoc{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"@context": ["https://w3id.org/did/v1"],
+		"id": "did:example:bob",
+		"publicKey": [{"id": "did:example:bob#key-1", "type": "Ed25519VerificationKey2018",
+			"controller": "did:example:bob", "publicKeyBase58": "H3C2AVvLMv6gmMNam3uVAjZpfkcJCwDwnZn6z3wXmqPV"}],
+		"service": [{"id": "did:example:bob;did-communication", "type": "did-communication",
+			"serviceEndpoint": "https://bob.example.com"}]
+	}`), didDoc))
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Request{
+		Type:       connectionRequest,
+		ID:         requestID,
+		Label:      "Bob",
+		Thread:     &didexchange.Thread{PID: invitationID},
+		Connection: &didexchange.Connection{DID: "did:example:bob", DIDDoc: didDoc},
+	})))
+	require.Equal(t, StateIDRequested, e.State(requestID))
+	require.Equal(t, StateIDInvited, e.State(invitationID))
+
+	// the invitee is reached at the service endpoint of its DID document
+	record, err := e.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, "https://bob.example.com", record.ServiceEndpoint)
+	require.Equal(t, []string{"H3C2AVvLMv6gmMNam3uVAjZpfkcJCwDwnZn6z3wXmqPV"}, record.RecipientKeys)
+
+	// then where the response is sent
+	destination := newDestination(t)
+	require.NoError(t, e.SendExchangeResponse(resp, destination))
+	require.Equal(t, StateIDResponded, e.State(requestID))
+	record, err = e.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, destination.ServiceEndpoint, record.ServiceEndpoint)
+	require.Equal(t, destination.RecipientKeys, record.RecipientKeys)
+
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Ack{
+		Type:   connectionAck,
+		ID:     "ack-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	})))
+	require.Equal(t, StateIDCompleted, e.State(requestID))
+
+	record, err = e.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, invitationID, record.InvitationID)
+	require.Equal(t, "Bob", record.TheirLabel)
+
+	// invitation is kept for other invitees
+	invitation, err := e.ConnectionStore().GetConnectionRecordByThreadID(invitationID)
+	require.NoError(t, err)
+	require.NotEqual(t, invitation.ConnectionID, record.ConnectionID)
+
+	// no more transitions once completed
+	require.Error(t, e.Abandon(requestID))
+}
+
+func TestExchange_Invitee(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	e := newExchange(t, WithDIDResolver(newDIDResolver(publicDID, pubKey)))
+
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.InviteMessage{
+		Type:  connectionInvite,
+		ID:    invitationID,
+		Label: "Alice",
+		DID:   publicDID,
+	})))
+	require.Equal(t, StateIDInvited, e.State(invitationID))
+	invitation, err := e.ConnectionStore().GetConnectionRecordByThreadID(invitationID)
+	require.NoError(t, err)
+
+	// invitation can only be received once
+	require.Error(t, e.ReceiveInvitation(&didexchange.InviteMessage{ID: invitationID}))
+
+	req := &didexchange.Request{ID: requestID, Label: "Bob", Thread: &didexchange.Thread{PID: invitationID}}
+	require.NoError(t, e.SendExchangeRequest(req, newDestination(t)))
+	require.Equal(t, StateIDRequested, e.State(requestID))
+
+	// request can only be sent once
+	require.Error(t, e.SendExchangeRequest(req, newDestination(t)))
+
+	resp := &didexchange.Response{
+		Type:   connectionResponse,
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+	require.NoError(t, e.HandleInbound(toBytes(t, resp)))
+	require.Equal(t, StateIDResponded, e.State(requestID))
+
+	require.NoError(t, e.SendExchangeAck(&didexchange.Ack{ID: "ack-id", Thread: &didexchange.Thread{ID: requestID}},
+		newDestination(t)))
+	require.Equal(t, StateIDCompleted, e.State(requestID))
+
+	// connection created from the invitation moved to the request thread
+	record, err := e.ConnectionStore().GetConnectionRecordByTheirDID("did:example:alice")
+	require.NoError(t, err)
+	require.Equal(t, invitation.ConnectionID, record.ConnectionID)
+	require.Equal(t, requestID, record.ThreadID)
+	require.Equal(t, "Alice", record.TheirLabel)
+	require.Equal(t, StateIDNull, e.State(invitationID))
+}
+
+func TestExchange_ImplicitInvitation(t *testing.T) {
+	e := newExchange(t)
+
+	// request without an invitation thread is accepted for public DIDs
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Request{Type: connectionRequest, ID: requestID})))
+	require.Equal(t, StateIDRequested, e.State(requestID))
+
+	// request referencing an unknown invitation is rejected
+	err := e.HandleInbound(toBytes(t, &didexchange.Request{
+		Type:   connectionRequest,
+		ID:     "other-request",
+		Thread: &didexchange.Thread{PID: "unknown"},
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no invitation found")
+	require.Equal(t, StateIDNull, e.State("other-request"))
+}
+
+func TestExchange_OutOfOrder(t *testing.T) {
+	e := newExchange(t)
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// response and ack for unknown threads
+	resp := &didexchange.Response{
+		Type:   connectionResponse,
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+	err = e.HandleInbound(toBytes(t, resp))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid state transition: null -> responded")
+
+	err = e.HandleInbound(toBytes(t, &didexchange.Ack{
+		Type:   connectionAck,
+		ID:     "ack-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid state transition: null -> completed")
+
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID}, newDestination(t)))
+
+	// ack before response
+	err = e.SendExchangeAck(&didexchange.Ack{ID: "ack-id", Thread: &didexchange.Thread{ID: requestID}},
+		newDestination(t))
+	require.Error(t, err)
+	require.Equal(t, StateIDRequested, e.State(requestID))
+
+	require.NoError(t, e.Abandon(requestID))
+	require.Equal(t, StateIDAbandoned, e.State(requestID))
+	require.Error(t, e.Abandon("unknown"))
+}
+
+func TestExchange_SendFailure(t *testing.T) {
+	e := newExchange(t)
+
+	// state is unchanged when the message could not be sent
+	require.Error(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID}, &dispatcher.Destination{}))
+	require.Equal(t, StateIDNull, e.State(requestID))
+
+	// or packed
+	require.Error(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID},
+		&dispatcher.Destination{ServiceEndpoint: destinationURL}))
+	require.Equal(t, StateIDNull, e.State(requestID))
+}
+
+func TestExchange_SendFailureAfterInvitation(t *testing.T) {
+	e := newExchange(t)
+	require.NoError(t, e.ReceiveInvitation(&didexchange.InviteMessage{ID: invitationID}))
+
+	// the connection moves back to the invitation thread
+	req := &didexchange.Request{ID: requestID, Thread: &didexchange.Thread{PID: invitationID}}
+	require.Error(t, e.SendExchangeRequest(req, &dispatcher.Destination{}))
+	require.Equal(t, StateIDNull, e.State(requestID))
+	require.Equal(t, StateIDInvited, e.State(invitationID))
+
+	require.NoError(t, e.SendExchangeRequest(req, newDestination(t)))
+	require.Equal(t, StateIDRequested, e.State(requestID))
+}
+
+func TestExchange_ConcurrentSend(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	// the same request is sent again while the first one is being sent
+	var e *Exchange
+	var sendAgainErr error
+	sendAgain := hookTransport(func(data, destination string) (string, error) {
+		sendAgainErr = e.SendExchangeRequest(&didexchange.Request{ID: requestID}, newDestination(t))
+		return successResponse, nil
+	})
+	e, err = NewExchange(sendAgain, store)
+	require.NoError(t, err)
+
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID}, newDestination(t)))
+	require.Error(t, sendAgainErr)
+	require.Contains(t, sendAgainErr.Error(), "invalid state transition: requested -> requested")
+	require.Equal(t, StateIDRequested, e.State(requestID))
+}
+
+func TestExchange_ProblemReport(t *testing.T) {
+	e := newExchange(t)
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID}, newDestination(t)))
+
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.ProblemReport{
+		Type:        connectionProblemReport,
+		ID:          "report-id",
+		Thread:      &didexchange.Thread{ID: requestID},
+		Description: &didexchange.ProblemDescription{Code: "request_not_accepted"},
+	})))
+	require.Equal(t, StateIDAbandoned, e.State(requestID))
+
+	// unknown thread
+	require.Error(t, e.HandleInbound(toBytes(t, &didexchange.ProblemReport{
+		Type:   connectionProblemReport,
+		ID:     "report-id",
+		Thread: &didexchange.Thread{ID: "unknown"},
+	})))
+	require.Error(t, e.HandleInbound([]byte(`{"@type":"`+connectionProblemReport+`","@id":1}`)))
+}
+
+func TestExchange_InvalidMessages(t *testing.T) {
+	e := newExchange(t)
+
+	require.Error(t, e.HandleInbound([]byte("invalid json")))
+	require.Error(t, e.HandleInbound(toBytes(t, &header{Type: "unknown"})))
+	require.Error(t, e.HandleInbound(toBytes(t, &didexchange.Request{Type: connectionRequest})))
+	require.Error(t, e.HandleInbound(toBytes(t, &didexchange.Response{Type: connectionResponse})))
+	require.Error(t, e.HandleInbound([]byte(`{"@type":"`+connectionResponse+`","@id":1}`)))
+	require.Error(t, e.HandleInbound(toBytes(t, &didexchange.Ack{Type: connectionAck})))
+	require.Error(t, e.HandleInbound(toBytes(t, &didexchange.InviteMessage{Type: connectionInvite})))
+	require.Error(t, e.HandleInbound([]byte(`{"@type":"`+connectionRequest+`","@id":1}`)))
+
+	require.Error(t, e.SendExchangeRequest(nil, newDestination(t)))
+	require.Error(t, e.SendExchangeResponse(&didexchange.Response{ID: "response-id"}, newDestination(t)))
+	require.Error(t, e.SendExchangeAck(&didexchange.Ack{ID: "ack-id"}, newDestination(t)))
+	require.Error(t, e.ReceiveInvitation(nil))
+
+	_, err := e.GenerateInviteWithPublicDID(&didexchange.InviteMessage{ID: invitationID})
+	require.Error(t, err)
+	_, err = e.GenerateInviteWithKeyAndEndpoint(&didexchange.InviteMessage{ID: invitationID})
+	require.Error(t, err)
+	require.Equal(t, StateIDNull, e.State(invitationID))
+}
+
+func TestExchange_InvalidResponseSignature(t *testing.T) {
+	e := newExchange(t)
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	require.NoError(t, e.ReceiveInvitation(&didexchange.InviteMessage{
+		ID:              invitationID,
+		RecipientKeys:   []string{base58.Encode(pubKey)},
+		ServiceEndpoint: "https://example.com/endpoint",
+	}))
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{
+		ID:     requestID,
+		Thread: &didexchange.Thread{PID: invitationID},
+	}, newDestination(t)))
+
+	resp := &didexchange.Response{
+		Type:   connectionResponse,
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}
+
+	// unsigned
+	err = e.HandleInbound(toBytes(t, resp))
+	require.IsType(t, &InvalidSignatureError{}, err)
+
+	// signed with a key that isn't a recipient key of the invitation
+	otherPubKey, otherPrivKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"},
+		otherPubKey, otherPrivKey))
+	err = e.HandleInbound(toBytes(t, resp))
+	require.IsType(t, &InvalidSignatureError{}, err)
+	require.Contains(t, err.Error(), "is not a key of the invitation")
+
+	// tampered signed data
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+	resp.ConnectionSignature.Signature = resp.ConnectionSignature.Signature[1:]
+	err = e.HandleInbound(toBytes(t, resp))
+	require.IsType(t, &InvalidSignatureError{}, err)
+	require.Equal(t, StateIDRequested, e.State(requestID))
+
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+	require.NoError(t, e.HandleInbound(toBytes(t, resp)))
+	require.Equal(t, StateIDResponded, e.State(requestID))
+}
+
+func TestExchange_PublicDIDResponseSigner(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	resp := &didexchange.Response{
+		Type:   connectionResponse,
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+
+	request := func(e *Exchange, invitation *didexchange.InviteMessage) {
+		require.NoError(t, e.ReceiveInvitation(invitation))
+		require.NoError(t, e.SendExchangeRequest(&didexchange.Request{
+			ID:     requestID,
+			Thread: &didexchange.Thread{PID: invitationID},
+		}, newDestination(t)))
+	}
+
+	t.Run("no DID resolver", func(t *testing.T) {
+		e := newExchange(t)
+		request(e, &didexchange.InviteMessage{ID: invitationID, DID: publicDID})
+
+		err := e.HandleInbound(toBytes(t, resp))
+		require.IsType(t, &InvalidSignatureError{}, err)
+		require.Equal(t, StateIDRequested, e.State(requestID))
+	})
+
+	t.Run("signer isn't a key of the public DID", func(t *testing.T) {
+		otherPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		e := newExchange(t, WithDIDResolver(newDIDResolver(publicDID, otherPubKey)))
+		request(e, &didexchange.InviteMessage{ID: invitationID, DID: publicDID})
+
+		err = e.HandleInbound(toBytes(t, resp))
+		require.IsType(t, &InvalidSignatureError{}, err)
+		require.Contains(t, err.Error(), "is not a key of the invitation")
+	})
+
+	t.Run("public DID can't be resolved", func(t *testing.T) {
+		e := newExchange(t, WithDIDResolver(resolver.New()))
+		request(e, &didexchange.InviteMessage{ID: invitationID, DID: publicDID})
+
+		err := e.HandleInbound(toBytes(t, resp))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to resolve")
+	})
+
+	t.Run("neither recipient keys nor public DID", func(t *testing.T) {
+		e := newExchange(t, WithDIDResolver(newDIDResolver(publicDID, pubKey)))
+		require.NoError(t, e.SendExchangeRequest(&didexchange.Request{ID: requestID}, newDestination(t)))
+
+		err := e.HandleInbound(toBytes(t, resp))
+		require.IsType(t, &InvalidSignatureError{}, err)
+	})
+}
+
+func TestExchange_SendSignedResponse(t *testing.T) {
+	e := newExchange(t)
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Request{Type: connectionRequest, ID: requestID})))
+
+	resp := &didexchange.Response{ID: "response-id", Thread: &didexchange.Thread{ID: requestID}}
+	require.NoError(t, SignExchangeResponse(resp, &didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey))
+	require.NoError(t, e.SendExchangeResponse(resp, newDestination(t)))
+
+	record, err := e.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, "did:example:alice", record.MyDID)
+}
+
+func TestNewExchange(t *testing.T) {
+	e, err := NewExchange(mock.NewOutboundTransport(successResponse), nil)
+	require.Error(t, err)
+	require.Nil(t, e)
+}
+
+func TestExchange_Packing(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	didProvider := didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	transport := &recordingTransport{}
+	e, err := NewExchange(transport, store, WithDIDProvider(didProvider))
+	require.NoError(t, err)
+
+	theirDIDProvider := didbasic.NewProvider()
+	theirDID, err := theirDIDProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	dest := &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+
+	// request authcrypted with the verkey of our DID
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{
+		ID:         requestID,
+		Connection: &didexchange.Connection{DID: myDID.DID},
+	}, dest))
+	require.Equal(t, destinationURL, transport.destination)
+
+	envelope, err := legacy.New(theirDIDProvider).Unpack([]byte(transport.data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+	request := &didexchange.Request{}
+	require.NoError(t, json.Unmarshal(envelope.Message, request))
+	require.Equal(t, requestID, request.ID)
+	require.Equal(t, connectionRequest, request.Type)
+
+	// our DID isn't held by the DID provider
+	err = e.SendExchangeRequest(&didexchange.Request{
+		ID:         "other-request",
+		Connection: &didexchange.Connection{DID: "did:example:unknown"},
+	}, dest)
+	require.Error(t, err)
+	require.Equal(t, StateIDNull, e.State("other-request"))
+
+	// custom packer
+	e, err = NewExchange(transport, store, WithPacker(legacy.New(nil)))
+	require.NoError(t, err)
+	require.NoError(t, e.SendExchangeRequest(&didexchange.Request{ID: "anon-request"}, dest))
+	envelope, err = legacy.New(theirDIDProvider).Unpack([]byte(transport.data))
+	require.NoError(t, err)
+	require.Empty(t, envelope.SenderKey)
+}
+
+func TestExchange_EnvelopeFormat(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+	transport := &recordingTransport{}
+	e, err := NewExchange(transport, store)
+	require.NoError(t, err)
+
+	theirDIDProvider := didbasic.NewProvider()
+	theirDID, err := theirDIDProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	dest := &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+
+	require.Error(t, e.SetEnvelopeFormat(requestID, pack.FormatJWE))
+
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Request{
+		Type:  connectionRequest,
+		ID:    requestID,
+		Label: "Bob",
+	})))
+	require.NoError(t, e.SetEnvelopeFormat(requestID, pack.FormatJWE))
+
+	record, err := store.GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, pack.FormatJWE, record.EnvelopeFormat)
+
+	// response packed in the format of the connection
+	require.NoError(t, e.SendExchangeResponse(&didexchange.Response{
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: requestID},
+	}, dest))
+	format, err := pack.DetectFormat([]byte(transport.data))
+	require.NoError(t, err)
+	require.Equal(t, pack.FormatJWE, format)
+	envelope, err := jwe.New(theirDIDProvider).Unpack([]byte(transport.data))
+	require.NoError(t, err)
+	response := &didexchange.Response{}
+	require.NoError(t, json.Unmarshal(envelope.Message, response))
+	require.Equal(t, "response-id", response.ID)
+
+	// unsupported format
+	require.NoError(t, e.HandleInbound(toBytes(t, &didexchange.Request{Type: connectionRequest, ID: "other-request"})))
+	require.NoError(t, e.SetEnvelopeFormat("other-request", "other"))
+	err = e.SendExchangeResponse(&didexchange.Response{Thread: &didexchange.Thread{ID: "other-request"}}, dest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported envelope format")
+
+	// packer without envelope formats
+	e, err = NewExchange(transport, store, WithPacker(legacy.New(nil)))
+	require.NoError(t, err)
+	err = e.SendExchangeResponse(&didexchange.Response{Thread: &didexchange.Thread{ID: "other-request"}}, dest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't support envelope format")
+}
+
+// recordingTransport keeps the last message sent
+type recordingTransport struct {
+	data        string
+	destination string
+}
+
+func (r *recordingTransport) Send(data, destination string) (string, error) {
+	r.data = data
+	r.destination = destination
+	return successResponse, nil
+}
+
+// hookTransport calls the hook function for every message sent
+type hookTransport func(data, destination string) (string, error)
+
+func (h hookTransport) Send(data, destination string) (string, error) {
+	return h(data, destination)
+}
+
+func newExchange(t *testing.T, opts ...ExchangeOpt) *Exchange {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	e, err := NewExchange(mock.NewOutboundTransport(successResponse), store, opts...)
+	require.NoError(t, err)
+
+	return e
+}
+
+// staticDIDMethod reads the DID documents it holds
+type staticDIDMethod map[string][]byte
+
+func (m staticDIDMethod) Read(did string, versionID interface{}, versionTime string, noCache bool) ([]byte, error) {
+	return m[did], nil
+}
+
+// newDIDResolver returns a resolver of the trustbloc DID with the given ed25519 public key
+func newDIDResolver(did string, pubKey []byte) *resolver.Resolver {
+	didDoc := fmt.Sprintf(`{"@context":"https://w3id.org/did/v1","id":"%s","publicKey":[`+
+		`{"id":"%s#key1","type":"Ed25519VerificationKey2018","publicKeyBase58":"%s"}]}`,
+		did, did, base58.Encode(pubKey))
+
+	return resolver.New(resolver.WithDidMethod("trustbloc", staticDIDMethod{did: []byte(didDoc)}))
+}
+
+// newDestination returns a destination with a new recipient key
+func newDestination(t *testing.T) *dispatcher.Destination {
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return &dispatcher.Destination{ServiceEndpoint: destinationURL, RecipientKeys: []string{base58.Encode(pubKey)}}
+}
+
+func toBytes(t *testing.T, data interface{}) []byte {
+	bytes, err := json.Marshal(data)
+	require.NoError(t, err)
+	return bytes
+}
@@ -0,0 +1,411 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/document"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	"github.com/trustbloc/did-common-go/pkg/diddoc"
+)
+
+const (
+	// StoreName name of the store holding connection records
+	StoreName = "connection"
+
+	connIDKeyPrefix   = "conn_"
+	threadIDKeyPrefix = "thid_"
+	theirDIDKeyPrefix = "theirdid_"
+	theirKeyKeyPrefix = "theirkey_"
+)
+
+// ConnectionRecord holds the results of a DID exchange
+type ConnectionRecord struct {
+	ConnectionID    string         `json:"connectionID,omitempty"`
+	ThreadID        string         `json:"threadID,omitempty"`
+	State           string         `json:"state,omitempty"`
+	MyDID           string         `json:"myDID,omitempty"`
+	TheirDID        string         `json:"theirDID,omitempty"`
+	TheirDIDDoc     *diddoc.DIDDoc `json:"theirDIDDoc,omitempty"`
+	TheirLabel      string         `json:"theirLabel,omitempty"`
+	InvitationID    string         `json:"invitationID,omitempty"`
+	RecipientKeys   []string       `json:"recipientKeys,omitempty"`
+	RoutingKeys     []string       `json:"routingKeys,omitempty"`
+	ServiceEndpoint string         `json:"serviceEndpoint,omitempty"`
+	EnvelopeFormat  string         `json:"envelopeFormat,omitempty"`
+	CreatedTime     time.Time      `json:"createdTime,omitempty"`
+	UpdatedTime     time.Time      `json:"updatedTime,omitempty"`
+}
+
+// TheirKeys returns the keys of the other party of the connection, the recipient keys of its service endpoint
+// followed by the base58 keys of their DID document
+func (r *ConnectionRecord) TheirKeys() []string {
+	keys := append([]string(nil), r.RecipientKeys...)
+	for _, key := range didDocKeys(r.TheirDIDDoc) {
+		if !contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// didDocument returns the DID document as a JSON-LD document, nil if there is none or it can't be converted
+func didDocument(didDoc *diddoc.DIDDoc) document.DIDDocument {
+	if didDoc == nil {
+		return nil
+	}
+
+	didDocJSON, err := json.Marshal(didDoc)
+	if err != nil {
+		return nil
+	}
+
+	doc, err := document.DidDocumentFromBytes(didDocJSON)
+	if err != nil {
+		return nil
+	}
+
+	return doc
+}
+
+// didDocKeys returns the base58 public keys of the DID document
+func didDocKeys(didDoc *diddoc.DIDDoc) []string {
+	doc := didDocument(didDoc)
+
+	var keys []string
+	for _, publicKey := range doc.PublicKeys() {
+		if key := publicKey.PublicKeyBase58(); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// didDocServiceEndpoint returns the first service endpoint of the DID document, empty if there is none
+func didDocServiceEndpoint(didDoc *diddoc.DIDDoc) string {
+	doc := didDocument(didDoc)
+	for _, service := range doc.Services() {
+		if endpoint, ok := service.Endpoint().(string); ok && endpoint != "" {
+			return endpoint
+		}
+	}
+
+	return ""
+}
+
+// ConnectionStore persists connection records and indexes them by thread ID, their DID and their keys
+type ConnectionStore struct {
+	store storage.Store
+}
+
+// NewConnectionStore creates a new connection record store on top of the given store
+func NewConnectionStore(store storage.Store) (*ConnectionStore, error) {
+	if store == nil {
+		return nil, errors.New("store is mandatory")
+	}
+
+	return &ConnectionStore{store: store}, nil
+}
+
+// SaveConnectionRecord saves the connection record and updates its indexes
+func (c *ConnectionStore) SaveConnectionRecord(record *ConnectionRecord) error {
+	if record == nil || record.ConnectionID == "" {
+		return errors.New("connection id is mandatory")
+	}
+
+	now := time.Now().UTC()
+	if record.CreatedTime.IsZero() {
+		record.CreatedTime = now
+	}
+	record.UpdatedTime = now
+
+	if err := c.dropStaleIndexes(record); err != nil {
+		return err
+	}
+
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Connection Record Error")
+	}
+	if err := c.store.Put(connIDKeyPrefix+record.ConnectionID, recordBytes); err != nil {
+		return errors.Wrapf(err, "failed to save connection record %s", record.ConnectionID)
+	}
+
+	return c.index(record)
+}
+
+// GetConnectionRecord fetches the connection record for the given connection ID
+func (c *ConnectionStore) GetConnectionRecord(connectionID string) (*ConnectionRecord, error) {
+	recordBytes, err := c.store.Get(connIDKeyPrefix + connectionID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get connection record %s", connectionID)
+	}
+
+	record := &ConnectionRecord{}
+	if err := json.Unmarshal(recordBytes, record); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Connection Record Error")
+	}
+
+	return record, nil
+}
+
+// GetConnectionRecordByThreadID fetches the connection record for the given thread ID
+func (c *ConnectionStore) GetConnectionRecordByThreadID(threadID string) (*ConnectionRecord, error) {
+	return c.getByIndex(threadIDKeyPrefix, threadID)
+}
+
+// GetConnectionRecordByTheirDID fetches the connection record for the given DID of the other party,
+// the most recently updated one is returned if there are several connections with the same DID
+func (c *ConnectionStore) GetConnectionRecordByTheirDID(theirDID string) (*ConnectionRecord, error) {
+	records, err := c.GetConnectionRecordsByTheirDID(theirDID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.Wrapf(storage.ErrDataNotFound, "no connection record for their did %s", theirDID)
+	}
+
+	latest := records[0]
+	for _, record := range records[1:] {
+		if record.UpdatedTime.After(latest.UpdatedTime) {
+			latest = record
+		}
+	}
+
+	return latest, nil
+}
+
+// GetConnectionRecordsByTheirDID fetches all the connection records for the given DID of the other party
+func (c *ConnectionStore) GetConnectionRecordsByTheirDID(theirDID string) ([]*ConnectionRecord, error) {
+	connectionIDs, err := c.indexedIDs(theirDIDKeyPrefix + theirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*ConnectionRecord, 0, len(connectionIDs))
+	for _, connectionID := range connectionIDs {
+		record, e := c.GetConnectionRecord(connectionID)
+		if e != nil {
+			return nil, e
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// GetConnectionRecordByTheirKey fetches the connection record of the other party holding one of the given keys
+// (see TheirKeys), the most recently updated one is returned if there are several
+func (c *ConnectionStore) GetConnectionRecordByTheirKey(keys ...string) (*ConnectionRecord, error) {
+	var connectionIDs []string
+	for _, key := range keys {
+		ids, err := c.indexedIDs(theirKeyKeyPrefix + key)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !contains(connectionIDs, id) {
+				connectionIDs = append(connectionIDs, id)
+			}
+		}
+	}
+
+	var latest *ConnectionRecord
+	for _, connectionID := range connectionIDs {
+		record, err := c.GetConnectionRecord(connectionID)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || record.UpdatedTime.After(latest.UpdatedTime) {
+			latest = record
+		}
+	}
+	if latest == nil {
+		return nil, errors.Wrapf(storage.ErrDataNotFound, "no connection record for their keys %v", keys)
+	}
+
+	return latest, nil
+}
+
+// QueryConnectionRecords fetches all the connection records in the given state,
+// all the connection records are returned if state is empty
+func (c *ConnectionStore) QueryConnectionRecords(state string) ([]*ConnectionRecord, error) {
+	var records []*ConnectionRecord
+	err := c.store.Iterate(connIDKeyPrefix, func(k string, v []byte) error {
+		record := &ConnectionRecord{}
+		if err := json.Unmarshal(v, record); err != nil {
+			return errors.Wrapf(err, "Unmarshal Connection Record Error")
+		}
+		if state == "" || record.State == state {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// deleteConnectionRecord deletes the connection record along with its indexes
+func (c *ConnectionStore) deleteConnectionRecord(record *ConnectionRecord) error {
+	if err := c.deleteIndex(threadIDKeyPrefix, record.ThreadID, ""); err != nil {
+		return err
+	}
+	if record.TheirDID != "" {
+		if err := c.removeFromIndex(theirDIDKeyPrefix+record.TheirDID, record.ConnectionID); err != nil {
+			return err
+		}
+	}
+	if err := c.removeFromKeyIndexes(record, nil); err != nil {
+		return err
+	}
+
+	return c.store.Delete(connIDKeyPrefix + record.ConnectionID)
+}
+
+// index indexes the connection record by thread ID, a single connection has a thread ID,
+// and by their DID and their keys, several connections can be made with the same DID and keys
+func (c *ConnectionStore) index(record *ConnectionRecord) error {
+	if record.ThreadID != "" {
+		if err := c.store.Put(threadIDKeyPrefix+record.ThreadID, []byte(record.ConnectionID)); err != nil {
+			return errors.Wrapf(err, "failed to index connection record %s by thread id", record.ConnectionID)
+		}
+	}
+	if record.TheirDID != "" {
+		if err := c.addToIndex(theirDIDKeyPrefix+record.TheirDID, record.ConnectionID); err != nil {
+			return errors.Wrapf(err, "failed to index connection record %s by their did", record.ConnectionID)
+		}
+	}
+	for _, key := range record.TheirKeys() {
+		if err := c.addToIndex(theirKeyKeyPrefix+key, record.ConnectionID); err != nil {
+			return errors.Wrapf(err, "failed to index connection record %s by their key", record.ConnectionID)
+		}
+	}
+
+	return nil
+}
+
+// dropStaleIndexes drops the indexes of the previous version of the record that no longer apply
+func (c *ConnectionStore) dropStaleIndexes(record *ConnectionRecord) error {
+	previous, err := c.GetConnectionRecord(record.ConnectionID)
+	if errors.Cause(err) == storage.ErrDataNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if e := c.deleteIndex(threadIDKeyPrefix, previous.ThreadID, record.ThreadID); e != nil {
+		return e
+	}
+	if previous.TheirDID != "" && previous.TheirDID != record.TheirDID {
+		if e := c.removeFromIndex(theirDIDKeyPrefix+previous.TheirDID, record.ConnectionID); e != nil {
+			return e
+		}
+	}
+
+	return c.removeFromKeyIndexes(previous, record.TheirKeys())
+}
+
+// removeFromKeyIndexes removes the connection record from the indexes of its keys but the ones kept
+func (c *ConnectionStore) removeFromKeyIndexes(record *ConnectionRecord, kept []string) error {
+	for _, key := range record.TheirKeys() {
+		if contains(kept, key) {
+			continue
+		}
+		if err := c.removeFromIndex(theirKeyKeyPrefix+key, record.ConnectionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ConnectionStore) getByIndex(prefix, value string) (*ConnectionRecord, error) {
+	connectionID, err := c.store.Get(prefix + value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get connection id for %s%s", prefix, value)
+	}
+
+	return c.GetConnectionRecord(string(connectionID))
+}
+
+// deleteIndex deletes the index entry for the previous value if it changed
+func (c *ConnectionStore) deleteIndex(prefix, previous, current string) error {
+	if previous == "" || previous == current {
+		return nil
+	}
+
+	return c.store.Delete(prefix + previous)
+}
+
+// indexedIDs returns the connection IDs of the multi-valued index entry with the given key
+func (c *ConnectionStore) indexedIDs(key string) ([]string, error) {
+	idsBytes, err := c.store.Get(key)
+	if errors.Cause(err) == storage.ErrDataNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get connection ids for %s", key)
+	}
+
+	var connectionIDs []string
+	if e := json.Unmarshal(idsBytes, &connectionIDs); e != nil {
+		return nil, errors.Wrapf(e, "Unmarshal Connection IDs Error")
+	}
+
+	return connectionIDs, nil
+}
+
+// addToIndex adds the connection ID to the multi-valued index entry with the given key
+func (c *ConnectionStore) addToIndex(key, connectionID string) error {
+	connectionIDs, err := c.indexedIDs(key)
+	if err != nil {
+		return err
+	}
+	if contains(connectionIDs, connectionID) {
+		return nil
+	}
+
+	return c.putIndexedIDs(key, append(connectionIDs, connectionID))
+}
+
+// removeFromIndex removes the connection ID from the multi-valued index entry with the given key
+func (c *ConnectionStore) removeFromIndex(key, connectionID string) error {
+	connectionIDs, err := c.indexedIDs(key)
+	if err != nil {
+		return err
+	}
+
+	var remaining []string
+	for _, id := range connectionIDs {
+		if id != connectionID {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		return c.store.Delete(key)
+	}
+
+	return c.putIndexedIDs(key, remaining)
+}
+
+func (c *ConnectionStore) putIndexedIDs(key string, connectionIDs []string) error {
+	idsBytes, err := json.Marshal(connectionIDs)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Connection IDs Error")
+	}
+
+	return c.store.Put(key, idsBytes)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/did-common-go/pkg/diddoc"
+)
+
+func TestConnectionStore(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	record := &ConnectionRecord{
+		ConnectionID:  "conn1",
+		ThreadID:      "thread1",
+		State:         StateIDRequested,
+		TheirDID:      "did:example:bob",
+		RecipientKeys: []string{"8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K"},
+	}
+	require.NoError(t, store.SaveConnectionRecord(record))
+	require.False(t, record.CreatedTime.IsZero())
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{
+		ConnectionID: "conn2",
+		ThreadID:     "thread2",
+		State:        StateIDCompleted,
+	}))
+
+	result, err := store.GetConnectionRecord("conn1")
+	require.NoError(t, err)
+	require.Equal(t, record.RecipientKeys, result.RecipientKeys)
+
+	result, err = store.GetConnectionRecordByThreadID("thread1")
+	require.NoError(t, err)
+	require.Equal(t, "conn1", result.ConnectionID)
+
+	result, err = store.GetConnectionRecordByTheirDID("did:example:bob")
+	require.NoError(t, err)
+	require.Equal(t, "conn1", result.ConnectionID)
+
+	records, err := store.QueryConnectionRecords(StateIDRequested)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	records, err = store.QueryConnectionRecords("")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	// updating the record moves its indexes
+	createdTime := record.CreatedTime
+	record.ThreadID = "thread3"
+	record.TheirDID = "did:example:carol"
+	record.State = StateIDResponded
+	require.NoError(t, store.SaveConnectionRecord(record))
+	require.Equal(t, createdTime, record.CreatedTime)
+
+	_, err = store.GetConnectionRecordByThreadID("thread1")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+	_, err = store.GetConnectionRecordByTheirDID("did:example:bob")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+
+	result, err = store.GetConnectionRecordByThreadID("thread3")
+	require.NoError(t, err)
+	require.Equal(t, StateIDResponded, result.State)
+
+	records, err = store.QueryConnectionRecords(StateIDRequested)
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func TestConnectionStore_SameTheirDID(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	// connections made by reusing an invitation with the same party
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn1", TheirDID: "did:example:bob"}))
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn2", TheirDID: "did:example:bob"}))
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn2", TheirDID: "did:example:bob",
+		State: StateIDCompleted}))
+
+	records, err := store.GetConnectionRecordsByTheirDID("did:example:bob")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "conn1", records[0].ConnectionID)
+	require.Equal(t, "conn2", records[1].ConnectionID)
+
+	result, err := store.GetConnectionRecordByTheirDID("did:example:bob")
+	require.NoError(t, err)
+	require.Equal(t, "conn2", result.ConnectionID)
+
+	// the other connections stay indexed when one of them moves to another DID
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn2", TheirDID: "did:example:carol"}))
+	records, err = store.GetConnectionRecordsByTheirDID("did:example:bob")
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, "conn1", records[0].ConnectionID)
+
+	records, err = store.GetConnectionRecordsByTheirDID("did:example:dave")
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func TestConnectionStore_TheirKey(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	didDoc := &diddoc.DIDDoc{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "did:example:bob", "publicKey": [
+		{"id": "did:example:bob#key-1", "type": "Ed25519VerificationKey2018", "publicKeyBase58": "bob-doc-key"},
+		{"id": "did:example:bob#key-2", "type": "Ed25519VerificationKey2018", "publicKeyBase58": "bob-key"}]}`),
+		didDoc))
+	bob := &ConnectionRecord{ConnectionID: "conn1", RecipientKeys: []string{"bob-key"}, TheirDIDDoc: didDoc}
+	require.Equal(t, []string{"bob-key", "bob-doc-key"}, bob.TheirKeys())
+	require.NoError(t, store.SaveConnectionRecord(bob))
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn2",
+		RecipientKeys: []string{"carol-key"}}))
+
+	for _, key := range []string{"bob-key", "bob-doc-key"} {
+		record, e := store.GetConnectionRecordByTheirKey("unknown", key)
+		require.NoError(t, e)
+		require.Equal(t, "conn1", record.ConnectionID)
+	}
+
+	// the most recently updated connection is returned
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn3",
+		RecipientKeys: []string{"bob-key"}}))
+	record, err := store.GetConnectionRecordByTheirKey("bob-key")
+	require.NoError(t, err)
+	require.Equal(t, "conn3", record.ConnectionID)
+
+	_, err = store.GetConnectionRecordByTheirKey("unknown")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+	_, err = store.GetConnectionRecordByTheirKey()
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+
+	// the keys no longer held aren't indexed
+	bob.RecipientKeys = []string{"bob-new-key"}
+	bob.TheirDIDDoc = nil
+	require.NoError(t, store.SaveConnectionRecord(bob))
+	_, err = store.GetConnectionRecordByTheirKey("bob-doc-key")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+	record, err = store.GetConnectionRecordByTheirKey("bob-new-key")
+	require.NoError(t, err)
+	require.Equal(t, "conn1", record.ConnectionID)
+
+	// nor the ones of deleted records
+	require.NoError(t, store.deleteConnectionRecord(bob))
+	_, err = store.GetConnectionRecordByTheirKey("bob-new-key")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+	record, err = store.GetConnectionRecordByTheirKey("bob-key")
+	require.NoError(t, err)
+	require.Equal(t, "conn3", record.ConnectionID)
+
+	// the other records aren't read
+	memStore := memstore.NewStore()
+	store, err = NewConnectionStore(memStore)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn1",
+		RecipientKeys: []string{"bob-key"}}))
+	require.NoError(t, memStore.Put(connIDKeyPrefix+"invalid", []byte("invalid json")))
+	record, err = store.GetConnectionRecordByTheirKey("bob-key")
+	require.NoError(t, err)
+	require.Equal(t, "conn1", record.ConnectionID)
+}
+
+func TestConnectionStore_Errors(t *testing.T) {
+	_, err := NewConnectionStore(nil)
+	require.Error(t, err)
+
+	memStore := memstore.NewStore()
+	store, err := NewConnectionStore(memStore)
+	require.NoError(t, err)
+
+	require.Error(t, store.SaveConnectionRecord(nil))
+	require.Error(t, store.SaveConnectionRecord(&ConnectionRecord{}))
+
+	_, err = store.GetConnectionRecord("unknown")
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+
+	require.NoError(t, memStore.Put(connIDKeyPrefix+"invalid", []byte("invalid json")))
+	_, err = store.GetConnectionRecord("invalid")
+	require.Error(t, err)
+	_, err = store.QueryConnectionRecords("")
+	require.Error(t, err)
+	require.Error(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "invalid"}))
+
+	store, err = NewConnectionStore(&failingStore{Store: memstore.NewStore()})
+	require.NoError(t, err)
+	require.Error(t, store.SaveConnectionRecord(&ConnectionRecord{ConnectionID: "conn1"}))
+}
+
+type failingStore struct {
+	storage.Store
+}
+
+func (s *failingStore) Put(k string, v []byte) error {
+	return errors.New("put error")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// ServiceName name of the DID exchange protocol service
+const ServiceName = "connections"
+
+// Service DID exchange protocol service, plugs the exchange state machine into the dispatcher
+type Service struct {
+	*Exchange
+}
+
+// NewService creates a new DID exchange protocol service
+func NewService(transport transport.OutboundTransport, store *ConnectionStore, opts ...ExchangeOpt) (*Service, error) {
+	exchange, err := NewExchange(transport, store, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{Exchange: exchange}, nil
+}
+
+// Name returns the name of the DID exchange protocol service
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// MsgTypes returns the message types accepted by the DID exchange protocol service
+func (s *Service) MsgTypes() []string {
+	return []string{MsgTypePrefix}
+}
+
+// HandleOutbound sends the exchange request, response or ack to the destination
+func (s *Service) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	switch m := msg.(type) {
+	case *didexchange.Request:
+		return s.SendExchangeRequest(m, destination)
+	case *didexchange.Response:
+		return s.SendExchangeResponse(m, destination)
+	case *didexchange.Ack:
+		return s.SendExchangeAck(m, destination)
+	default:
+		return errors.Errorf("unsupported exchange message %T", msg)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+func TestService(t *testing.T) {
+	store, err := NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	svc, err := NewService(mock.NewOutboundTransport(successResponse), store)
+	require.NoError(t, err)
+	require.Equal(t, ServiceName, svc.Name())
+
+	d := dispatcher.New()
+	require.NoError(t, d.RegisterService(svc))
+
+	// inbound request dispatched to the service
+	require.NoError(t, d.Dispatch(toBytes(t, &didexchange.Request{
+		Type:  connectionRequest,
+		ID:    requestID,
+		Label: "Bob",
+	})))
+	require.Equal(t, StateIDRequested, svc.State(requestID))
+
+	dest := newDestination(t)
+	require.NoError(t, d.Send(ServiceName,
+		&didexchange.Response{ID: "response-id", Thread: &didexchange.Thread{ID: requestID}}, dest))
+	require.Equal(t, StateIDResponded, svc.State(requestID))
+
+	// outbound request of the invitee
+	require.NoError(t, svc.HandleOutbound(&didexchange.Request{ID: "other-request"}, dest))
+	require.Equal(t, StateIDRequested, svc.State("other-request"))
+
+	// unsupported message and missing destination
+	require.Error(t, svc.HandleOutbound(&didexchange.InviteMessage{}, dest))
+	require.Error(t, svc.HandleOutbound(&didexchange.Ack{}, nil))
+	require.Error(t, svc.HandleOutbound(&didexchange.Ack{}, &dispatcher.Destination{}))
+
+	// invalid store
+	_, err = NewService(mock.NewOutboundTransport(successResponse), nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/internal/base64url"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// signatureType connection~sig signature type
+	// https://github.com/hyperledger/aries-rfcs/tree/master/features/0160-connection-protocol#connection-response
+	signatureType = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/signature/1.0/ed25519Sha512_single"
+
+	// timestampSize size of the timestamp prefixing the signed connection
+	timestampSize = 8
+)
+
+// InvalidSignatureError is returned when the connection~sig of an exchange response fails verification
+type InvalidSignatureError struct {
+	reason string
+}
+
+// Error returns the reason the signature is invalid
+func (e *InvalidSignatureError) Error() string {
+	return "invalid connection signature: " + e.reason
+}
+
+func invalidSignature(format string, args ...interface{}) error {
+	return &InvalidSignatureError{reason: fmt.Sprintf(format, args...)}
+}
+
+// SignExchangeResponse signs the connection with the given ed25519 key pair (the invitation's recipient key)
+// and sets it as the connection~sig of the exchange response
+func SignExchangeResponse(exchangeResponse *didexchange.Response, connection *didexchange.Connection,
+	verKey, secret []byte) error {
+	if exchangeResponse == nil {
+		return errors.New("exchangeResponse cannot be nil")
+	}
+
+	connectionSignature, err := SignConnection(connection, verKey, secret)
+	if err != nil {
+		return err
+	}
+	exchangeResponse.ConnectionSignature = connectionSignature
+
+	return nil
+}
+
+// SignConnection signs the current timestamp followed by the connection with the given ed25519 key pair
+func SignConnection(connection *didexchange.Connection,
+	verKey, secret []byte) (*didexchange.ConnectionSignature, error) {
+	if connection == nil {
+		return nil, errors.New("connection cannot be nil")
+	}
+	if len(verKey) != ed25519.PublicKeySize || len(secret) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 key pair")
+	}
+
+	connectionJSON, err := json.Marshal(connection)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Connection Error")
+	}
+
+	signedData := make([]byte, timestampSize, timestampSize+len(connectionJSON))
+	binary.BigEndian.PutUint64(signedData, uint64(time.Now().Unix()))
+	signedData = append(signedData, connectionJSON...)
+
+	return &didexchange.ConnectionSignature{
+		Type:       signatureType,
+		SignedData: base64.URLEncoding.EncodeToString(signedData),
+		Signature:  base64.URLEncoding.EncodeToString(ed25519.Sign(secret, signedData)),
+		SignVerKey: base58.Encode(verKey),
+	}, nil
+}
+
+// VerifyConnectionSignature verifies the connection~sig and returns the signed connection,
+// an InvalidSignatureError is returned if the signature can't be verified
+func VerifyConnectionSignature(
+	connectionSignature *didexchange.ConnectionSignature) (*didexchange.Connection, error) {
+	if connectionSignature == nil {
+		return nil, invalidSignature("missing connection~sig")
+	}
+	if connectionSignature.Type != signatureType {
+		return nil, invalidSignature("unsupported signature type %s", connectionSignature.Type)
+	}
+
+	verKey := base58.Decode(connectionSignature.SignVerKey)
+	if len(verKey) != ed25519.PublicKeySize {
+		return nil, invalidSignature("invalid signer key %s", connectionSignature.SignVerKey)
+	}
+
+	signedData, err := base64url.Decode(connectionSignature.SignedData)
+	if err != nil {
+		return nil, invalidSignature("invalid signed data encoding: %s", err)
+	}
+
+	signature, err := base64url.Decode(connectionSignature.Signature)
+	if err != nil {
+		return nil, invalidSignature("invalid signature encoding: %s", err)
+	}
+
+	if !ed25519.Verify(verKey, signedData, signature) {
+		return nil, invalidSignature("signature verification failed")
+	}
+
+	return unpackSignedConnection(connectionSignature)
+}
+
+// unpackSignedConnection returns the connection out of the signed data without verifying the signature
+func unpackSignedConnection(connectionSignature *didexchange.ConnectionSignature) (*didexchange.Connection, error) {
+	signedData, err := base64url.Decode(connectionSignature.SignedData)
+	if err != nil {
+		return nil, invalidSignature("invalid signed data encoding: %s", err)
+	}
+	if len(signedData) <= timestampSize {
+		return nil, invalidSignature("signed data is too short")
+	}
+
+	connection := &didexchange.Connection{}
+	if err := json.Unmarshal(signedData[timestampSize:], connection); err != nil {
+		return nil, invalidSignature("invalid signed connection: %s", err)
+	}
+
+	return connection, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignAndVerifyConnection(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	connection := &didexchange.Connection{DID: "did:example:alice"}
+
+	resp := &didexchange.Response{ID: "response-id"}
+	require.NoError(t, SignExchangeResponse(resp, connection, pubKey, privKey))
+	require.Equal(t, signatureType, resp.ConnectionSignature.Type)
+	require.Equal(t, base58.Encode(pubKey), resp.ConnectionSignature.SignVerKey)
+
+	signedConnection, err := VerifyConnectionSignature(resp.ConnectionSignature)
+	require.NoError(t, err)
+	require.Equal(t, connection, signedConnection)
+
+	require.Error(t, SignExchangeResponse(nil, connection, pubKey, privKey))
+	require.Error(t, SignExchangeResponse(resp, nil, pubKey, privKey))
+	require.Error(t, SignExchangeResponse(resp, connection, pubKey[1:], privKey))
+}
+
+func TestVerifyConnectionSignature_Invalid(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	newSignature := func() *didexchange.ConnectionSignature {
+		sig, e := SignConnection(&didexchange.Connection{DID: "did:example:alice"}, pubKey, privKey)
+		require.NoError(t, e)
+		return sig
+	}
+
+	tcs := []struct {
+		name   string
+		modify func(sig *didexchange.ConnectionSignature)
+		reason string
+	}{
+		{
+			name:   "unsupported type",
+			modify: func(sig *didexchange.ConnectionSignature) { sig.Type = "invalid" },
+			reason: "unsupported signature type",
+		},
+		{
+			name:   "invalid signer key",
+			modify: func(sig *didexchange.ConnectionSignature) { sig.SignVerKey = "invalid" },
+			reason: "invalid signer key",
+		},
+		{
+			name:   "other signer key",
+			modify: func(sig *didexchange.ConnectionSignature) { sig.SignVerKey = base58.Encode(otherPubKey) },
+			reason: "signature verification failed",
+		},
+		{
+			name:   "invalid signed data encoding",
+			modify: func(sig *didexchange.ConnectionSignature) { sig.SignedData = "!!!" },
+			reason: "invalid signed data encoding",
+		},
+		{
+			name:   "invalid signature encoding",
+			modify: func(sig *didexchange.ConnectionSignature) { sig.Signature = "!!!" },
+			reason: "invalid signature encoding",
+		},
+		{
+			name: "tampered signed data",
+			modify: func(sig *didexchange.ConnectionSignature) {
+				sig.SignedData = base64.URLEncoding.EncodeToString([]byte("12345678{}"))
+			},
+			reason: "signature verification failed",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			sig := newSignature()
+			tc.modify(sig)
+
+			connection, err := VerifyConnectionSignature(sig)
+			require.IsType(t, &InvalidSignatureError{}, err)
+			require.Contains(t, err.Error(), tc.reason)
+			require.Nil(t, connection)
+		})
+	}
+
+	connection, err := VerifyConnectionSignature(nil)
+	require.IsType(t, &InvalidSignatureError{}, err)
+	require.Nil(t, connection)
+}
+
+func TestUnpackSignedConnection_Invalid(t *testing.T) {
+	_, err := unpackSignedConnection(&didexchange.ConnectionSignature{SignedData: "!!!"})
+	require.IsType(t, &InvalidSignatureError{}, err)
+
+	_, err = unpackSignedConnection(&didexchange.ConnectionSignature{
+		SignedData: base64.URLEncoding.EncodeToString([]byte("1234")),
+	})
+	require.IsType(t, &InvalidSignatureError{}, err)
+
+	_, err = unpackSignedConnection(&didexchange.ConnectionSignature{
+		SignedData: base64.URLEncoding.EncodeToString([]byte("12345678{")),
+	})
+	require.IsType(t, &InvalidSignatureError{}, err)
+}
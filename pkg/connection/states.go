@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// StateIDNull connection state before any exchange message was sent or received
+	StateIDNull = "null"
+	// StateIDInvited connection state once an invitation was sent or received
+	StateIDInvited = "invited"
+	// StateIDRequested connection state once an exchange request was sent or received
+	StateIDRequested = "requested"
+	// StateIDResponded connection state once an exchange response was sent or received
+	StateIDResponded = "responded"
+	// StateIDCompleted connection state once the exchange was acknowledged
+	StateIDCompleted = "completed"
+	// StateIDAbandoned connection state once the exchange was given up by either party
+	StateIDAbandoned = "abandoned"
+)
+
+// state of a DID exchange
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0023-did-exchange#states
+type state interface {
+	// Name of this state
+	Name() string
+	// CanTransitionTo returns true if the exchange can move from this state to the next one
+	CanTransitionTo(next state) bool
+}
+
+// null state
+type null struct{}
+
+func (s *null) Name() string {
+	return StateIDNull
+}
+
+func (s *null) CanTransitionTo(next state) bool {
+	// requested is allowed for the inviter side of an implicit (public DID) invitation
+	return next.Name() == StateIDInvited || next.Name() == StateIDRequested
+}
+
+// invited state
+type invited struct{}
+
+func (s *invited) Name() string {
+	return StateIDInvited
+}
+
+func (s *invited) CanTransitionTo(next state) bool {
+	return next.Name() == StateIDRequested || next.Name() == StateIDAbandoned
+}
+
+// requested state
+type requested struct{}
+
+func (s *requested) Name() string {
+	return StateIDRequested
+}
+
+func (s *requested) CanTransitionTo(next state) bool {
+	return next.Name() == StateIDResponded || next.Name() == StateIDAbandoned
+}
+
+// responded state
+type responded struct{}
+
+func (s *responded) Name() string {
+	return StateIDResponded
+}
+
+func (s *responded) CanTransitionTo(next state) bool {
+	return next.Name() == StateIDCompleted || next.Name() == StateIDAbandoned
+}
+
+// completed state
+type completed struct{}
+
+func (s *completed) Name() string {
+	return StateIDCompleted
+}
+
+func (s *completed) CanTransitionTo(next state) bool {
+	return false
+}
+
+// abandoned state
+type abandoned struct{}
+
+func (s *abandoned) Name() string {
+	return StateIDAbandoned
+}
+
+func (s *abandoned) CanTransitionTo(next state) bool {
+	return false
+}
+
+// stateFromName returns the state for the given state ID
+func stateFromName(name string) (state, error) {
+	switch name {
+	case StateIDNull:
+		return &null{}, nil
+	case StateIDInvited:
+		return &invited{}, nil
+	case StateIDRequested:
+		return &requested{}, nil
+	case StateIDResponded:
+		return &responded{}, nil
+	case StateIDCompleted:
+		return &completed{}, nil
+	case StateIDAbandoned:
+		return &abandoned{}, nil
+	default:
+		return nil, errors.Errorf("invalid state name %s", name)
+	}
+}
+
+// stateFromMsgType returns the state a connection moves to when the given message type is sent or received
+func stateFromMsgType(msgType string) (state, error) {
+	switch msgType {
+	case connectionInvite:
+		return &invited{}, nil
+	case connectionRequest:
+		return &requested{}, nil
+	case connectionResponse:
+		return &responded{}, nil
+	case connectionAck:
+		return &completed{}, nil
+	default:
+		return nil, errors.Errorf("unrecognized msgType: %s", msgType)
+	}
+}
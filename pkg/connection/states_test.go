@@ -0,0 +1,102 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNullState(t *testing.T) {
+	n := &null{}
+	require.Equal(t, StateIDNull, n.Name())
+	require.False(t, n.CanTransitionTo(&null{}))
+	require.True(t, n.CanTransitionTo(&invited{}))
+	require.True(t, n.CanTransitionTo(&requested{}))
+	require.False(t, n.CanTransitionTo(&responded{}))
+	require.False(t, n.CanTransitionTo(&completed{}))
+	require.False(t, n.CanTransitionTo(&abandoned{}))
+}
+
+func TestInvitedState(t *testing.T) {
+	inv := &invited{}
+	require.Equal(t, StateIDInvited, inv.Name())
+	require.False(t, inv.CanTransitionTo(&null{}))
+	require.False(t, inv.CanTransitionTo(&invited{}))
+	require.True(t, inv.CanTransitionTo(&requested{}))
+	require.False(t, inv.CanTransitionTo(&responded{}))
+	require.False(t, inv.CanTransitionTo(&completed{}))
+	require.True(t, inv.CanTransitionTo(&abandoned{}))
+}
+
+func TestRequestedState(t *testing.T) {
+	req := &requested{}
+	require.Equal(t, StateIDRequested, req.Name())
+	require.False(t, req.CanTransitionTo(&null{}))
+	require.False(t, req.CanTransitionTo(&invited{}))
+	require.False(t, req.CanTransitionTo(&requested{}))
+	require.True(t, req.CanTransitionTo(&responded{}))
+	require.False(t, req.CanTransitionTo(&completed{}))
+	require.True(t, req.CanTransitionTo(&abandoned{}))
+}
+
+func TestRespondedState(t *testing.T) {
+	res := &responded{}
+	require.Equal(t, StateIDResponded, res.Name())
+	require.False(t, res.CanTransitionTo(&null{}))
+	require.False(t, res.CanTransitionTo(&invited{}))
+	require.False(t, res.CanTransitionTo(&requested{}))
+	require.False(t, res.CanTransitionTo(&responded{}))
+	require.True(t, res.CanTransitionTo(&completed{}))
+	require.True(t, res.CanTransitionTo(&abandoned{}))
+}
+
+func TestCompletedAndAbandonedStates(t *testing.T) {
+	for _, s := range []state{&completed{}, &abandoned{}} {
+		for _, next := range []state{&null{}, &invited{}, &requested{}, &responded{}, &completed{}, &abandoned{}} {
+			require.False(t, s.CanTransitionTo(next))
+		}
+	}
+	require.Equal(t, StateIDCompleted, (&completed{}).Name())
+	require.Equal(t, StateIDAbandoned, (&abandoned{}).Name())
+}
+
+func TestStateFromName(t *testing.T) {
+	for _, name := range []string{StateIDNull, StateIDInvited, StateIDRequested, StateIDResponded,
+		StateIDCompleted, StateIDAbandoned} {
+		s, err := stateFromName(name)
+		require.NoError(t, err)
+		require.Equal(t, name, s.Name())
+	}
+
+	s, err := stateFromName("unknown")
+	require.Error(t, err)
+	require.Nil(t, s)
+}
+
+func TestStateFromMsgType(t *testing.T) {
+	s, err := stateFromMsgType(connectionInvite)
+	require.NoError(t, err)
+	require.Equal(t, StateIDInvited, s.Name())
+
+	s, err = stateFromMsgType(connectionRequest)
+	require.NoError(t, err)
+	require.Equal(t, StateIDRequested, s.Name())
+
+	s, err = stateFromMsgType(connectionResponse)
+	require.NoError(t, err)
+	require.Equal(t, StateIDResponded, s.Name())
+
+	s, err = stateFromMsgType(connectionAck)
+	require.NoError(t, err)
+	require.Equal(t, StateIDCompleted, s.Name())
+
+	s, err = stateFromMsgType("unknown")
+	require.Error(t, err)
+	require.Nil(t, s)
+}
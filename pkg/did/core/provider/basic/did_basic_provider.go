@@ -7,12 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package didbasic
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
-	"reflect"
 	"sync"
-	"time"
 
+	"github.com/pkg/errors"
 	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
 )
 
@@ -31,19 +30,18 @@ func NewProvider() *Provider {
 
 // CreateLocalDID create a new DID along with keypair and stores info along with metadata.
 func (prov *Provider) CreateLocalDID(metadata map[string]interface{}) (*didprovider.LocalDIDInfo, error) {
-	didInfo := &didprovider.LocalDIDInfo{
-		// (TO-DO) : generate unique DID
-		DID: time.Now().String(),
-		// (TO-DO) : generate keypair
-		VerKey:   []byte(time.Now().String()),
-		Secret:   []byte(time.Now().String()),
-		Metadata: metadata,
+	didInfo, err := didprovider.NewLocalDIDInfo(metadata)
+	if err != nil {
+		return nil, err
 	}
 
 	// store DID LocalDIDInfo (in-memory)
 	prov.lock.Lock()
+	defer prov.lock.Unlock()
+	if _, ok := prov.store[didInfo.DID]; ok {
+		return nil, errors.Errorf("DID %s already exists", didInfo.DID)
+	}
 	prov.store[didInfo.DID] = didInfo
-	prov.lock.Unlock()
 
 	return didInfo, nil
 }
@@ -79,7 +77,7 @@ func (prov *Provider) GetLocalDIDBasedOnVerKey(verkey []byte) (*didprovider.Loca
 	defer prov.lock.RUnlock()
 
 	for _, value := range prov.store {
-		if reflect.DeepEqual(value.VerKey, verkey) {
+		if bytes.Equal(value.VerKey, verkey) {
 			return value, nil
 		}
 	}
@@ -7,9 +7,13 @@ SPDX-License-Identifier: Apache-2.0
 package didbasic
 
 import (
+	"sync"
 	"testing"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/stretchr/testify/require"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"golang.org/x/crypto/ed25519"
 )
 
 func TestBasicProvider(t *testing.T) {
@@ -41,3 +45,68 @@ func TestBasicProvider(t *testing.T) {
 	require.Error(t, err)
 
 }
+
+func TestBasicProvider_KeyPair(t *testing.T) {
+	didProv := NewProvider()
+
+	didInfo, err := didProv.CreateLocalDID(map[string]interface{}{"label": "Alice"})
+	require.NoError(t, err)
+	require.Len(t, didInfo.VerKey, ed25519.PublicKeySize)
+	require.Len(t, didInfo.Secret, ed25519.PrivateKeySize)
+	require.Equal(t, "did:sov:"+base58.Encode(didInfo.VerKey[:16]), didInfo.DID)
+	require.Equal(t, map[string]interface{}{"label": "Alice"}, didInfo.Metadata)
+
+	// secret signs for the verkey
+	signature := ed25519.Sign(didInfo.Secret, []byte("data"))
+	require.True(t, ed25519.Verify(didInfo.VerKey, []byte("data"), signature))
+}
+
+func TestBasicProvider_Concurrent(t *testing.T) {
+	didProv := NewProvider()
+
+	const count = 50
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := didProv.CreateLocalDID(nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	didInfoList, err := didProv.GetLocalDIDList()
+	require.NoError(t, err)
+	require.Len(t, didInfoList, count)
+}
+
+func TestBasicProvider_Seed(t *testing.T) {
+	const seed = "00000000000000000000000000000My1"
+
+	didInfo, err := NewProvider().CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: seed})
+	require.NoError(t, err)
+	require.Empty(t, didInfo.Metadata)
+
+	didProv := NewProvider()
+	sameDIDInfo, err := didProv.CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: []byte(seed)})
+	require.NoError(t, err)
+	require.Equal(t, didInfo.DID, sameDIDInfo.DID)
+	require.Equal(t, didInfo.VerKey, sameDIDInfo.VerKey)
+	require.Equal(t, didInfo.Secret, sameDIDInfo.Secret)
+
+	// DID already exists
+	_, err = didProv.CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: seed})
+	require.Error(t, err)
+
+	// invalid seed
+	_, err = didProv.CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: "short"})
+	require.Error(t, err)
+	_, err = didProv.CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: 1})
+	require.Error(t, err)
+}
@@ -0,0 +1,290 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package didfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	filestore "github.com/trustbloc/aries-framework-go/pkg/storage/file"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	didKeyPrefix    = "did_"
+	verKeyKeyPrefix = "verkey_"
+	saltKey         = "salt"
+	checkKey        = "check"
+
+	// checkValue is encrypted under the passphrase derived key to detect a wrong passphrase on open
+	checkValue = "aries-did-file-provider"
+
+	saltSize = 32
+	keySize  = 32
+
+	// scrypt parameters recommended for interactive logins
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// Provider DID provider persisting the local DIDs to a directory, secrets are encrypted at rest with AES-GCM
+// under a key derived from the passphrase with scrypt and DIDs are indexed by verkey
+type Provider struct {
+	store storage.Store
+	aead  cipher.AEAD
+	lock  sync.RWMutex
+}
+
+// didRecord local DID info as persisted by the provider
+type didRecord struct {
+	DID             string                 `json:"did"`
+	VerKey          []byte                 `json:"verKey"`
+	EncryptedSecret []byte                 `json:"encryptedSecret"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// NewProvider instance of file DID provider keeping its data under the given directory,
+// the same passphrase must be used every time the directory is opened
+func NewProvider(dir string, passphrase []byte) (*Provider, error) {
+	store, err := filestore.NewStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProviderFromStore(store, passphrase)
+}
+
+// NewProviderFromStore instance of DID provider persisting the local DIDs to the given store
+func NewProviderFromStore(store storage.Store, passphrase []byte) (*Provider, error) {
+	if store == nil {
+		return nil, errors.New("store is mandatory")
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is mandatory")
+	}
+
+	aead, err := openCipher(store, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{store: store, aead: aead}, nil
+}
+
+// CreateLocalDID create a new DID along with keypair and stores info along with metadata.
+func (prov *Provider) CreateLocalDID(metadata map[string]interface{}) (*didprovider.LocalDIDInfo, error) {
+	didInfo, err := didprovider.NewLocalDIDInfo(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedSecret, err := encrypt(prov.aead, didInfo.Secret, []byte(didInfo.DID))
+	if err != nil {
+		return nil, err
+	}
+
+	recordBytes, err := json.Marshal(&didRecord{
+		DID:             didInfo.DID,
+		VerKey:          didInfo.VerKey,
+		EncryptedSecret: encryptedSecret,
+		Metadata:        didInfo.Metadata,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal DID Record Error")
+	}
+
+	prov.lock.Lock()
+	defer prov.lock.Unlock()
+
+	_, err = prov.store.Get(didKeyPrefix + didInfo.DID)
+	if err == nil {
+		return nil, errors.Errorf("DID %s already exists", didInfo.DID)
+	}
+	if errors.Cause(err) != storage.ErrDataNotFound {
+		return nil, err
+	}
+
+	if err := prov.store.Put(didKeyPrefix+didInfo.DID, recordBytes); err != nil {
+		return nil, errors.Wrapf(err, "failed to save DID %s", didInfo.DID)
+	}
+	if err := prov.store.Put(verKeyKeyPrefix+base58.Encode(didInfo.VerKey), []byte(didInfo.DID)); err != nil {
+		return nil, errors.Wrapf(err, "failed to index DID %s by verkey", didInfo.DID)
+	}
+
+	return didInfo, nil
+}
+
+// GetLocalDIDInfo fetch DID info based on DID
+func (prov *Provider) GetLocalDIDInfo(did string) (*didprovider.LocalDIDInfo, error) {
+	prov.lock.RLock()
+	defer prov.lock.RUnlock()
+
+	recordBytes, err := prov.store.Get(didKeyPrefix + did)
+	if err != nil {
+		return nil, errors.Wrapf(err, "No Local DID Info found for DID %s", did)
+	}
+
+	return prov.decodeRecord(recordBytes)
+}
+
+// GetLocalDIDList fetches all the stored DID LocalDIDInfo
+func (prov *Provider) GetLocalDIDList() ([]*didprovider.LocalDIDInfo, error) {
+	prov.lock.RLock()
+	defer prov.lock.RUnlock()
+
+	var dids []*didprovider.LocalDIDInfo
+	err := prov.store.Iterate(didKeyPrefix, func(k string, v []byte) error {
+		didInfo, err := prov.decodeRecord(v)
+		if err != nil {
+			return err
+		}
+		dids = append(dids, didInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dids, nil
+}
+
+// GetLocalDIDBasedOnVerKey fetch DID info based on VerKey
+func (prov *Provider) GetLocalDIDBasedOnVerKey(verkey []byte) (*didprovider.LocalDIDInfo, error) {
+	prov.lock.RLock()
+	defer prov.lock.RUnlock()
+
+	did, err := prov.store.Get(verKeyKeyPrefix + base58.Encode(verkey))
+	if err != nil {
+		return nil, errors.Wrapf(err, "No Local DID Info found for VerKey")
+	}
+
+	recordBytes, err := prov.store.Get(didKeyPrefix + string(did))
+	if err != nil {
+		return nil, errors.Wrapf(err, "No Local DID Info found for DID %s", did)
+	}
+
+	return prov.decodeRecord(recordBytes)
+}
+
+// decodeRecord unmarshals the persisted DID record and decrypts its secret
+func (prov *Provider) decodeRecord(recordBytes []byte) (*didprovider.LocalDIDInfo, error) {
+	record := &didRecord{}
+	if err := json.Unmarshal(recordBytes, record); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal DID Record Error")
+	}
+
+	secret, err := decrypt(prov.aead, record.EncryptedSecret, []byte(record.DID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt secret of DID %s", record.DID)
+	}
+
+	return &didprovider.LocalDIDInfo{
+		DID:      record.DID,
+		VerKey:   record.VerKey,
+		Secret:   secret,
+		Metadata: record.Metadata,
+	}, nil
+}
+
+// openCipher derives the encryption key from the passphrase and the salt of the store,
+// the salt is generated the first time the store is opened
+func openCipher(store storage.Store, passphrase []byte) (cipher.AEAD, error) {
+	salt, err := store.Get(saltKey)
+	if errors.Cause(err) == storage.ErrDataNotFound {
+		return initCipher(store, passphrase)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read salt")
+	}
+
+	aead, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := store.Get(checkKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read passphrase check")
+	}
+
+	value, err := decrypt(aead, check, nil)
+	if err != nil || !bytes.Equal(value, []byte(checkValue)) {
+		return nil, errors.New("invalid passphrase")
+	}
+
+	return aead, nil
+}
+
+// initCipher generates the salt of a new store along with the encrypted passphrase check
+func initCipher(store storage.Store, passphrase []byte) (cipher.AEAD, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate salt")
+	}
+
+	aead, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := encrypt(aead, []byte(checkValue), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Put(checkKey, check); err != nil {
+		return nil, errors.Wrapf(err, "failed to save passphrase check")
+	}
+	if err := store.Put(saltKey, salt); err != nil {
+		return nil, errors.Wrapf(err, "failed to save salt")
+	}
+
+	return aead, nil
+}
+
+func newCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to derive key from passphrase")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals the plaintext with a random nonce prepended to the ciphertext
+func encrypt(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate nonce")
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonceSize := aead.NonceSize()
+	return aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], additionalData)
+}
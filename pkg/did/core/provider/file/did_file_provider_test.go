@@ -0,0 +1,123 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package didfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+var passphrase = []byte("passphrase")
+
+func TestFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "didfile")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	didProv, err := NewProvider(dir, passphrase)
+	require.NoError(t, err)
+
+	didInfo, err := didProv.CreateLocalDID(map[string]interface{}{"label": "Alice"})
+	require.NoError(t, err)
+	require.NotEmpty(t, didInfo.DID)
+
+	_, err = didProv.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	// DIDs survive a restart
+	didProv, err = NewProvider(dir, passphrase)
+	require.NoError(t, err)
+
+	didInfoBasedOnDID, err := didProv.GetLocalDIDInfo(didInfo.DID)
+	require.NoError(t, err)
+	require.Equal(t, didInfo, didInfoBasedOnDID)
+
+	didInfoList, err := didProv.GetLocalDIDList()
+	require.NoError(t, err)
+	require.Len(t, didInfoList, 2)
+
+	didInfoBasedOnVerKey, err := didProv.GetLocalDIDBasedOnVerKey(didInfo.VerKey)
+	require.NoError(t, err)
+	require.Equal(t, didInfo, didInfoBasedOnVerKey)
+
+	_, err = didProv.GetLocalDIDInfo("")
+	require.Error(t, err)
+
+	_, err = didProv.GetLocalDIDBasedOnVerKey(nil)
+	require.Error(t, err)
+
+	// secrets are encrypted at rest
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(content, didInfo.Secret))
+	}
+
+	// wrong passphrase
+	_, err = NewProvider(dir, []byte("wrong"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid passphrase")
+}
+
+func TestFileProvider_Seed(t *testing.T) {
+	didProv, err := NewProviderFromStore(memstore.NewStore(), passphrase)
+	require.NoError(t, err)
+
+	seed := map[string]interface{}{didprovider.SeedMetadataKey: "00000000000000000000000000000My1"}
+	_, err = didProv.CreateLocalDID(seed)
+	require.NoError(t, err)
+
+	// DID already exists
+	_, err = didProv.CreateLocalDID(seed)
+	require.Error(t, err)
+
+	// invalid seed
+	_, err = didProv.CreateLocalDID(map[string]interface{}{didprovider.SeedMetadataKey: "short"})
+	require.Error(t, err)
+}
+
+func TestFileProvider_Errors(t *testing.T) {
+	_, err := NewProviderFromStore(nil, passphrase)
+	require.Error(t, err)
+
+	_, err = NewProviderFromStore(memstore.NewStore(), nil)
+	require.Error(t, err)
+
+	file, err := ioutil.TempFile("", "didfile")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.Remove(file.Name())) }()
+
+	// directory can't be created
+	_, err = NewProvider(filepath.Join(file.Name(), "dids"), passphrase)
+	require.Error(t, err)
+
+	// corrupted records
+	store := memstore.NewStore()
+	didProv, err := NewProviderFromStore(store, passphrase)
+	require.NoError(t, err)
+	didInfo, err := didProv.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(didKeyPrefix+didInfo.DID, []byte(`{"did":"`+didInfo.DID+`"}`)))
+	_, err = didProv.GetLocalDIDInfo(didInfo.DID)
+	require.Error(t, err)
+	_, err = didProv.GetLocalDIDBasedOnVerKey(didInfo.VerKey)
+	require.Error(t, err)
+
+	require.NoError(t, store.Put(didKeyPrefix+didInfo.DID, []byte("invalid")))
+	_, err = didProv.GetLocalDIDList()
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package did
+
+import (
+	"crypto/rand"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// SeedMetadataKey metadata entry holding the optional 32 bytes seed of the DID key pair,
+	// same seed always yields the same DID and keys, it is meant for tests and isn't kept in the DID metadata
+	SeedMetadataKey = "seed"
+
+	didPrefix = "did:sov:"
+	// didKeySize size of the verkey prefix the DID is derived from
+	didKeySize = 16
+)
+
+// NewLocalDIDInfo generates an Ed25519 key pair, from the seed of the metadata if any, and the Sovrin style DID
+// derived from the first 16 bytes of the verkey
+func NewLocalDIDInfo(metadata map[string]interface{}) (*LocalDIDInfo, error) {
+	seed, err := seedFromMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var verKey ed25519.PublicKey
+	var secret ed25519.PrivateKey
+	if seed != nil {
+		secret = ed25519.NewKeyFromSeed(seed)
+		verKey = secret.Public().(ed25519.PublicKey)
+	} else {
+		verKey, secret, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate key pair")
+		}
+	}
+
+	var didMetadata map[string]interface{}
+	if metadata != nil {
+		didMetadata = make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			if k != SeedMetadataKey {
+				didMetadata[k] = v
+			}
+		}
+	}
+
+	return &LocalDIDInfo{
+		DID:      didPrefix + base58.Encode(verKey[:didKeySize]),
+		VerKey:   verKey,
+		Secret:   secret,
+		Metadata: didMetadata,
+	}, nil
+}
+
+// seedFromMetadata returns the seed of the metadata, nil if not set
+func seedFromMetadata(metadata map[string]interface{}) ([]byte, error) {
+	value, ok := metadata[SeedMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var seed []byte
+	switch v := value.(type) {
+	case string:
+		seed = []byte(v)
+	case []byte:
+		seed = v
+	default:
+		return nil, errors.Errorf("unsupported seed type %T", value)
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.Errorf("seed must be %d bytes", ed25519.SeedSize)
+	}
+
+	return seed, nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package did
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestNewLocalDIDInfo(t *testing.T) {
+	didInfo, err := NewLocalDIDInfo(map[string]interface{}{"label": "Alice"})
+	require.NoError(t, err)
+	require.Len(t, didInfo.VerKey, ed25519.PublicKeySize)
+	require.Len(t, didInfo.Secret, ed25519.PrivateKeySize)
+	require.Equal(t, "did:sov:"+base58.Encode(didInfo.VerKey[:16]), didInfo.DID)
+	require.Equal(t, map[string]interface{}{"label": "Alice"}, didInfo.Metadata)
+
+	otherDIDInfo, err := NewLocalDIDInfo(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, didInfo.DID, otherDIDInfo.DID)
+	require.Nil(t, otherDIDInfo.Metadata)
+}
+
+func TestNewLocalDIDInfo_Seed(t *testing.T) {
+	const seed = "00000000000000000000000000000My1"
+
+	didInfo, err := NewLocalDIDInfo(map[string]interface{}{SeedMetadataKey: seed, "label": "Alice"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"label": "Alice"}, didInfo.Metadata)
+
+	sameDIDInfo, err := NewLocalDIDInfo(map[string]interface{}{SeedMetadataKey: []byte(seed)})
+	require.NoError(t, err)
+	require.Equal(t, didInfo.DID, sameDIDInfo.DID)
+	require.Equal(t, didInfo.Secret, sameDIDInfo.Secret)
+
+	_, err = NewLocalDIDInfo(map[string]interface{}{SeedMetadataKey: "short"})
+	require.Error(t, err)
+
+	_, err = NewLocalDIDInfo(map[string]interface{}{SeedMetadataKey: 1})
+	require.Error(t, err)
+}
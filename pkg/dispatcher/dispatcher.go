@@ -0,0 +1,170 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+const (
+	// ProblemReportType problem report message type
+	ProblemReportType = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/notification/1.0/problem-report"
+
+	// UnrecognizedMessageType problem code reported for messages no handler is registered for
+	UnrecognizedMessageType = "unrecognized-message-type"
+
+	// docURISeparator separates the DID of the message family from the rest of the type in did:sov type URIs
+	docURISeparator = ";"
+)
+
+// Handler handles an inbound message payload
+type Handler func(payload []byte) error
+
+// EnvelopeHandler handles an inbound envelope, the returned reply is sent back to the sender
+// on the connection the envelope was received on (return route) if not empty
+type EnvelopeHandler func(envelope *pack.Envelope) ([]byte, error)
+
+// ProblemReportError is returned when an inbound message can't be dispatched,
+// the problem report is meant to be sent back to the sender
+type ProblemReportError struct {
+	Report *didexchange.ProblemReport
+}
+
+// Error returns the problem description
+func (e *ProblemReportError) Error() string {
+	return "problem report: " + e.Report.Description.Code + ": " + e.Report.Description.Message
+}
+
+// Dispatcher routes inbound messages to the handler registered for their @type and acts as the registry of
+// the protocol services of an agent.
+// Handlers are registered for a full type URI or for a type prefix ending with "/", such as
+// "spec/connections/1.0/" or "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/".
+type Dispatcher struct {
+	handlers map[string]EnvelopeHandler
+	services map[string]Service
+	lock     sync.RWMutex
+}
+
+// header is used to peek at the type of an inbound message
+type header struct {
+	Type string `json:"@type,omitempty"`
+	ID   string `json:"@id,omitempty"`
+}
+
+// New creates a new dispatcher without any handler
+func New() *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[string]EnvelopeHandler),
+		services: make(map[string]Service),
+	}
+}
+
+// Register registers the handler for the given type URI or type prefix
+func (d *Dispatcher) Register(msgType string, handler Handler) error {
+	if msgType == "" || handler == nil {
+		return errors.New("message type and handler are mandatory")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.handlers[msgType]; ok {
+		return errors.Errorf("handler already registered for %s", msgType)
+	}
+	d.handlers[msgType] = payloadHandler(handler)
+
+	return nil
+}
+
+// Dispatch peeks at the @type of the inbound message and invokes the handler registered for it,
+// a ProblemReportError is returned if no handler is registered for the message type
+func (d *Dispatcher) Dispatch(payload []byte) error {
+	_, err := d.DispatchEnvelope(&pack.Envelope{Message: payload})
+	return err
+}
+
+// DispatchEnvelope dispatches the message of the unpacked envelope as Dispatch does,
+// returning the reply of the handler to send back to the sender on the return route if any
+func (d *Dispatcher) DispatchEnvelope(envelope *pack.Envelope) ([]byte, error) {
+	msgHeader := &header{}
+	if err := json.Unmarshal(envelope.Message, msgHeader); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Message Header Error")
+	}
+
+	handler := d.lookup(msgHeader.Type)
+	if handler == nil {
+		return nil, &ProblemReportError{Report: &didexchange.ProblemReport{
+			Type:   ProblemReportType,
+			ID:     uuid.New().String(),
+			Thread: &didexchange.Thread{ID: msgHeader.ID},
+			Description: &didexchange.ProblemDescription{
+				Code:    UnrecognizedMessageType,
+				Message: "no handler registered for message type " + msgHeader.Type,
+			},
+		}}
+	}
+
+	return handler(envelope)
+}
+
+// lookup returns the handler registered for the exact message type,
+// or else the one registered for the longest matching type prefix
+func (d *Dispatcher) lookup(msgType string) EnvelopeHandler {
+	if msgType == "" {
+		return nil
+	}
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if handler, ok := d.handlers[msgType]; ok {
+		return handler
+	}
+
+	// prefixes may omit the DID of the message family
+	familyType := msgType
+	if i := strings.Index(msgType, docURISeparator); i >= 0 {
+		familyType = msgType[i+1:]
+	}
+
+	var handler EnvelopeHandler
+	longest := 0
+	for prefix, h := range d.handlers {
+		if !strings.HasSuffix(prefix, "/") {
+			continue
+		}
+
+		// length of the matched part of the full message type
+		matched := 0
+		if strings.HasPrefix(msgType, prefix) {
+			matched = len(prefix)
+		} else if strings.HasPrefix(familyType, prefix) {
+			matched = len(msgType) - len(familyType) + len(prefix)
+		}
+
+		if matched > longest {
+			handler = h
+			longest = matched
+		}
+	}
+
+	return handler
+}
+
+// payloadHandler adapts the payload handler to an envelope handler without reply
+func payloadHandler(handler Handler) EnvelopeHandler {
+	return func(envelope *pack.Envelope) ([]byte, error) {
+		return nil, handler(envelope.Message)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dispatcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	connectionRequest = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"
+	introduceRequest  = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/request"
+)
+
+func TestDispatcher(t *testing.T) {
+	d := New()
+
+	var dispatched []string
+	handler := func(name string) Handler {
+		return func(payload []byte) error {
+			dispatched = append(dispatched, name)
+			return nil
+		}
+	}
+
+	require.NoError(t, d.Register("spec/connections/1.0/", handler("connections")))
+	require.NoError(t, d.Register("did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/", handler("introduce")))
+	require.NoError(t, d.Register("did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/", handler("any")))
+	require.NoError(t, d.Register(introduceRequest, handler("introduce-request")))
+
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"`+connectionRequest+`"}`)))
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"spec/connections/1.0/response"}`)))
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/proposal"}`)))
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"`+introduceRequest+`"}`)))
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/trust_ping/1.0/ping"}`)))
+	require.Equal(t, []string{"connections", "connections", "introduce", "introduce-request", "any"}, dispatched)
+
+	// handler errors are returned as is
+	require.NoError(t, d.Register("spec/failing/1.0/", func(payload []byte) error {
+		return errors.New("handler error")
+	}))
+	require.EqualError(t, d.Dispatch([]byte(`{"@type":"spec/failing/1.0/message"}`)), "handler error")
+}
+
+func TestDispatcher_Register(t *testing.T) {
+	d := New()
+
+	require.Error(t, d.Register("", func(payload []byte) error { return nil }))
+	require.Error(t, d.Register("spec/connections/1.0/", nil))
+
+	require.NoError(t, d.Register("spec/connections/1.0/", func(payload []byte) error { return nil }))
+	require.Error(t, d.Register("spec/connections/1.0/", func(payload []byte) error { return nil }))
+}
+
+func TestDispatcher_UnknownType(t *testing.T) {
+	d := New()
+	require.NoError(t, d.Register(connectionRequest, func(payload []byte) error { return nil }))
+
+	for _, payload := range []string{
+		`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/response","@id":"12345"}`,
+		`{"@id":"12345"}`,
+	} {
+		err := d.Dispatch([]byte(payload))
+		require.IsType(t, &ProblemReportError{}, err)
+
+		report := err.(*ProblemReportError).Report
+		require.Equal(t, ProblemReportType, report.Type)
+		require.NotEmpty(t, report.ID)
+		require.Equal(t, "12345", report.Thread.ID)
+		require.Equal(t, UnrecognizedMessageType, report.Description.Code)
+		require.Contains(t, err.Error(), UnrecognizedMessageType)
+	}
+
+	require.Error(t, d.Dispatch([]byte("invalid json")))
+}
@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dispatcher
+
+import (
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+// Service protocol service interface, a protocol plugs into an agent by registering its service with the dispatcher
+type Service interface {
+	// Name of the protocol service
+	Name() string
+
+	// MsgTypes returns the message types accepted by the service, either type URIs or type prefixes ending with "/"
+	MsgTypes() []string
+
+	// HandleInbound handles an inbound message of one of the accepted types
+	HandleInbound(payload []byte) error
+
+	// HandleOutbound sends an outbound message of the protocol to the destination
+	HandleOutbound(msg interface{}, destination *Destination) error
+}
+
+// ReturnRouteService is implemented by the protocol services replying to inbound messages on the return route,
+// the dispatcher passes them the whole envelope instead of the message payload
+type ReturnRouteService interface {
+	Service
+
+	// HandleInboundEnvelope handles an inbound envelope of one of the accepted types, the returned reply is sent
+	// back to the sender on the connection the envelope was received on if not empty
+	HandleInboundEnvelope(envelope *pack.Envelope) ([]byte, error)
+}
+
+// Destination of an outbound message
+type Destination struct {
+	ServiceEndpoint string
+	RecipientKeys   []string
+	RoutingKeys     []string
+}
+
+// RegisterService registers the protocol service and routes the message types it accepts to its inbound handler
+func (d *Dispatcher) RegisterService(svc Service) error {
+	if svc == nil || svc.Name() == "" || len(svc.MsgTypes()) == 0 {
+		return errors.New("service name and message types are mandatory")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.services[svc.Name()]; ok {
+		return errors.Errorf("service %s already registered", svc.Name())
+	}
+
+	for _, msgType := range svc.MsgTypes() {
+		if _, ok := d.handlers[msgType]; ok {
+			return errors.Errorf("handler already registered for %s", msgType)
+		}
+	}
+
+	handler := payloadHandler(svc.HandleInbound)
+	if returnRouteSvc, ok := svc.(ReturnRouteService); ok {
+		handler = returnRouteSvc.HandleInboundEnvelope
+	}
+	for _, msgType := range svc.MsgTypes() {
+		d.handlers[msgType] = handler
+	}
+	d.services[svc.Name()] = svc
+
+	return nil
+}
+
+// Service returns the registered protocol service with the given name
+func (d *Dispatcher) Service(name string) (Service, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	svc, ok := d.services[name]
+	if !ok {
+		return nil, errors.Errorf("service %s not registered", name)
+	}
+
+	return svc, nil
+}
+
+// Services returns all the registered protocol services
+func (d *Dispatcher) Services() []Service {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	services := make([]Service, 0, len(d.services))
+	for _, svc := range d.services {
+		services = append(services, svc)
+	}
+
+	return services
+}
+
+// Send sends the outbound message through the protocol service with the given name
+func (d *Dispatcher) Send(name string, msg interface{}, destination *Destination) error {
+	svc, err := d.Service(name)
+	if err != nil {
+		return err
+	}
+
+	return svc.HandleOutbound(msg, destination)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dispatcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+type mockService struct {
+	name     string
+	msgTypes []string
+	inbound  [][]byte
+	outbound []interface{}
+}
+
+func (s *mockService) Name() string {
+	return s.name
+}
+
+func (s *mockService) MsgTypes() []string {
+	return s.msgTypes
+}
+
+func (s *mockService) HandleInbound(payload []byte) error {
+	s.inbound = append(s.inbound, payload)
+	return nil
+}
+
+func (s *mockService) HandleOutbound(msg interface{}, destination *Destination) error {
+	s.outbound = append(s.outbound, msg)
+	return nil
+}
+
+// replyService replies to its inbound messages on the return route
+type replyService struct {
+	mockService
+	senderKeys []string
+}
+
+func (s *replyService) HandleInboundEnvelope(envelope *pack.Envelope) ([]byte, error) {
+	s.senderKeys = append(s.senderKeys, envelope.SenderKey)
+	return []byte("reply to " + envelope.SenderKey), nil
+}
+
+func TestDispatcher_RegisterService(t *testing.T) {
+	d := New()
+
+	connections := &mockService{name: "connections", msgTypes: []string{"spec/connections/1.0/"}}
+	introduce := &mockService{name: "introduce", msgTypes: []string{introduceRequest}}
+	require.NoError(t, d.RegisterService(connections))
+	require.NoError(t, d.RegisterService(introduce))
+	require.Len(t, d.Services(), 2)
+
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"`+connectionRequest+`"}`)))
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"`+introduceRequest+`"}`)))
+	require.Len(t, connections.inbound, 1)
+	require.Len(t, introduce.inbound, 1)
+
+	svc, err := d.Service("introduce")
+	require.NoError(t, err)
+	require.Equal(t, introduce, svc)
+
+	require.NoError(t, d.Send("connections", "msg", &Destination{ServiceEndpoint: "https://localhost:8090"}))
+	require.Equal(t, []interface{}{"msg"}, connections.outbound)
+
+	// unknown service
+	_, err = d.Service("unknown")
+	require.Error(t, err)
+	require.Error(t, d.Send("unknown", "msg", &Destination{}))
+
+	// duplicate service name
+	require.Error(t, d.RegisterService(&mockService{name: "connections", msgTypes: []string{"spec/other/1.0/"}}))
+
+	// message type already handled, nothing is registered
+	other := &mockService{name: "other", msgTypes: []string{"spec/other/1.0/", introduceRequest}}
+	require.Error(t, d.RegisterService(other))
+	_, err = d.Service("other")
+	require.Error(t, err)
+	require.NoError(t, d.Register("spec/other/1.0/", func(payload []byte) error { return nil }))
+
+	// missing name or message types
+	require.Error(t, d.RegisterService(nil))
+	require.Error(t, d.RegisterService(&mockService{msgTypes: []string{"spec/x/1.0/"}}))
+	require.Error(t, d.RegisterService(&mockService{name: "x"}))
+}
+
+func TestDispatcher_ReturnRouteService(t *testing.T) {
+	d := New()
+
+	connections := &mockService{name: "connections", msgTypes: []string{"spec/connections/1.0/"}}
+	introduce := &replyService{mockService: mockService{name: "introduce", msgTypes: []string{introduceRequest}}}
+	require.NoError(t, d.RegisterService(connections))
+	require.NoError(t, d.RegisterService(introduce))
+
+	// the envelope is passed to return route services
+	reply, err := d.DispatchEnvelope(&pack.Envelope{
+		Message:   []byte(`{"@type":"` + introduceRequest + `"}`),
+		SenderKey: "sender",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "reply to sender", string(reply))
+	require.Equal(t, []string{"sender"}, introduce.senderKeys)
+	require.Empty(t, introduce.inbound)
+
+	// the reply is dropped by Dispatch
+	require.NoError(t, d.Dispatch([]byte(`{"@type":"`+introduceRequest+`"}`)))
+	require.Equal(t, []string{"sender", ""}, introduce.senderKeys)
+
+	// other services don't reply
+	reply, err = d.DispatchEnvelope(&pack.Envelope{Message: []byte(`{"@type":"` + connectionRequest + `"}`)})
+	require.NoError(t, err)
+	require.Empty(t, reply)
+	require.Len(t, connections.inbound, 1)
+
+	_, err = d.DispatchEnvelope(&pack.Envelope{Message: []byte(`{"@type":"unknown"}`)})
+	require.IsType(t, &ProblemReportError{}, err)
+	_, err = d.DispatchEnvelope(&pack.Envelope{Message: []byte("not json")})
+	require.Error(t, err)
+}
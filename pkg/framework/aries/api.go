@@ -0,0 +1,91 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aries
+
+import (
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/framework/context"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// ProtocolSvcCreator creates a protocol service from the framework context
+type ProtocolSvcCreator func(ctx *context.Provider) (dispatcher.Service, error)
+
+// Option configures the framework
+type Option func(opts *Aries)
+
+// WithOutboundTransport sets the transport used to send messages to other agents,
+// the framework closes it on Close if it implements io.Closer
+func WithOutboundTransport(ot transport.OutboundTransport) Option {
+	return func(opts *Aries) {
+		opts.outboundTransport = ot
+	}
+}
+
+// WithInboundTransport sets the transport receiving messages from other agents,
+// it takes precedence over the inbound HTTP address
+func WithInboundTransport(it transport.InboundTransport) Option {
+	return func(opts *Aries) {
+		opts.inboundTransport = it
+	}
+}
+
+// WithInboundHTTPAddr sets the address the default inbound HTTP transport listens on, the agent doesn't
+// receive messages if neither the address nor an inbound transport is set
+func WithInboundHTTPAddr(addr string) Option {
+	return func(opts *Aries) {
+		opts.inboundAddr = addr
+	}
+}
+
+// WithInboundHTTPEndpoint sets the URL other agents reach the default inbound HTTP transport at, e.g. behind
+// a proxy. It is mandatory if the inbound HTTP address is a wildcard address.
+func WithInboundHTTPEndpoint(endpoint string) Option {
+	return func(opts *Aries) {
+		opts.inboundEndpoint = endpoint
+	}
+}
+
+// WithDIDProvider sets the provider of the agent's local DIDs
+func WithDIDProvider(didProvider didprovider.Provider) Option {
+	return func(opts *Aries) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithDIDResolver sets the DID resolver
+func WithDIDResolver(didResolver *resolver.Resolver) Option {
+	return func(opts *Aries) {
+		opts.didResolver = didResolver
+	}
+}
+
+// WithStorageProvider sets the storage provider, the framework closes it on Close
+func WithStorageProvider(storeProvider storage.Provider) Option {
+	return func(opts *Aries) {
+		opts.storeProvider = storeProvider
+	}
+}
+
+// WithPacker sets the packer of the DIDComm envelopes sent and received by the agent
+func WithPacker(packer pack.Packer) Option {
+	return func(opts *Aries) {
+		opts.packer = packer
+	}
+}
+
+// WithProtocols adds protocol services to the agent, they take precedence over the default
+// connections, introduce, trust ping and basic message services with the same name
+func WithProtocols(protocolSvcCreators ...ProtocolSvcCreator) Option {
+	return func(opts *Aries) {
+		opts.protocolSvcCreators = append(opts.protocolSvcCreators, protocolSvcCreators...)
+	}
+}
@@ -0,0 +1,349 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aries
+
+import (
+	gocontext "context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/basicmessage"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/framework/context"
+	"github.com/trustbloc/aries-framework-go/pkg/introduction"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	didcommtrans "github.com/trustbloc/aries-framework-go/pkg/transport/http"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/ws"
+	"github.com/trustbloc/aries-framework-go/pkg/trustping"
+)
+
+const (
+	// inboundPath path of the agent endpoint on the inbound HTTP transport
+	inboundPath = "/"
+
+	defaultOutboundTimeout = 30 * time.Second
+	shutdownTimeout        = 10 * time.Second
+)
+
+// Aries provides access to the agent context and owns the lifecycle of the inbound transport
+type Aries struct {
+	outboundTransport   transport.OutboundTransport
+	inboundTransport    transport.InboundTransport
+	inboundAddr         string
+	inboundEndpoint     string
+	didProvider         didprovider.Provider
+	didResolver         *resolver.Resolver
+	storeProvider       storage.Provider
+	packer              pack.Packer
+	protocolSvcCreators []ProtocolSvcCreator
+	ctx                 *context.Provider
+	lock                sync.Mutex
+	started             bool
+	closed              bool
+}
+
+// New creates a new framework, the agent starts receiving messages once started.
+// Defaults are HTTP and WebSocket outbound transports chosen by the destination scheme, an in-memory storage,
+// the basic DID provider, a resolver without DID methods, a packer of legacy RFC 0019 and JWE envelopes
+// packing in the legacy format and the connections, introduce, trust ping and basic message protocol services.
+// The basic message history is saved in the storage of the agent.
+func New(opts ...Option) (*Aries, error) {
+	frameworkOpts := &Aries{}
+	// Apply options
+	for _, opt := range opts {
+		opt(frameworkOpts)
+	}
+
+	if err := frameworkOpts.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	ctx, err := context.New(
+		context.WithOutboundTransport(frameworkOpts.outboundTransport),
+		context.WithInboundTransport(frameworkOpts.inboundTransport),
+		context.WithDIDProvider(frameworkOpts.didProvider),
+		context.WithDIDResolver(frameworkOpts.didResolver),
+		context.WithStorageProvider(frameworkOpts.storeProvider),
+		context.WithPacker(frameworkOpts.packer),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create framework context")
+	}
+	frameworkOpts.ctx = ctx
+
+	if err := frameworkOpts.registerServices(); err != nil {
+		return nil, err
+	}
+
+	return frameworkOpts, nil
+}
+
+// Context returns the framework context
+func (a *Aries) Context() *context.Provider {
+	return a.ctx
+}
+
+// Start starts the inbound transport of the agent
+func (a *Aries) Start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return errors.New("framework is closed")
+	}
+	if a.started {
+		return errors.New("framework already started")
+	}
+
+	if a.inboundTransport != nil {
+		if err := a.inboundTransport.Start(a.ctx.Packer(), a.handleInbound); err != nil {
+			return errors.Wrapf(err, "failed to start inbound transport")
+		}
+	}
+
+	a.started = true
+
+	return nil
+}
+
+// Close stops the inbound transport, waits for the messages in progress, closes the outbound transport
+// if it can be closed and closes the storage provider. All of them are closed even if one of them fails,
+// the errors are returned together.
+func (a *Aries) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return nil
+	}
+
+	var errMsgs []string
+	if a.started && a.inboundTransport != nil {
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), shutdownTimeout)
+		defer cancel()
+		if err := a.inboundTransport.Stop(ctx); err != nil {
+			errMsgs = append(errMsgs, "failed to stop inbound transport: "+err.Error())
+		}
+	}
+	if closer, ok := a.outboundTransport.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errMsgs = append(errMsgs, "failed to close outbound transport: "+err.Error())
+		}
+	}
+	if err := a.storeProvider.Close(); err != nil {
+		errMsgs = append(errMsgs, "failed to close storage provider: "+err.Error())
+	}
+	a.closed = true
+
+	if len(errMsgs) > 0 {
+		return errors.New(strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
+
+func (a *Aries) setDefaults() error {
+	if a.didProvider == nil {
+		a.didProvider = didbasic.NewProvider()
+	}
+	if a.didResolver == nil {
+		a.didResolver = resolver.New()
+	}
+	if a.storeProvider == nil {
+		a.storeProvider = memstore.NewProvider()
+	}
+	if a.packer == nil {
+		a.packer = packers.NewDefault(a.didProvider)
+	}
+
+	return a.setDefaultTransports()
+}
+
+// setDefaultTransports sets the default inbound transport if an inbound address is set
+// and the default outbound transport, the packer is set beforehand
+func (a *Aries) setDefaultTransports() error {
+	if a.inboundTransport == nil && a.inboundAddr != "" {
+		inboundTransport, err := a.defaultInboundTransport()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create inbound HTTP transport")
+		}
+		a.inboundTransport = inboundTransport
+	}
+	if a.outboundTransport == nil {
+		outboundTransport, err := a.defaultOutboundTransport()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create default outbound transport")
+		}
+		a.outboundTransport = outboundTransport
+	}
+
+	return nil
+}
+
+// defaultInboundTransport returns the HTTP inbound transport listening on the inbound address,
+// it is reached at the inbound endpoint if set
+func (a *Aries) defaultInboundTransport() (transport.InboundTransport, error) {
+	opts := []didcommtrans.InboundOpt{didcommtrans.WithInboundPath(inboundPath)}
+	if a.inboundEndpoint != "" {
+		opts = append(opts, didcommtrans.WithInboundExternalEndpoint(a.inboundEndpoint))
+	}
+
+	return didcommtrans.NewInbound(a.inboundAddr, opts...)
+}
+
+// defaultOutboundTransport returns the HTTP and WebSocket outbound transports chosen by the destination scheme,
+// messages received on the websockets opened by the agent are handled as inbound messages
+func (a *Aries) defaultOutboundTransport() (transport.OutboundTransport, error) {
+	httpTransport, err := didcommtrans.NewOutboundCommFromClient(&http.Client{Timeout: defaultOutboundTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	wsTransport, err := ws.NewOutbound(ws.WithInboundMessageHandler(a.packer, a.handleInbound))
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.NewOutboundMux(
+		transport.WithSchemeTransport(httpTransport, "http", "https"),
+		transport.WithSchemeTransport(wsTransport, "ws", "wss"),
+	), nil
+}
+
+// handleInbound dispatches the envelope unpacked by the inbound transport to the protocol services,
+// their reply if any is sent back on the return route. Messages of other media types than envelopes are rejected.
+func (a *Aries) handleInbound(mediaType string, envelope *pack.Envelope) ([]byte, error) {
+	if !transport.IsEnvelope(mediaType) {
+		return nil, errors.Errorf("unsupported media type %s of inbound message", mediaType)
+	}
+
+	return a.ctx.Dispatcher().DispatchEnvelope(envelope)
+}
+
+// defaultService protocol service registered by default unless a service of the same name is registered
+// by the options, it is created only if registered
+type defaultService struct {
+	name    string
+	creator ProtocolSvcCreator
+}
+
+// defaultServices the connections, introduce, trust ping and basic message protocol services
+var defaultServices = []defaultService{
+	{name: connection.ServiceName, creator: newConnectionService},
+	{name: introduction.ServiceName, creator: newIntroductionService},
+	{name: trustping.ServiceName, creator: newTrustPingService},
+	{name: basicmessage.ServiceName, creator: newBasicMessageService},
+}
+
+// registerServices registers the protocol services of the options followed by the default services
+// which aren't overridden
+func (a *Aries) registerServices() error {
+	for _, creator := range a.protocolSvcCreators {
+		if err := a.registerService(creator); err != nil {
+			return err
+		}
+	}
+
+	for _, svc := range defaultServices {
+		if _, err := a.ctx.Dispatcher().Service(svc.name); err == nil {
+			continue
+		}
+
+		if err := a.registerService(svc.creator); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerService creates the protocol service and registers it unless a service of the same name is registered
+func (a *Aries) registerService(creator ProtocolSvcCreator) error {
+	svc, err := creator(a.ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create protocol service")
+	}
+
+	if _, err := a.ctx.Dispatcher().Service(svc.Name()); err == nil {
+		return nil
+	}
+
+	if err := a.ctx.Dispatcher().RegisterService(svc); err != nil {
+		return errors.Wrapf(err, "failed to register protocol service %s", svc.Name())
+	}
+
+	return nil
+}
+
+func newConnectionService(ctx *context.Provider) (dispatcher.Service, error) {
+	connectionStore, err := openConnectionStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.NewService(ctx.OutboundTransport(), connectionStore,
+		connection.WithPacker(ctx.Packer()), connection.WithDIDProvider(ctx.DIDProvider()),
+		connection.WithDIDResolver(ctx.DIDResolver()))
+}
+
+func newIntroductionService(ctx *context.Provider) (dispatcher.Service, error) {
+	connectionStore, err := openConnectionStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return introduction.NewService(ctx.OutboundTransport(), connectionStore, nil,
+		introduction.WithPacker(ctx.Packer()), introduction.WithDIDProvider(ctx.DIDProvider()))
+}
+
+func newTrustPingService(ctx *context.Provider) (dispatcher.Service, error) {
+	connectionStore, err := openConnectionStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return trustping.NewService(ctx.OutboundTransport(), connectionStore,
+		trustping.WithPacker(ctx.Packer()), trustping.WithDIDProvider(ctx.DIDProvider()))
+}
+
+func newBasicMessageService(ctx *context.Provider) (dispatcher.Service, error) {
+	connectionStore, err := openConnectionStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	historyStore, err := ctx.StorageProvider().OpenStore(basicmessage.StoreName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open basic message store")
+	}
+
+	return basicmessage.NewService(ctx.OutboundTransport(), connectionStore, nil,
+		basicmessage.WithPacker(ctx.Packer()), basicmessage.WithDIDProvider(ctx.DIDProvider()),
+		basicmessage.WithHistory(historyStore))
+}
+
+// openConnectionStore opens the store of the connection records shared by the protocol services
+func openConnectionStore(ctx *context.Provider) (*connection.ConnectionStore, error) {
+	store, err := ctx.StorageProvider().OpenStore(connection.StoreName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open connection store")
+	}
+
+	return connection.NewConnectionStore(store)
+}
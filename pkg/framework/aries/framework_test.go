@@ -0,0 +1,478 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aries
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/basicmessage"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/framework/context"
+	"github.com/trustbloc/aries-framework-go/pkg/introduction"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/jwe"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	didcommtrans "github.com/trustbloc/aries-framework-go/pkg/transport/http"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/ws"
+	"github.com/trustbloc/aries-framework-go/pkg/trustping"
+)
+
+const commContentType = "application/didcomm-envelope-enc"
+
+type mockService struct {
+	name    string
+	inbound [][]byte
+}
+
+func (s *mockService) Name() string {
+	return s.name
+}
+
+func (s *mockService) MsgTypes() []string {
+	return []string{"spec/" + s.name + "/1.0/"}
+}
+
+func (s *mockService) HandleInbound(payload []byte) error {
+	s.inbound = append(s.inbound, payload)
+	return nil
+}
+
+func (s *mockService) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	return nil
+}
+
+// chanService passes its inbound messages to a channel
+type chanService struct {
+	received chan []byte
+}
+
+func (s *chanService) Name() string {
+	return "chan"
+}
+
+func (s *chanService) MsgTypes() []string {
+	return []string{"spec/chan/1.0/"}
+}
+
+func (s *chanService) HandleInbound(payload []byte) error {
+	s.received <- payload
+	return nil
+}
+
+func (s *chanService) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	return nil
+}
+
+func TestFramework_Defaults(t *testing.T) {
+	a, err := New()
+	require.NoError(t, err)
+
+	ctx := a.Context()
+	require.NotNil(t, ctx.OutboundTransport())
+	require.NotNil(t, ctx.DIDProvider())
+	require.NotNil(t, ctx.DIDResolver())
+	require.NotNil(t, ctx.StorageProvider())
+
+	_, err = ctx.Service(connection.ServiceName)
+	require.NoError(t, err)
+	_, err = ctx.Service(introduction.ServiceName)
+	require.NoError(t, err)
+	_, err = ctx.Service(trustping.ServiceName)
+	require.NoError(t, err)
+	_, err = ctx.Service(basicmessage.ServiceName)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Start())
+	require.Error(t, a.Start())
+	require.NoError(t, a.Close())
+	require.NoError(t, a.Close())
+	require.Error(t, a.Start())
+}
+
+// failingInbound inbound transport failing to stop
+type failingInbound struct{}
+
+func (f *failingInbound) Start(packer pack.Packer, handler transport.InboundMessageHandler) error {
+	return nil
+}
+
+func (f *failingInbound) Stop(ctx gocontext.Context) error {
+	return errors.New("stop error")
+}
+
+func (f *failingInbound) Endpoint() string {
+	return ""
+}
+
+// closingOutbound outbound transport counting its closes, the close error is returned if set
+type closingOutbound struct {
+	*mock.OutboundTransport
+	closed int
+	err    error
+}
+
+func (c *closingOutbound) Close() error {
+	c.closed++
+	return c.err
+}
+
+// closingStoreProvider storage provider counting its closes
+type closingStoreProvider struct {
+	*memstore.Provider
+	closed int
+}
+
+func (c *closingStoreProvider) Close() error {
+	c.closed++
+	return c.Provider.Close()
+}
+
+func TestFramework_CloseErrors(t *testing.T) {
+	outbound := &closingOutbound{OutboundTransport: mock.NewOutboundTransport(""), err: errors.New("close error")}
+	storeProvider := &closingStoreProvider{Provider: memstore.NewProvider()}
+	a, err := New(WithInboundTransport(&failingInbound{}), WithOutboundTransport(outbound),
+		WithStorageProvider(storeProvider))
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+
+	// everything is closed even though stopping the inbound transport fails
+	err = a.Close()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "stop error")
+	require.Contains(t, err.Error(), "close error")
+	require.Equal(t, 1, outbound.closed)
+	require.Equal(t, 1, storeProvider.closed)
+
+	require.NoError(t, a.Close())
+	require.Equal(t, 1, storeProvider.closed)
+	require.Error(t, a.Start())
+}
+
+func TestFramework_Options(t *testing.T) {
+	transport := mock.NewOutboundTransport("success")
+	didProvider := didbasic.NewProvider()
+	didResolver := resolver.New()
+	storeProvider := memstore.NewProvider()
+	packer := legacy.New(didProvider)
+	svc := &mockService{name: "mock"}
+
+	a, err := New(WithOutboundTransport(transport), WithDIDProvider(didProvider), WithDIDResolver(didResolver),
+		WithStorageProvider(storeProvider), WithPacker(packer), WithProtocols(func(ctx *context.Provider) (dispatcher.Service, error) {
+			return svc, nil
+		}))
+	require.NoError(t, err)
+
+	ctx := a.Context()
+	require.Equal(t, transport, ctx.OutboundTransport())
+	require.Equal(t, didProvider, ctx.DIDProvider())
+	require.Equal(t, didResolver, ctx.DIDResolver())
+	require.Equal(t, storeProvider, ctx.StorageProvider())
+	require.Equal(t, packer, ctx.Packer())
+
+	registered, err := ctx.Service("mock")
+	require.NoError(t, err)
+	require.Equal(t, svc, registered)
+
+	require.NoError(t, ctx.Dispatcher().Dispatch([]byte(`{"@type":"spec/mock/1.0/ping"}`)))
+	require.Len(t, svc.inbound, 1)
+}
+
+func TestFramework_OverrideDefaultService(t *testing.T) {
+	svc := &mockService{name: introduction.ServiceName}
+	a, err := New(WithProtocols(func(ctx *context.Provider) (dispatcher.Service, error) {
+		return svc, nil
+	}))
+	require.NoError(t, err)
+
+	registered, err := a.Context().Service(introduction.ServiceName)
+	require.NoError(t, err)
+	require.Equal(t, svc, registered)
+}
+
+// failingStoreProvider storage provider failing to open stores
+type failingStoreProvider struct {
+	*memstore.Provider
+}
+
+func (f *failingStoreProvider) OpenStore(name string) (storage.Store, error) {
+	return nil, errors.New("open store error")
+}
+
+func TestFramework_OverrideAllDefaultServices(t *testing.T) {
+	var creators []ProtocolSvcCreator
+	for _, name := range []string{connection.ServiceName, introduction.ServiceName, trustping.ServiceName,
+		basicmessage.ServiceName} {
+		svc := &mockService{name: name}
+		creators = append(creators, func(ctx *context.Provider) (dispatcher.Service, error) {
+			return svc, nil
+		})
+	}
+
+	// the overridden default services aren't created, they would fail to open their stores
+	a, err := New(WithStorageProvider(&failingStoreProvider{Provider: memstore.NewProvider()}),
+		WithProtocols(creators...))
+	require.NoError(t, err)
+
+	registered, err := a.Context().Service(trustping.ServiceName)
+	require.NoError(t, err)
+	require.Equal(t, &mockService{name: trustping.ServiceName}, registered)
+
+	_, err = New(WithStorageProvider(&failingStoreProvider{Provider: memstore.NewProvider()}))
+	require.Error(t, err)
+}
+
+func TestFramework_ProtocolError(t *testing.T) {
+	_, err := New(WithProtocols(func(ctx *context.Provider) (dispatcher.Service, error) {
+		return nil, errors.New("protocol error")
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "protocol error")
+}
+
+func TestFramework_InboundHTTP(t *testing.T) {
+	a, err := New(WithInboundHTTPAddr("localhost:0"))
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+
+	url := a.Context().InboundTransport().Endpoint()
+	require.NotEmpty(t, url)
+
+	myDID, err := a.Context().DIDProvider().CreateLocalDID(nil)
+	require.NoError(t, err)
+	recipientKeys := []string{base58.Encode(myDID.VerKey)}
+
+	// introduction proposal unpacked and routed to the introduce service
+	envelope, err := legacy.New(nil).Pack(
+		[]byte(`{"@type":"`+introduction.MsgTypePrefix+`proposal","@id":"proposal-id"}`), "", recipientKeys)
+	require.NoError(t, err)
+	resp, err := http.Post(url, commContentType, bytes.NewBuffer(envelope))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// JWE envelopes are detected and unpacked as well
+	envelope, err = jwe.New(nil).Pack(
+		[]byte(`{"@type":"`+introduction.MsgTypePrefix+`proposal","@id":"proposal-id"}`), "", recipientKeys)
+	require.NoError(t, err)
+	resp, err = http.Post(url, commContentType, bytes.NewBuffer(envelope))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// problem report for unknown message types
+	envelope, err = legacy.New(nil).Pack([]byte(`{"@type":"spec/unknown/1.0/msg"}`), "", recipientKeys)
+	require.NoError(t, err)
+	resp, err = http.Post(url, commContentType, bytes.NewBuffer(envelope))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// plaintext messages are rejected
+	resp, err = http.Post(url, commContentType, bytes.NewBufferString(
+		`{"@type":"`+introduction.MsgTypePrefix+`proposal","@id":"proposal-id"}`))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	require.NoError(t, a.Close())
+
+	// inbound transport is stopped
+	_, err = http.Post(url, commContentType, bytes.NewBufferString(`{}`))
+	require.Error(t, err)
+}
+
+func TestFramework_InboundHTTPEndpoint(t *testing.T) {
+	_, err := New(WithInboundHTTPAddr(":0"))
+	require.Error(t, err)
+
+	a, err := New(WithInboundHTTPAddr(":0"), WithInboundHTTPEndpoint("https://agent.example.com/"))
+	require.NoError(t, err)
+	require.NoError(t, a.Start())
+	require.Equal(t, "https://agent.example.com/", a.Context().InboundTransport().Endpoint())
+	require.NoError(t, a.Close())
+}
+
+func TestFramework_InboundTransport(t *testing.T) {
+	inbound, err := didcommtrans.NewInbound("localhost:0", didcommtrans.WithInboundPath("/agent"))
+	require.NoError(t, err)
+
+	a, err := New(WithInboundTransport(inbound), WithInboundHTTPAddr("localhost:0"))
+	require.NoError(t, err)
+	require.Equal(t, inbound, a.Context().InboundTransport())
+	require.Empty(t, inbound.Endpoint())
+
+	require.NoError(t, a.Start())
+	require.True(t, strings.HasSuffix(inbound.Endpoint(), "/agent"))
+
+	require.NoError(t, a.Close())
+	require.Empty(t, inbound.Endpoint())
+}
+
+func TestFramework_WebSocket(t *testing.T) {
+	inbound, err := ws.NewInbound("localhost:0")
+	require.NoError(t, err)
+	received := make(chan []byte, 1)
+	alice, err := New(WithInboundTransport(inbound), WithProtocols(func(ctx *context.Provider) (dispatcher.Service, error) {
+		return &chanService{received: received}, nil
+	}))
+	require.NoError(t, err)
+	require.NoError(t, alice.Start())
+	defer func() { require.NoError(t, alice.Close()) }()
+
+	aliceDID, err := alice.Context().DIDProvider().CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	bob, err := New()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, bob.Close()) }()
+
+	// the default outbound transport picks the WebSocket transport for ws endpoints
+	msg := []byte(`{"@type":"spec/chan/1.0/msg"}`)
+	envelope, err := bob.Context().Packer().Pack(msg, "", []string{base58.Encode(aliceDID.VerKey)})
+	require.NoError(t, err)
+	_, err = bob.Context().OutboundTransport().Send(string(envelope), inbound.Endpoint())
+	require.NoError(t, err)
+
+	select {
+	case payload := <-received:
+		require.Equal(t, msg, payload)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	_, err = bob.Context().OutboundTransport().Send(string(envelope), "mem://alice")
+	require.Error(t, err)
+	_, ok := err.(*transport.NoTransportForSchemeError)
+	require.True(t, ok)
+}
+
+func TestFramework_MemTransport(t *testing.T) {
+	hub := mem.NewHub()
+
+	newAgent := func(name string) (*Aries, *connection.Service, *didprovider.LocalDIDInfo) {
+		agent, err := New(WithInboundTransport(hub.Inbound(name)), WithOutboundTransport(hub.Outbound()))
+		require.NoError(t, err)
+		require.NoError(t, agent.Start())
+
+		svc, err := agent.Context().Service(connection.ServiceName)
+		require.NoError(t, err)
+		did, err := agent.Context().DIDProvider().CreateLocalDID(nil)
+		require.NoError(t, err)
+		return agent, svc.(*connection.Service), did
+	}
+
+	alice, aliceSvc, aliceDID := newAgent("alice")
+	defer func() { require.NoError(t, alice.Close()) }()
+	bob, bobSvc, bobDID := newAgent("bob")
+	defer func() { require.NoError(t, bob.Close()) }()
+
+	const invitationID, requestID = "invitation-id", "request-id"
+	invitation := &didexchange.InviteMessage{
+		ID:              invitationID,
+		Label:           "Alice",
+		RecipientKeys:   []string{base58.Encode(aliceDID.VerKey)},
+		ServiceEndpoint: "mem://alice",
+	}
+	_, err := aliceSvc.GenerateInviteWithKeyAndEndpoint(invitation)
+	require.NoError(t, err)
+	require.NoError(t, bobSvc.ReceiveInvitation(invitation))
+
+	// the whole exchange goes through the hub
+	toAlice := &dispatcher.Destination{ServiceEndpoint: "mem://alice", RecipientKeys: invitation.RecipientKeys}
+	toBob := &dispatcher.Destination{ServiceEndpoint: "mem://bob", RecipientKeys: []string{base58.Encode(bobDID.VerKey)}}
+
+	require.NoError(t, bobSvc.HandleOutbound(&didexchange.Request{
+		ID:         requestID,
+		Label:      "Bob",
+		Thread:     &didexchange.Thread{PID: invitationID},
+		Connection: &didexchange.Connection{DID: bobDID.DID},
+	}, toAlice))
+	require.Equal(t, connection.StateIDRequested, aliceSvc.State(requestID))
+
+	response := &didexchange.Response{ID: "response-id", Thread: &didexchange.Thread{ID: requestID}}
+	require.NoError(t, connection.SignExchangeResponse(response, &didexchange.Connection{DID: aliceDID.DID},
+		aliceDID.VerKey, aliceDID.Secret))
+	require.NoError(t, aliceSvc.HandleOutbound(response, toBob))
+	require.Equal(t, connection.StateIDResponded, bobSvc.State(requestID))
+
+	require.NoError(t, bobSvc.HandleOutbound(&didexchange.Ack{ID: "ack-id", Thread: &didexchange.Thread{ID: requestID}},
+		toAlice))
+	require.Equal(t, connection.StateIDCompleted, aliceSvc.State(requestID))
+	require.Equal(t, connection.StateIDCompleted, bobSvc.State(requestID))
+
+	record, err := aliceSvc.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	require.Equal(t, bobDID.DID, record.TheirDID)
+	require.Equal(t, "Bob", record.TheirLabel)
+
+	// bob pings alice, the response comes back on the return route
+	pingSvc, err := bob.Context().Service(trustping.ServiceName)
+	require.NoError(t, err)
+	bobRecord, err := bobSvc.ConnectionStore().GetConnectionRecordByThreadID(requestID)
+	require.NoError(t, err)
+	latency, err := pingSvc.(*trustping.Service).Ping(bobRecord.ConnectionID)
+	require.NoError(t, err)
+	require.True(t, latency > 0)
+
+	// bob chats with alice, both histories hold the message
+	msgSvc, err := bob.Context().Service(basicmessage.ServiceName)
+	require.NoError(t, err)
+	require.NoError(t, msgSvc.(*basicmessage.Service).Send(bobRecord.ConnectionID,
+		&didexchange.BasicMessage{Content: "hello"}))
+
+	bobHistory, err := msgSvc.(*basicmessage.Service).History(bobRecord.ConnectionID)
+	require.NoError(t, err)
+	require.Len(t, bobHistory, 1)
+	require.False(t, bobHistory[0].Inbound)
+
+	msgSvc, err = alice.Context().Service(basicmessage.ServiceName)
+	require.NoError(t, err)
+	aliceHistory, err := msgSvc.(*basicmessage.Service).History(record.ConnectionID)
+	require.NoError(t, err)
+	require.Len(t, aliceHistory, 1)
+	require.True(t, aliceHistory[0].Inbound)
+	require.Equal(t, "hello", aliceHistory[0].Message.Content)
+
+	// unknown agents can't be reached
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Request{ID: "other-request"},
+		&dispatcher.Destination{ServiceEndpoint: "mem://carol", RecipientKeys: invitation.RecipientKeys}))
+}
+
+func TestFramework_InboundHTTPInvalidAddr(t *testing.T) {
+	a, err := New(WithInboundHTTPAddr("invalid address"))
+	require.NoError(t, err)
+	require.Error(t, a.Start())
+	require.NoError(t, a.Close())
+}
+
+func TestFramework_InboundMediaType(t *testing.T) {
+	a, err := New()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, a.Close()) }()
+
+	_, err = a.handleInbound(transport.MediaTypeJSON, &pack.Envelope{Message: []byte(`{}`)})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported media type")
+}
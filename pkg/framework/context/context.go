@@ -0,0 +1,140 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package context
+
+import (
+	"github.com/pkg/errors"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// Provider supplies the framework configuration to the packages plugged into the agent
+type Provider struct {
+	outboundTransport transport.OutboundTransport
+	inboundTransport  transport.InboundTransport
+	didProvider       didprovider.Provider
+	didResolver       *resolver.Resolver
+	storeProvider     storage.Provider
+	packer            pack.Packer
+	dispatcher        *dispatcher.Dispatcher
+}
+
+// ProviderOption configures the framework context provider
+type ProviderOption func(opts *Provider)
+
+// New creates a new framework context provider
+func New(opts ...ProviderOption) (*Provider, error) {
+	ctxProvider := &Provider{}
+	// Apply options
+	for _, opt := range opts {
+		opt(ctxProvider)
+	}
+
+	if ctxProvider.dispatcher == nil {
+		ctxProvider.dispatcher = dispatcher.New()
+	}
+
+	return ctxProvider, nil
+}
+
+// OutboundTransport returns the outbound transport of the agent
+func (p *Provider) OutboundTransport() transport.OutboundTransport {
+	return p.outboundTransport
+}
+
+// InboundTransport returns the inbound transport of the agent, nil if the agent doesn't receive messages
+func (p *Provider) InboundTransport() transport.InboundTransport {
+	return p.inboundTransport
+}
+
+// DIDProvider returns the provider of the agent's local DIDs
+func (p *Provider) DIDProvider() didprovider.Provider {
+	return p.didProvider
+}
+
+// DIDResolver returns the DID resolver of the agent
+func (p *Provider) DIDResolver() *resolver.Resolver {
+	return p.didResolver
+}
+
+// StorageProvider returns the storage provider of the agent
+func (p *Provider) StorageProvider() storage.Provider {
+	return p.storeProvider
+}
+
+// Packer returns the packer of the DIDComm envelopes sent and received by the agent
+func (p *Provider) Packer() pack.Packer {
+	return p.packer
+}
+
+// Dispatcher returns the dispatcher routing inbound messages to the protocol services
+func (p *Provider) Dispatcher() *dispatcher.Dispatcher {
+	return p.dispatcher
+}
+
+// Service returns the protocol service registered with the given name
+func (p *Provider) Service(name string) (dispatcher.Service, error) {
+	svc, err := p.dispatcher.Service(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get protocol service")
+	}
+
+	return svc, nil
+}
+
+// WithOutboundTransport injects the outbound transport into the context
+func WithOutboundTransport(ot transport.OutboundTransport) ProviderOption {
+	return func(opts *Provider) {
+		opts.outboundTransport = ot
+	}
+}
+
+// WithInboundTransport injects the inbound transport into the context
+func WithInboundTransport(it transport.InboundTransport) ProviderOption {
+	return func(opts *Provider) {
+		opts.inboundTransport = it
+	}
+}
+
+// WithDIDProvider injects the DID provider into the context
+func WithDIDProvider(didProvider didprovider.Provider) ProviderOption {
+	return func(opts *Provider) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithDIDResolver injects the DID resolver into the context
+func WithDIDResolver(didResolver *resolver.Resolver) ProviderOption {
+	return func(opts *Provider) {
+		opts.didResolver = didResolver
+	}
+}
+
+// WithStorageProvider injects the storage provider into the context
+func WithStorageProvider(storeProvider storage.Provider) ProviderOption {
+	return func(opts *Provider) {
+		opts.storeProvider = storeProvider
+	}
+}
+
+// WithPacker injects the envelope packer into the context
+func WithPacker(packer pack.Packer) ProviderOption {
+	return func(opts *Provider) {
+		opts.packer = packer
+	}
+}
+
+// WithDispatcher injects the dispatcher into the context
+func WithDispatcher(msgDispatcher *dispatcher.Dispatcher) ProviderOption {
+	return func(opts *Provider) {
+		opts.dispatcher = msgDispatcher
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/did/core/resolver"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/introduction"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	didcommtrans "github.com/trustbloc/aries-framework-go/pkg/transport/http"
+)
+
+func TestNewProvider(t *testing.T) {
+	t.Run("test default dispatcher", func(t *testing.T) {
+		ctx, err := New()
+		require.NoError(t, err)
+		require.NotNil(t, ctx.Dispatcher())
+		require.Nil(t, ctx.OutboundTransport())
+		require.Nil(t, ctx.InboundTransport())
+	})
+
+	t.Run("test options", func(t *testing.T) {
+		transport := mock.NewOutboundTransport("success")
+		didProvider := didbasic.NewProvider()
+		didResolver := resolver.New()
+		storeProvider := memstore.NewProvider()
+		packer := legacy.New(didProvider)
+		msgDispatcher := dispatcher.New()
+		inbound, err := didcommtrans.NewInbound("localhost:0")
+		require.NoError(t, err)
+
+		ctx, err := New(WithOutboundTransport(transport), WithInboundTransport(inbound), WithDIDProvider(didProvider),
+			WithDIDResolver(didResolver), WithStorageProvider(storeProvider), WithPacker(packer),
+			WithDispatcher(msgDispatcher))
+		require.NoError(t, err)
+		require.Equal(t, transport, ctx.OutboundTransport())
+		require.Equal(t, inbound, ctx.InboundTransport())
+		require.Equal(t, didProvider, ctx.DIDProvider())
+		require.Equal(t, didResolver, ctx.DIDResolver())
+		require.Equal(t, storeProvider, ctx.StorageProvider())
+		require.Equal(t, packer, ctx.Packer())
+		require.Equal(t, msgDispatcher, ctx.Dispatcher())
+	})
+
+	t.Run("test service", func(t *testing.T) {
+		ctx, err := New()
+		require.NoError(t, err)
+
+		_, err = ctx.Service(introduction.ServiceName)
+		require.Error(t, err)
+
+		store, err := connection.NewConnectionStore(memstore.NewStore())
+		require.NoError(t, err)
+		svc, err := introduction.NewService(mock.NewOutboundTransport("success"), store, nil)
+		require.NoError(t, err)
+		require.NoError(t, ctx.Dispatcher().RegisterService(svc))
+
+		registered, err := ctx.Service(introduction.ServiceName)
+		require.NoError(t, err)
+		require.Equal(t, svc, registered)
+	})
+}
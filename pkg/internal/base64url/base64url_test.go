@@ -0,0 +1,24 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package base64url
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	for _, data := range []string{"aGk", "aGk="} {
+		decoded, err := Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, "hi", string(decoded))
+	}
+
+	_, err := Decode("not base64url!")
+	require.Error(t, err)
+}
@@ -7,22 +7,81 @@ SPDX-License-Identifier: Apache-2.0
 package introduction
 
 import (
-	"encoding/json"
+	"context"
 
 	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
 	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
 	"github.com/trustbloc/aries-framework-go/pkg/transport"
 )
 
+// MsgTypePrefix type prefix of the introduce protocol messages
+const MsgTypePrefix = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/"
+
 const (
 	introduceProposal = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/proposal"
 	introduceRequest  = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/request"
 	introduceResponse = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/introduce/1.0/response"
 )
 
-// SendProposal sends the introduction proposal
+// SendProposal sends the introduction proposal packed for the recipient keys of the destination,
+// authcrypted with the verkey of the sender DID option if the DID provider option holds it
 // https://github.com/hyperledger/aries-rfcs/tree/master/features/0028-introduce#proposal-1
-func SendProposal(proposal *didexchange.IntroductionProposal, destination string, transport transport.OutboundTransport) error {
+func SendProposal(proposal *didexchange.IntroductionProposal,
+	destination *dispatcher.Destination, transport transport.OutboundTransport, opts ...connection.SendOpt) error {
+	return SendProposalContext(context.Background(), proposal, destination, transport, opts...)
+}
+
+// SendProposalContext sends the introduction proposal, giving up once the context is done
+func SendProposalContext(ctx context.Context, proposal *didexchange.IntroductionProposal,
+	destination *dispatcher.Destination, ot transport.OutboundTransport, opts ...connection.SendOpt) error {
+	if err := prepareProposal(proposal); err != nil {
+		return err
+	}
+
+	_, err := connection.SendPackedContext(ctx, proposal, destination, ot, opts...)
+	return err
+}
+
+// SendRequest sends the introduction request packed as SendProposal does
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0028-introduce#request
+func SendRequest(request *didexchange.IntroductionRequest,
+	destination *dispatcher.Destination, transport transport.OutboundTransport, opts ...connection.SendOpt) error {
+	return SendRequestContext(context.Background(), request, destination, transport, opts...)
+}
+
+// SendRequestContext sends the introduction request, giving up once the context is done
+func SendRequestContext(ctx context.Context, request *didexchange.IntroductionRequest,
+	destination *dispatcher.Destination, ot transport.OutboundTransport, opts ...connection.SendOpt) error {
+	if err := prepareRequest(request); err != nil {
+		return err
+	}
+
+	_, err := connection.SendPackedContext(ctx, request, destination, ot, opts...)
+	return err
+}
+
+// SendResponse sends the introduction response packed as SendProposal does
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0028-introduce#response
+func SendResponse(response *didexchange.IntroductionResponse,
+	destination *dispatcher.Destination, transport transport.OutboundTransport, opts ...connection.SendOpt) error {
+	return SendResponseContext(context.Background(), response, destination, transport, opts...)
+}
+
+// SendResponseContext sends the introduction response, giving up once the context is done
+func SendResponseContext(ctx context.Context, response *didexchange.IntroductionResponse,
+	destination *dispatcher.Destination, ot transport.OutboundTransport, opts ...connection.SendOpt) error {
+	if err := prepareResponse(response); err != nil {
+		return err
+	}
+
+	_, err := connection.SendPackedContext(ctx, response, destination, ot, opts...)
+	return err
+}
+
+// prepareProposal validates the proposal and sets its type
+func prepareProposal(proposal *didexchange.IntroductionProposal) error {
 	if proposal == nil {
 		return errors.New("proposal cannot be nil")
 	}
@@ -31,13 +90,11 @@ func SendProposal(proposal *didexchange.IntroductionProposal, destination string
 	}
 
 	proposal.Type = introduceProposal
-	_, err := marshalAndSend(proposal, "Error Marshalling Send Introduction Proposal", destination, transport)
-	return err
+	return nil
 }
 
-// SendRequest sends the introduction request
-// https://github.com/hyperledger/aries-rfcs/tree/master/features/0028-introduce#request
-func SendRequest(request *didexchange.IntroductionRequest, destination string, transport transport.OutboundTransport) error {
+// prepareRequest validates the request and sets its type
+func prepareRequest(request *didexchange.IntroductionRequest) error {
 	if request == nil {
 		return errors.New("Request cannot be nil")
 	}
@@ -46,13 +103,11 @@ func SendRequest(request *didexchange.IntroductionRequest, destination string, t
 	}
 
 	request.Type = introduceRequest
-	_, err := marshalAndSend(request, "Error Marshalling Send Introduction Request", destination, transport)
-	return err
+	return nil
 }
 
-// SendResponse sends the introduction response
-// https://github.com/hyperledger/aries-rfcs/tree/master/features/0028-introduce#response
-func SendResponse(response *didexchange.IntroductionResponse, destination string, transport transport.OutboundTransport) error {
+// prepareResponse validates the response and sets its type
+func prepareResponse(response *didexchange.IntroductionResponse) error {
 	if response == nil {
 		return errors.New("Response cannot be nil")
 	}
@@ -61,14 +116,5 @@ func SendResponse(response *didexchange.IntroductionResponse, destination string
 	}
 
 	response.Type = introduceResponse
-	_, err := marshalAndSend(response, "Error Marshalling Send Introduction Response", destination, transport)
-	return err
-}
-
-func marshalAndSend(data interface{}, errorMsg, destination string, transport transport.OutboundTransport) (string, error) {
-	jsonString, err := json.Marshal(data)
-	if err != nil {
-		return "", errors.Wrapf(err, errorMsg)
-	}
-	return transport.Send(string(jsonString), destination)
+	return nil
 }
@@ -7,16 +7,33 @@ SPDX-License-Identifier: Apache-2.0
 package introduction
 
 import (
+	"context"
 	"testing"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
 	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
 	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
 )
 
 const destinationURL = "https://localhost:8090"
 const successResponse = "success"
 
+// newDestination returns the destination reached at destinationURL with the verkey of a new DID
+func newDestination(t *testing.T) *dispatcher.Destination {
+	theirDID, err := didbasic.NewProvider().CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	return &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+}
+
 /*---------------Test-----------------------*/
 func TestSendProposal(t *testing.T) {
 	transport := mock.NewOutboundTransport(successResponse)
@@ -27,21 +44,24 @@ func TestSendProposal(t *testing.T) {
 	}
 
 	// positive case
-	require.NoError(t, SendProposal(proposal, destinationURL, transport))
+	require.NoError(t, SendProposal(proposal, newDestination(t), transport))
 
 	// nil proposal
-	require.Error(t, SendProposal(nil, destinationURL, transport))
+	require.Error(t, SendProposal(nil, newDestination(t), transport))
 
 	// nil destination
-	require.Error(t, SendProposal(proposal, "", transport))
+	require.Error(t, SendProposal(proposal, nil, transport))
+
+	// no recipient keys to pack for
+	require.Error(t, SendProposal(proposal, &dispatcher.Destination{ServiceEndpoint: destinationURL}, transport))
 
 	// nil Descriptor
 	proposal.To = nil
-	require.Error(t, SendProposal(proposal, destinationURL, transport))
+	require.Error(t, SendProposal(proposal, newDestination(t), transport))
 
 	// nil name inside the Descriptor
 	proposal.To = &didexchange.IntroductionDescriptor{}
-	require.Error(t, SendProposal(proposal, destinationURL, transport))
+	require.Error(t, SendProposal(proposal, newDestination(t), transport))
 }
 
 func TestSendRequest(t *testing.T) {
@@ -53,21 +73,21 @@ func TestSendRequest(t *testing.T) {
 	}
 
 	// positive case
-	require.NoError(t, SendRequest(request, destinationURL, transport))
+	require.NoError(t, SendRequest(request, newDestination(t), transport))
 
 	// nil request
-	require.Error(t, SendRequest(nil, destinationURL, transport))
+	require.Error(t, SendRequest(nil, newDestination(t), transport))
 
 	// nil destination
-	require.Error(t, SendRequest(request, "", transport))
+	require.Error(t, SendRequest(request, nil, transport))
 
 	// nil IntroduceTo
 	request.IntroduceTo = nil
-	require.Error(t, SendRequest(request, destinationURL, transport))
+	require.Error(t, SendRequest(request, newDestination(t), transport))
 
 	// nil IntroduceTo name
 	request.IntroduceTo = &didexchange.RequestDescriptor{}
-	require.Error(t, SendRequest(request, destinationURL, transport))
+	require.Error(t, SendRequest(request, newDestination(t), transport))
 }
 
 func TestSendResponse(t *testing.T) {
@@ -79,19 +99,76 @@ func TestSendResponse(t *testing.T) {
 	}
 
 	// positive case
-	require.NoError(t, SendResponse(response, destinationURL, transport))
+	require.NoError(t, SendResponse(response, newDestination(t), transport))
 
 	// nil response
-	require.Error(t, SendResponse(nil, destinationURL, transport))
+	require.Error(t, SendResponse(nil, newDestination(t), transport))
 
 	// nil destination
-	require.Error(t, SendResponse(response, "", transport))
+	require.Error(t, SendResponse(response, nil, transport))
 
 	// nil thread
 	response.Thread = nil
-	require.Error(t, SendResponse(response, destinationURL, transport))
+	require.Error(t, SendResponse(response, newDestination(t), transport))
 
 	// nil thread id
 	response.Thread = &didexchange.Thread{}
-	require.Error(t, SendResponse(response, destinationURL, transport))
+	require.Error(t, SendResponse(response, newDestination(t), transport))
+}
+
+func TestSend_Packed(t *testing.T) {
+	transport := mock.NewRecordingTransport()
+	didProvider, theirDIDProvider := didbasic.NewProvider(), didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	theirDID, err := theirDIDProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	proposal := &didexchange.IntroductionProposal{
+		ID: "aosjfl341kd45",
+		To: &didexchange.IntroductionDescriptor{Name: "Bob"},
+	}
+	dest := &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+	require.NoError(t, SendProposal(proposal, dest, transport,
+		connection.WithSenderDIDProvider(didProvider), connection.WithSenderDID(myDID.DID)))
+
+	sent, err := transport.Last()
+	require.NoError(t, err)
+	envelope, err := legacy.New(theirDIDProvider).Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	unpacked, err := sent.Unpack(legacy.New(theirDIDProvider))
+	require.NoError(t, err)
+	recorded, err := unpacked.IntroductionProposal()
+	require.NoError(t, err)
+	require.Equal(t, proposal.ID, recorded.ID)
+}
+
+func TestSend_Context(t *testing.T) {
+	transport := mock.NewOutboundTransport(successResponse)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	proposal := &didexchange.IntroductionProposal{
+		ID: "aosjfl341kd45",
+		To: &didexchange.IntroductionDescriptor{Name: "Bob"},
+	}
+	require.Equal(t, context.Canceled, SendProposalContext(ctx, proposal, newDestination(t), transport))
+
+	request := &didexchange.IntroductionRequest{
+		ID:          "aosjfl341kd45",
+		IntroduceTo: &didexchange.RequestDescriptor{Name: "Bob"},
+	}
+	require.Equal(t, context.Canceled, SendRequestContext(ctx, request, newDestination(t), transport))
+
+	response := &didexchange.IntroductionResponse{
+		ID:     "ofjkwfl930or20",
+		Thread: &didexchange.Thread{ID: "aosjfl341kd45"},
+	}
+	require.Equal(t, context.Canceled, SendResponseContext(ctx, response, newDestination(t), transport))
 }
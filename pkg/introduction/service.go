@@ -0,0 +1,196 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introduction
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// ServiceName name of the introduce protocol service
+const ServiceName = "introduce"
+
+// InboundHandler is called with the decoded introduction proposal, request or response received by the service
+type InboundHandler func(msg interface{}) error
+
+// Service introduce protocol service, outbound messages are sent on the connection to their destination,
+// authcrypted with the verkey of our DID of the connection
+type Service struct {
+	transport   transport.OutboundTransport
+	store       *connection.ConnectionStore
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	handler     InboundHandler
+}
+
+// serviceOpts holds the options for the introduce protocol service
+type serviceOpts struct {
+	packer      pack.Packer
+	didProvider didprovider.Provider
+}
+
+// ServiceOpt is an introduce protocol service option
+type ServiceOpt func(opts *serviceOpts)
+
+// WithPacker the introduction messages are packed with the given packer,
+// legacy RFC 0019 and JWE envelopes are supported by default
+func WithPacker(packer pack.Packer) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.packer = packer
+	}
+}
+
+// WithDIDProvider the introduction messages are authcrypted with the verkey of our DID of the connection
+// held by the DID provider
+func WithDIDProvider(didProvider didprovider.Provider) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.didProvider = didProvider
+	}
+}
+
+type header struct {
+	Type string `json:"@type,omitempty"`
+}
+
+// NewService creates a new introduce protocol service sending messages on the connections of the given store,
+// inbound messages are passed to the handler if not nil
+func NewService(transport transport.OutboundTransport, store *connection.ConnectionStore, handler InboundHandler,
+	opts ...ServiceOpt) (*Service, error) {
+	if transport == nil || store == nil {
+		return nil, errors.New("transport and connection store are mandatory")
+	}
+
+	svcOpts := &serviceOpts{}
+	// Apply options
+	for _, opt := range opts {
+		opt(svcOpts)
+	}
+	if svcOpts.packer == nil {
+		svcOpts.packer = packers.NewDefault(svcOpts.didProvider)
+	}
+
+	return &Service{
+		transport:   transport,
+		store:       store,
+		packer:      svcOpts.packer,
+		didProvider: svcOpts.didProvider,
+		handler:     handler,
+	}, nil
+}
+
+// Name returns the name of the introduce protocol service
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// MsgTypes returns the message types accepted by the introduce protocol service
+func (s *Service) MsgTypes() []string {
+	return []string{MsgTypePrefix}
+}
+
+// HandleInbound decodes the introduction proposal, request or response and passes it to the inbound handler
+func (s *Service) HandleInbound(payload []byte) error {
+	msgHeader := &header{}
+	if err := json.Unmarshal(payload, msgHeader); err != nil {
+		return errors.Wrapf(err, "Unmarshal Introduction Message Error")
+	}
+
+	var msg interface{}
+	switch msgHeader.Type {
+	case introduceProposal:
+		msg = &didexchange.IntroductionProposal{}
+	case introduceRequest:
+		msg = &didexchange.IntroductionRequest{}
+	case introduceResponse:
+		msg = &didexchange.IntroductionResponse{}
+	default:
+		return errors.Errorf("unrecognized msgType: %s", msgHeader.Type)
+	}
+
+	if err := json.Unmarshal(payload, msg); err != nil {
+		return errors.Wrapf(err, "Unmarshal Introduction Message Error")
+	}
+
+	if s.handler == nil {
+		return nil
+	}
+
+	return s.handler(msg)
+}
+
+// HandleOutbound sends the introduction proposal, request or response to the destination on the connection
+// whose recipient keys it is packed for, authcrypted with the verkey of our DID of the connection
+func (s *Service) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	if destination == nil || destination.ServiceEndpoint == "" {
+		return errors.New("destination service endpoint is mandatory")
+	}
+
+	if err := prepare(msg); err != nil {
+		return err
+	}
+
+	record, err := s.connectionTo(destination)
+	if err != nil {
+		return err
+	}
+
+	senderKey, err := connection.SenderKey(s.didProvider, record.MyDID)
+	if err != nil {
+		return err
+	}
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Introduction Message Error")
+	}
+
+	envelope, err := pack.PackWithFormat(s.packer, record.EnvelopeFormat, msgJSON, senderKey,
+		destination.RecipientKeys)
+	if err != nil {
+		return errors.Wrapf(err, "failed to pack introduction message")
+	}
+
+	_, err = transport.SendEnvelope(context.Background(), s.transport, envelope, destination.ServiceEndpoint)
+	return err
+}
+
+// prepare validates the introduction proposal, request or response and sets its type
+func prepare(msg interface{}) error {
+	switch m := msg.(type) {
+	case *didexchange.IntroductionProposal:
+		return prepareProposal(m)
+	case *didexchange.IntroductionRequest:
+		return prepareRequest(m)
+	case *didexchange.IntroductionResponse:
+		return prepareResponse(m)
+	default:
+		return errors.Errorf("unsupported introduction message %T", msg)
+	}
+}
+
+// connectionTo returns the completed connection holding the recipient keys of the destination as their keys
+func (s *Service) connectionTo(destination *dispatcher.Destination) (*connection.ConnectionRecord, error) {
+	record, err := s.store.GetConnectionRecordByTheirKey(destination.RecipientKeys...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no connection found to %s", destination.ServiceEndpoint)
+	}
+	if record.State != connection.StateIDCompleted {
+		return nil, errors.Errorf("connection %s to %s isn't completed", record.ConnectionID,
+			destination.ServiceEndpoint)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package introduction
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/jwe"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+func TestService(t *testing.T) {
+	didProvider := didbasic.NewProvider()
+	store, dest := newConnection(t, didProvider, didbasic.NewProvider())
+
+	var received []interface{}
+	svc, err := NewService(mock.NewOutboundTransport(successResponse), store, func(msg interface{}) error {
+		received = append(received, msg)
+		return nil
+	}, WithDIDProvider(didProvider))
+	require.NoError(t, err)
+	require.Equal(t, ServiceName, svc.Name())
+
+	d := dispatcher.New()
+	require.NoError(t, d.RegisterService(svc))
+
+	proposal := &didexchange.IntroductionProposal{ID: "proposal-id", To: &didexchange.IntroductionDescriptor{Name: "Bob"}}
+	require.NoError(t, d.Send(ServiceName, proposal, dest))
+	require.Equal(t, introduceProposal, proposal.Type)
+	require.NoError(t, svc.HandleOutbound(&didexchange.IntroductionRequest{ID: "request-id",
+		IntroduceTo: &didexchange.RequestDescriptor{Name: "Bob"}}, dest))
+	require.NoError(t, svc.HandleOutbound(&didexchange.IntroductionResponse{ID: "response-id",
+		Thread: &didexchange.Thread{ID: "proposal-id"}}, dest))
+
+	// unsupported message and missing destination
+	require.Error(t, svc.HandleOutbound(&didexchange.Request{}, dest))
+	require.Error(t, svc.HandleOutbound(proposal, &dispatcher.Destination{}))
+	require.Error(t, svc.HandleOutbound(proposal, &dispatcher.Destination{ServiceEndpoint: destinationURL}))
+
+	// no connection to the destination
+	otherDID, err := didbasic.NewProvider().CreateLocalDID(nil)
+	require.NoError(t, err)
+	err = svc.HandleOutbound(proposal, &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(otherDID.VerKey)},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no connection found")
+
+	for _, msg := range []interface{}{
+		proposal,
+		&didexchange.IntroductionRequest{Type: introduceRequest, ID: "request-id"},
+		&didexchange.IntroductionResponse{Type: introduceResponse, ID: "response-id"},
+	} {
+		payload, err := json.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, d.Dispatch(payload))
+	}
+	require.Len(t, received, 3)
+	require.Equal(t, "proposal-id", received[0].(*didexchange.IntroductionProposal).ID)
+	require.Equal(t, "request-id", received[1].(*didexchange.IntroductionRequest).ID)
+	require.Equal(t, "response-id", received[2].(*didexchange.IntroductionResponse).ID)
+
+	require.Error(t, svc.HandleInbound([]byte(`{"@type":"`+MsgTypePrefix+`unknown"}`)))
+	require.Error(t, svc.HandleInbound([]byte(`invalid`)))
+
+	// without inbound handler
+	svc, err = NewService(mock.NewOutboundTransport(successResponse), store, nil)
+	require.NoError(t, err)
+	require.NoError(t, svc.HandleInbound([]byte(`{"@type":"`+introduceRequest+`"}`)))
+
+	// without DID provider there is no DID to authcrypt from
+	require.Error(t, svc.HandleOutbound(proposal, dest))
+
+	_, err = NewService(nil, store, nil)
+	require.Error(t, err)
+	_, err = NewService(mock.NewOutboundTransport(successResponse), nil, nil)
+	require.Error(t, err)
+}
+
+func TestService_Packing(t *testing.T) {
+	didProvider, theirDIDProvider := didbasic.NewProvider(), didbasic.NewProvider()
+	store, dest := newConnection(t, didProvider, theirDIDProvider)
+
+	transport := &recordingTransport{}
+	svc, err := NewService(transport, store, nil, WithDIDProvider(didProvider))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.HandleOutbound(&didexchange.IntroductionProposal{
+		ID: "proposal-id",
+		To: &didexchange.IntroductionDescriptor{Name: "Bob"},
+	}, dest))
+	require.Equal(t, destinationURL, transport.destination)
+
+	// authcrypted with the verkey of our DID of the connection
+	envelope, err := legacy.New(theirDIDProvider).Unpack([]byte(transport.data))
+	require.NoError(t, err)
+	record, err := store.GetConnectionRecord(connectionID)
+	require.NoError(t, err)
+	myDID, err := didProvider.GetLocalDIDInfo(record.MyDID)
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	proposal := &didexchange.IntroductionProposal{}
+	require.NoError(t, json.Unmarshal(envelope.Message, proposal))
+	require.Equal(t, "proposal-id", proposal.ID)
+	require.Equal(t, introduceProposal, proposal.Type)
+
+	// envelope format of the connection
+	record.EnvelopeFormat = pack.FormatJWE
+	require.NoError(t, store.SaveConnectionRecord(record))
+	require.NoError(t, svc.HandleOutbound(&didexchange.IntroductionResponse{
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: "proposal-id"},
+	}, dest))
+	envelope, err = jwe.New(theirDIDProvider).Unpack([]byte(transport.data))
+	require.NoError(t, err)
+	require.Equal(t, base58.Encode(myDID.VerKey), envelope.SenderKey)
+
+	// custom packer
+	svc, err = NewService(transport, store, nil, WithPacker(legacy.New(didProvider)), WithDIDProvider(didProvider))
+	require.NoError(t, err)
+	require.Error(t, svc.HandleOutbound(&didexchange.IntroductionResponse{
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: "proposal-id"},
+	}, dest))
+	record.EnvelopeFormat = ""
+	require.NoError(t, store.SaveConnectionRecord(record))
+	require.NoError(t, svc.HandleOutbound(&didexchange.IntroductionResponse{
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: "proposal-id"},
+	}, dest))
+
+	// the connection must be completed
+	record.State = connection.StateIDAbandoned
+	require.NoError(t, store.SaveConnectionRecord(record))
+	require.Error(t, svc.HandleOutbound(&didexchange.IntroductionResponse{
+		ID:     "response-id",
+		Thread: &didexchange.Thread{ID: "proposal-id"},
+	}, dest))
+}
+
+const connectionID = "connection-id"
+
+// newConnection saves a connection from our DID to theirs and returns its store and destination
+func newConnection(t *testing.T, didProvider, theirDIDProvider *didbasic.Provider) (*connection.ConnectionStore,
+	*dispatcher.Destination) {
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	theirDID, err := theirDIDProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	store, err := connection.NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+	dest := &dispatcher.Destination{
+		ServiceEndpoint: destinationURL,
+		RecipientKeys:   []string{base58.Encode(theirDID.VerKey)},
+	}
+	require.NoError(t, store.SaveConnectionRecord(&connection.ConnectionRecord{
+		ConnectionID:    connectionID,
+		State:           connection.StateIDCompleted,
+		MyDID:           myDID.DID,
+		ServiceEndpoint: dest.ServiceEndpoint,
+		RecipientKeys:   dest.RecipientKeys,
+	}))
+
+	return store, dest
+}
+
+// recordingTransport keeps the last message sent
+type recordingTransport struct {
+	data        string
+	destination string
+}
+
+func (r *recordingTransport) Send(data, destination string) (string, error) {
+	r.data = data
+	r.destination = destination
+	return successResponse, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/mem"
+	"github.com/trustbloc/did-common-go/pkg/diddoc"
+)
+
+// Agent mock agent holding a DID, the packer of its envelopes and the store of its connections,
+// the protocol services under test are created on top of them
+type Agent struct {
+	DIDProvider didprovider.Provider
+	DID         *didprovider.LocalDIDInfo
+	Packer      pack.Packer
+	Store       *connection.ConnectionStore
+	exchange    *connection.Exchange
+	// exchangeTransport records the exchange messages sent by the agent, Connect delivers them
+	exchangeTransport *mock.RecordingTransport
+}
+
+// New creates a new agent with a new DID, a packer of legacy RFC 0019 and JWE envelopes and no connection
+func New(t *testing.T) *Agent {
+	didProvider := didbasic.NewProvider()
+	did, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	packer := packers.NewDefault(didProvider)
+	store, err := connection.NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	exchangeTransport := mock.NewRecordingTransport()
+	exchange, err := connection.NewExchange(exchangeTransport, store,
+		connection.WithPacker(packer), connection.WithDIDProvider(didProvider))
+	require.NoError(t, err)
+
+	return &Agent{
+		DIDProvider:       didProvider,
+		DID:               did,
+		Packer:            packer,
+		Store:             store,
+		exchange:          exchange,
+		exchangeTransport: exchangeTransport,
+	}
+}
+
+// VerKey returns the base58 verkey of the DID of the agent
+func (a *Agent) VerKey() string {
+	return base58.Encode(a.DID.VerKey)
+}
+
+// ConnectTo saves a completed connection of the agent to the other agent reachable at the endpoint as if the
+// DID exchange took place, the messages sent on it are packed in the envelope format if set
+func (a *Agent) ConnectTo(t *testing.T, connectionID string, other *Agent, endpoint, format string) {
+	require.NoError(t, a.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+		ConnectionID:    connectionID,
+		State:           connection.StateIDCompleted,
+		MyDID:           a.DID.DID,
+		TheirDID:        other.DID.DID,
+		ServiceEndpoint: endpoint,
+		RecipientKeys:   []string{other.VerKey()},
+		EnvelopeFormat:  format,
+	}))
+}
+
+// Handler handles the envelopes received by an agent, such as the inbound envelope handler of the protocol
+// service under test
+type Handler func(envelope *pack.Envelope) ([]byte, error)
+
+// Serve starts receiving the envelopes sent to mem://<name> through the hub and passes them to the handler,
+// the returned function stops serving
+func (a *Agent) Serve(t *testing.T, hub *mem.Hub, name string, handler Handler) func() {
+	inbound := hub.Inbound(name)
+	require.NoError(t, inbound.Start(a.Packer, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		return handler(envelope)
+	}))
+
+	return func() {
+		require.NoError(t, inbound.Stop(context.Background()))
+	}
+}
+
+// Connect runs the DID exchange between the inviter and the invitee reachable at the given endpoints,
+// the invitation is made with the verkey of the DID of the inviter. The IDs of the connection of the inviter
+// and of the connection of the invitee are returned.
+func Connect(t *testing.T, inviter, invitee *Agent, inviterEndpoint, inviteeEndpoint string) (string, string) {
+	invitation := &didexchange.InviteMessage{
+		ID:              uuid.New().String(),
+		Label:           "inviter",
+		RecipientKeys:   []string{inviter.VerKey()},
+		ServiceEndpoint: inviterEndpoint,
+	}
+	_, err := inviter.exchange.GenerateInviteWithKeyAndEndpoint(invitation)
+	require.NoError(t, err)
+	require.NoError(t, invitee.exchange.ReceiveInvitation(invitation))
+	toInviter := &dispatcher.Destination{ServiceEndpoint: inviterEndpoint, RecipientKeys: invitation.RecipientKeys}
+
+	request := &didexchange.Request{
+		ID:         uuid.New().String(),
+		Label:      "invitee",
+		Thread:     &didexchange.Thread{PID: invitation.ID},
+		Connection: &didexchange.Connection{DID: invitee.DID.DID, DIDDoc: invitee.didDoc(t, inviteeEndpoint)},
+	}
+	require.NoError(t, invitee.exchange.SendExchangeRequest(request, toInviter))
+	deliver(t, invitee, inviter)
+
+	response := &didexchange.Response{ID: uuid.New().String(), Thread: &didexchange.Thread{ID: request.ID}}
+	require.NoError(t, connection.SignExchangeResponse(response,
+		&didexchange.Connection{DID: inviter.DID.DID, DIDDoc: inviter.didDoc(t, inviterEndpoint)},
+		inviter.DID.VerKey, inviter.DID.Secret))
+	require.NoError(t, inviter.exchange.SendExchangeResponse(response,
+		&dispatcher.Destination{ServiceEndpoint: inviteeEndpoint, RecipientKeys: []string{invitee.VerKey()}}))
+	deliver(t, inviter, invitee)
+
+	ack := &didexchange.Ack{ID: uuid.New().String(), Status: "OK", Thread: &didexchange.Thread{ID: request.ID}}
+	require.NoError(t, invitee.exchange.SendExchangeAck(ack, toInviter))
+	deliver(t, invitee, inviter)
+
+	return completedConnection(t, inviter, request.ID), completedConnection(t, invitee, request.ID)
+}
+
+// deliver passes the last exchange message sent by the sender to the exchange of the recipient
+func deliver(t *testing.T, sender, recipient *Agent) {
+	sent, err := sender.exchangeTransport.Last()
+	require.NoError(t, err)
+
+	unpacked, err := sent.Unpack(recipient.Packer)
+	require.NoError(t, err)
+	require.NoError(t, recipient.exchange.HandleInbound([]byte(unpacked.Data)))
+}
+
+// completedConnection returns the ID of the connection of the thread, which must be completed
+func completedConnection(t *testing.T, a *Agent, threadID string) string {
+	record, err := a.Store.GetConnectionRecordByThreadID(threadID)
+	require.NoError(t, err)
+	require.Equal(t, connection.StateIDCompleted, record.State)
+
+	return record.ConnectionID
+}
+
+// didDoc returns the DID document of the agent with its verkey and the endpoint as service
+func (a *Agent) didDoc(t *testing.T, endpoint string) *diddoc.DIDDoc {
+	didDoc := &diddoc.DIDDoc{}
+	require.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(`{
+		"@context": ["https://w3id.org/did/v1"],
+		"id": "%[1]s",
+		"publicKey": [{"id": "%[1]s#key-1", "type": "Ed25519VerificationKey2018", "controller": "%[1]s",
+			"publicKeyBase58": "%[2]s"}],
+		"service": [{"id": "%[1]s;did-communication", "type": "did-communication", "serviceEndpoint": "%[3]s"}]
+	}`, a.DID.DID, a.VerKey(), endpoint)), didDoc))
+
+	return didDoc
+}
@@ -0,0 +1,67 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/mem"
+)
+
+func TestConnect(t *testing.T) {
+	alice, bob := New(t), New(t)
+
+	aliceConnection, bobConnection := Connect(t, alice, bob, "mem://alice", "mem://bob")
+
+	// the inviter reaches the invitee where the exchange response was sent
+	record, err := alice.Store.GetConnectionRecord(aliceConnection)
+	require.NoError(t, err)
+	require.Equal(t, alice.DID.DID, record.MyDID)
+	require.Equal(t, bob.DID.DID, record.TheirDID)
+	require.Equal(t, "mem://bob", record.ServiceEndpoint)
+	require.Equal(t, []string{bob.VerKey()}, record.TheirKeys())
+
+	// the invitee reaches the inviter at the endpoint of the invitation
+	record, err = bob.Store.GetConnectionRecord(bobConnection)
+	require.NoError(t, err)
+	require.Equal(t, bob.DID.DID, record.MyDID)
+	require.Equal(t, alice.DID.DID, record.TheirDID)
+	require.Equal(t, "mem://alice", record.ServiceEndpoint)
+	require.Equal(t, []string{alice.VerKey()}, record.TheirKeys())
+}
+
+func TestAgent_ConnectTo(t *testing.T) {
+	alice, bob := New(t), New(t)
+
+	bob.ConnectTo(t, "alice-connection", alice, "mem://alice", pack.FormatJWE)
+
+	record, err := bob.Store.GetConnectionRecord("alice-connection")
+	require.NoError(t, err)
+	require.Equal(t, bob.DID.DID, record.MyDID)
+	require.Equal(t, []string{alice.VerKey()}, record.RecipientKeys)
+	require.Equal(t, pack.FormatJWE, record.EnvelopeFormat)
+}
+
+func TestAgent_Serve(t *testing.T) {
+	hub := mem.NewHub()
+	alice, bob := New(t), New(t)
+
+	stop := alice.Serve(t, hub, "alice", func(envelope *pack.Envelope) ([]byte, error) {
+		return append([]byte("re: "), envelope.Message...), nil
+	})
+	defer stop()
+
+	envelope, err := bob.Packer.Pack([]byte("hi"), bob.VerKey(), []string{alice.VerKey()})
+	require.NoError(t, err)
+	reply, err := transport.SendEnvelope(context.Background(), hub.Outbound(), envelope, "mem://alice")
+	require.NoError(t, err)
+	require.Equal(t, "re: hi", string(reply))
+}
@@ -0,0 +1,275 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// AnyCall scripts the replies to every call
+const AnyCall = -1
+
+// Sent is a message sent through the recording transport
+type Sent struct {
+	Data        string
+	Destination string
+	// MediaType of the messages sent with SendMessage, empty for the ones sent with Send
+	MediaType string
+}
+
+// Decode decodes the JSON message into v
+func (s Sent) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(s.Data), v)
+}
+
+// Unpack returns the message unpacked with the packer, for transports sending packed envelopes
+func (s Sent) Unpack(packer pack.Packer) (Sent, error) {
+	envelope, err := packer.Unpack([]byte(s.Data))
+	if err != nil {
+		return Sent{}, err
+	}
+
+	return Sent{Data: string(envelope.Message), Destination: s.Destination, MediaType: s.MediaType}, nil
+}
+
+// Type returns the @type of the JSON message, empty if it can't be decoded
+func (s Sent) Type() string {
+	msgHeader := &struct {
+		Type string `json:"@type"`
+	}{}
+	if err := s.Decode(msgHeader); err != nil {
+		return ""
+	}
+
+	return msgHeader.Type
+}
+
+// ExchangeRequest decodes the message as a DID exchange request
+func (s Sent) ExchangeRequest() (*didexchange.Request, error) {
+	request := &didexchange.Request{}
+	return request, s.Decode(request)
+}
+
+// ExchangeResponse decodes the message as a DID exchange response
+func (s Sent) ExchangeResponse() (*didexchange.Response, error) {
+	response := &didexchange.Response{}
+	return response, s.Decode(response)
+}
+
+// ExchangeAck decodes the message as a DID exchange ack
+func (s Sent) ExchangeAck() (*didexchange.Ack, error) {
+	ack := &didexchange.Ack{}
+	return ack, s.Decode(ack)
+}
+
+// IntroductionProposal decodes the message as an introduction proposal
+func (s Sent) IntroductionProposal() (*didexchange.IntroductionProposal, error) {
+	proposal := &didexchange.IntroductionProposal{}
+	return proposal, s.Decode(proposal)
+}
+
+// IntroductionRequest decodes the message as an introduction request
+func (s Sent) IntroductionRequest() (*didexchange.IntroductionRequest, error) {
+	request := &didexchange.IntroductionRequest{}
+	return request, s.Decode(request)
+}
+
+// IntroductionResponse decodes the message as an introduction response
+func (s Sent) IntroductionResponse() (*didexchange.IntroductionResponse, error) {
+	response := &didexchange.IntroductionResponse{}
+	return response, s.Decode(response)
+}
+
+// Matcher tells whether a sent message matches
+type Matcher func(sent Sent) bool
+
+// ToDestination matches the messages sent to the destination
+func ToDestination(destination string) Matcher {
+	return func(sent Sent) bool {
+		return sent.Destination == destination
+	}
+}
+
+// OfType matches the JSON messages of the given @type
+func OfType(msgType string) Matcher {
+	return func(sent Sent) bool {
+		return sent.Type() == msgType
+	}
+}
+
+// WithID matches the JSON messages with the given @id
+func WithID(id string) Matcher {
+	return func(sent Sent) bool {
+		msgHeader := &struct {
+			ID string `json:"@id"`
+		}{}
+		return sent.Decode(msgHeader) == nil && msgHeader.ID == id
+	}
+}
+
+type reply struct {
+	response string
+	err      error
+}
+
+type script struct {
+	destination string
+	// anyDestination the call index counts the calls to all the destinations
+	anyDestination bool
+	call           int
+}
+
+// RecordingTransport mock outbound transport recording the messages sent through it, its replies can be
+// scripted per destination or for any destination and per call index, it replies with an empty response otherwise
+type RecordingTransport struct {
+	sent    []Sent
+	replies map[script]reply
+	lock    sync.Mutex
+}
+
+// NewRecordingTransport new RecordingTransport instance
+func NewRecordingTransport() *RecordingTransport {
+	return &RecordingTransport{replies: make(map[script]reply)}
+}
+
+// Respond scripts the response to the call with the given index (starting at 0) to the destination
+func (r *RecordingTransport) Respond(destination string, call int, response string) *RecordingTransport {
+	return r.script(script{destination: destination, call: call}, reply{response: response})
+}
+
+// Fail scripts the error returned by the call with the given index (starting at 0) to the destination
+func (r *RecordingTransport) Fail(destination string, call int, err error) *RecordingTransport {
+	return r.script(script{destination: destination, call: call}, reply{err: err})
+}
+
+// RespondAny scripts the response to the call with the given index (starting at 0) among the calls
+// to all the destinations, the destination scripts take precedence
+func (r *RecordingTransport) RespondAny(call int, response string) *RecordingTransport {
+	return r.script(script{anyDestination: true, call: call}, reply{response: response})
+}
+
+// FailAny scripts the error returned by the call with the given index (starting at 0) among the calls
+// to all the destinations, the destination scripts take precedence
+func (r *RecordingTransport) FailAny(call int, err error) *RecordingTransport {
+	return r.script(script{anyDestination: true, call: call}, reply{err: err})
+}
+
+func (r *RecordingTransport) script(s script, rep reply) *RecordingTransport {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.replies[s] = rep
+	return r
+}
+
+// Send implementation of OutboundTransport.Send api, the message is recorded even if the reply is an error
+func (r *RecordingTransport) Send(data string, destination string) (string, error) {
+	return r.record(Sent{Data: data, Destination: destination})
+}
+
+// record records the message and returns the scripted reply of the call
+func (r *RecordingTransport) record(sent Sent) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	call := len(r.filter(ToDestination(sent.Destination)))
+	total := len(r.sent)
+	r.sent = append(r.sent, sent)
+
+	for _, s := range []script{
+		{destination: sent.Destination, call: call},
+		{destination: sent.Destination, call: AnyCall},
+		{anyDestination: true, call: total},
+		{anyDestination: true, call: AnyCall},
+	} {
+		if rep, ok := r.replies[s]; ok {
+			return rep.response, rep.err
+		}
+	}
+
+	return "", nil
+}
+
+// SendContext implementation of ContextOutboundTransport.SendContext api, nothing is recorded if the context
+// is done
+func (r *RecordingTransport) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return r.Send(data, destination)
+}
+
+// SendMessage implementation of MessageOutboundTransport.SendMessage api, the message is recorded along with
+// its media type, the scripted response has the same media type. Nothing is recorded if the context is done.
+func (r *RecordingTransport) SendMessage(ctx context.Context, msg *transport.Message,
+	destination string) (*transport.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read message to %s", destination)
+	}
+
+	resp, err := r.record(Sent{Data: string(data), Destination: destination, MediaType: msg.MediaType})
+	if err != nil || resp == "" {
+		return nil, err
+	}
+
+	return transport.NewMessage(msg.MediaType, []byte(resp)), nil
+}
+
+// Sent returns the messages sent so far, matching all the matchers if any
+func (r *RecordingTransport) Sent(matchers ...Matcher) []Sent {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.filter(matchers...)
+}
+
+// Last returns the last message sent matching all the matchers if any, an error if there is none
+func (r *RecordingTransport) Last(matchers ...Matcher) (Sent, error) {
+	sent := r.Sent(matchers...)
+	if len(sent) == 0 {
+		return Sent{}, errors.New("no matching message sent")
+	}
+
+	return sent[len(sent)-1], nil
+}
+
+// Reset forgets the messages sent so far, so the call indexes of the scripts restart at 0.
+// The scripted replies are kept, create a new transport to clear them.
+func (r *RecordingTransport) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.sent = nil
+}
+
+func (r *RecordingTransport) filter(matchers ...Matcher) []Sent {
+	var matching []Sent
+SentLoop:
+	for _, sent := range r.sent {
+		for _, match := range matchers {
+			if !match(sent) {
+				continue SentLoop
+			}
+		}
+		matching = append(matching, sent)
+	}
+
+	return matching
+}
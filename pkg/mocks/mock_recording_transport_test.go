@@ -0,0 +1,233 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const (
+	requestType  = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"
+	responseType = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/response"
+	ackType      = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/ack"
+	alice        = "https://alice.example.com"
+	bob          = "https://bob.example.com"
+)
+
+func TestRecordingTransport_Record(t *testing.T) {
+	r := NewRecordingTransport()
+	require.Empty(t, r.Sent())
+
+	request := `{"@type":"` + requestType + `","@id":"request-1","label":"Bob"}`
+	resp, err := r.Send(request, alice)
+	require.NoError(t, err)
+	require.Empty(t, resp)
+
+	response := `{"@type":"` + responseType + `","@id":"response-1"}`
+	_, err = r.SendContext(context.Background(), response, bob)
+	require.NoError(t, err)
+
+	_, err = r.Send("not json", bob)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.SendContext(ctx, request, alice)
+	require.Equal(t, context.Canceled, err)
+
+	require.Equal(t, []Sent{
+		{Data: request, Destination: alice},
+		{Data: response, Destination: bob},
+		{Data: "not json", Destination: bob},
+	}, r.Sent())
+	require.Len(t, r.Sent(ToDestination(bob)), 2)
+	require.Equal(t, []Sent{{Data: response, Destination: bob}}, r.Sent(ToDestination(bob), OfType(responseType)))
+	require.Equal(t, []Sent{{Data: request, Destination: alice}}, r.Sent(WithID("request-1")))
+	require.Empty(t, r.Sent(ToDestination(alice), OfType(responseType)))
+
+	last, err := r.Last(ToDestination(bob))
+	require.NoError(t, err)
+	require.Equal(t, "not json", last.Data)
+	require.Empty(t, last.Type())
+
+	_, err = r.Last(OfType(ackType))
+	require.Error(t, err)
+
+	r.Reset()
+	require.Empty(t, r.Sent())
+}
+
+func TestRecordingTransport_Script(t *testing.T) {
+	errAlice := errors.New("alice is unreachable")
+	errFourth := errors.New("fourth call failed")
+
+	r := NewRecordingTransport().
+		RespondAny(AnyCall, "default").
+		Respond(bob, AnyCall, "bob").
+		Respond(bob, 1, "bob again").
+		Fail(alice, 0, errAlice).
+		FailAny(3, errFourth)
+
+	_, err := r.Send("1", alice)
+	require.Equal(t, errAlice, err)
+
+	resp, err := r.Send("2", bob)
+	require.NoError(t, err)
+	require.Equal(t, "bob", resp)
+
+	// the destination scripts take precedence over the global call index
+	resp, err = r.Send("3", bob)
+	require.NoError(t, err)
+	require.Equal(t, "bob again", resp)
+
+	_, err = r.Send("4", alice)
+	require.Equal(t, errFourth, err)
+
+	resp, err = r.Send("5", alice)
+	require.NoError(t, err)
+	require.Equal(t, "default", resp)
+
+	// failed calls are recorded
+	require.Len(t, r.Sent(), 5)
+
+	// scripts are kept on reset, call indexes restart
+	r.Reset()
+	_, err = r.Send("1", alice)
+	require.Equal(t, errAlice, err)
+
+	resp, err = NewRecordingTransport().Send("1", alice)
+	require.NoError(t, err)
+	require.Empty(t, resp)
+
+	// an empty destination is a destination like any other
+	r = NewRecordingTransport().Respond("", AnyCall, "empty")
+	resp, err = r.Send("1", alice)
+	require.NoError(t, err)
+	require.Empty(t, resp)
+	resp, err = r.Send("2", "")
+	require.NoError(t, err)
+	require.Equal(t, "empty", resp)
+}
+
+func TestRecordingTransport_SendMessage(t *testing.T) {
+	r := NewRecordingTransport().Respond(bob, 0, "reply").Fail(bob, 1, errors.New("bob is unreachable"))
+
+	resp, err := r.SendMessage(context.Background(), transport.NewMessage(transport.MediaTypeJSON, []byte("1")), alice)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	resp, err = r.SendMessage(context.Background(), transport.NewMessage(transport.MediaTypeEnvelope, []byte("2")), bob)
+	require.NoError(t, err)
+	require.Equal(t, transport.MediaTypeEnvelope, resp.MediaType)
+	data, err := resp.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, "reply", string(data))
+
+	_, err = r.SendMessage(context.Background(), transport.NewMessage(transport.MediaTypeEnvelope, nil), bob)
+	require.EqualError(t, err, "bob is unreachable")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.SendMessage(ctx, transport.NewMessage(transport.MediaTypeEnvelope, nil), bob)
+	require.Equal(t, context.Canceled, err)
+
+	_, err = r.SendMessage(context.Background(), &transport.Message{Body: iotest.ErrReader(errors.New("read error"))}, bob)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read message")
+
+	require.Equal(t, []Sent{
+		{Data: "1", Destination: alice, MediaType: transport.MediaTypeJSON},
+		{Data: "2", Destination: bob, MediaType: transport.MediaTypeEnvelope},
+		{Data: "", Destination: bob, MediaType: transport.MediaTypeEnvelope},
+	}, r.Sent())
+
+	// the packed envelopes are sent with their media type
+	_, err = transport.SendEnvelope(context.Background(), r, []byte("envelope"), alice)
+	require.NoError(t, err)
+	last, err := r.Last()
+	require.NoError(t, err)
+	require.Equal(t, Sent{Data: "envelope", Destination: alice, MediaType: transport.MediaTypeEnvelope}, last)
+}
+
+func TestSent_Decode(t *testing.T) {
+	r := NewRecordingTransport()
+	msgs := []string{
+		`{"@type":"` + requestType + `","@id":"request-1","label":"Bob"}`,
+		`{"@type":"` + responseType + `","@id":"response-1","~thread":{"@thid":"request-1"}}`,
+		`{"@type":"` + ackType + `","@id":"ack-1","status":"OK"}`,
+		`{"@id":"proposal-1","to":{"@name":"Carol"}}`,
+		`{"@id":"request-2","please_introduce_to":{"@name":"Carol"}}`,
+		`{"@id":"response-2","@approve":true}`,
+	}
+	for _, msg := range msgs {
+		_, err := r.Send(msg, alice)
+		require.NoError(t, err)
+	}
+	sent := r.Sent()
+
+	request, err := sent[0].ExchangeRequest()
+	require.NoError(t, err)
+	require.Equal(t, "Bob", request.Label)
+
+	response, err := sent[1].ExchangeResponse()
+	require.NoError(t, err)
+	require.Equal(t, "request-1", response.Thread.ID)
+
+	ack, err := sent[2].ExchangeAck()
+	require.NoError(t, err)
+	require.Equal(t, "OK", ack.Status)
+
+	proposal, err := sent[3].IntroductionProposal()
+	require.NoError(t, err)
+	require.Equal(t, "Carol", proposal.To.Name)
+
+	introRequest, err := sent[4].IntroductionRequest()
+	require.NoError(t, err)
+	require.Equal(t, "Carol", introRequest.IntroduceTo.Name)
+
+	introResponse, err := sent[5].IntroductionResponse()
+	require.NoError(t, err)
+	require.True(t, introResponse.Approve)
+
+	_, err = Sent{Data: "not json"}.ExchangeRequest()
+	require.Error(t, err)
+}
+
+func TestSent_Unpack(t *testing.T) {
+	sent := Sent{Data: "envelope", Destination: alice}
+
+	unpacked, err := sent.Unpack(&mockPacker{message: `{"@type":"` + ackType + `"}`})
+	require.NoError(t, err)
+	require.Equal(t, alice, unpacked.Destination)
+	require.Equal(t, ackType, unpacked.Type())
+
+	_, err = sent.Unpack(&mockPacker{err: errors.New("unpack error")})
+	require.EqualError(t, err, "unpack error")
+}
+
+type mockPacker struct {
+	message string
+	err     error
+}
+
+func (m *mockPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	return payload, m.err
+}
+
+func (m *mockPacker) Unpack(envelope []byte) (*pack.Envelope, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &pack.Envelope{Message: []byte(m.message)}, nil
+}
@@ -6,7 +6,10 @@ SPDX-License-Identifier: Apache-2.0
 
 package mock
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // OutboundTransport mock outbound transport structure
 type OutboundTransport struct {
@@ -26,3 +29,13 @@ func (transport *OutboundTransport) Send(data string, destination string) (strin
 
 	return transport.ExpectedResponse, nil
 }
+
+// SendContext implementation of ContextOutboundTransport.SendContext api, the context error is returned
+// if it is done
+func (transport *OutboundTransport) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return transport.Send(data, destination)
+}
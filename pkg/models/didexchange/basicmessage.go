@@ -0,0 +1,17 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package didexchange
+
+// BasicMessage basic message structure
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0095-basic-message#reference
+type BasicMessage struct {
+	Type         string        `json:"@type,omitempty"`
+	ID           string        `json:"@id,omitempty"`
+	Content      string        `json:"content,omitempty"`
+	SentTime     string        `json:"sent_time,omitempty"`
+	Localization *Localization `json:"~l10n,omitempty"`
+}
@@ -8,7 +8,8 @@ package didexchange
 
 // Thread thread data
 type Thread struct {
-	ID string `json:"@thid,omitempty"`
+	ID  string `json:"@thid,omitempty"`
+	PID string `json:"@pthid,omitempty"`
 }
 
 // ImageAttachment structure for image attachment data
@@ -26,9 +27,24 @@ type ImageContent struct {
 	SHA256    string `json:"@sha256,omitempty"`
 }
 
-// Time time related data structure
+const (
+	// ReturnRouteAll asks the other party to send all its messages back on the return route (see Transport)
+	ReturnRouteAll = "all"
+	// ReturnRouteThread asks the other party to send the messages of the thread back on the return route
+	ReturnRouteThread = "thread"
+)
+
+// Transport RFC 0092 ~transport decorator, ReturnRoute tells the other party which messages to send back
+// on the connection the message was received on: "none", "all" or "thread"
+type Transport struct {
+	ReturnRoute string `json:"return_route,omitempty"`
+}
+
+// Time time related data structure, InTime and OutTime are the RFC 0032 ~timing decorator times
 type Time struct {
 	Expires string `json:"@expires_time,omitempty"`
+	InTime  string `json:"in_time,omitempty"`
+	OutTime string `json:"out_time,omitempty"`
 }
 
 // Localization localization data structure
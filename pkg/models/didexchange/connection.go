@@ -25,6 +25,7 @@ type Request struct {
 	ID         string      `json:"@id,omitempty"`
 	Label      string      `json:"label,omitempty"`
 	Connection *Connection `json:"connection,omitempty"`
+	Thread     *Thread     `json:"~thread,omitempty"`
 }
 
 // Response defines a2a exchange response
@@ -35,6 +36,14 @@ type Response struct {
 	Thread              *Thread              `json:"~thread,omitempty"`
 }
 
+// Ack defines a2a exchange acknowledgement
+type Ack struct {
+	Type   string  `json:"@type,omitempty"`
+	ID     string  `json:"@id,omitempty"`
+	Status string  `json:"status,omitempty"`
+	Thread *Thread `json:"~thread,omitempty"`
+}
+
 // ConnectionSignature connection signature
 type ConnectionSignature struct {
 	Type       string `json:"@type,omitempty"`
@@ -0,0 +1,23 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package didexchange
+
+// ProblemReport problem report structure
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0035-report-problem
+type ProblemReport struct {
+	Type        string              `json:"@type,omitempty"`
+	ID          string              `json:"@id,omitempty"`
+	Thread      *Thread             `json:"~thread,omitempty"`
+	Description *ProblemDescription `json:"description,omitempty"`
+	Impact      string              `json:"impact,omitempty"`
+}
+
+// ProblemDescription problem description structure
+type ProblemDescription struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"en,omitempty"`
+}
@@ -0,0 +1,27 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package didexchange
+
+// Ping trust ping structure
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0048-trust-ping#messages
+type Ping struct {
+	Type              string     `json:"@type,omitempty"`
+	ID                string     `json:"@id,omitempty"`
+	Comment           string     `json:"comment,omitempty"`
+	ResponseRequested bool       `json:"response_requested"`
+	Timing            *Time      `json:"~timing,omitempty"`
+	Transport         *Transport `json:"~transport,omitempty"`
+}
+
+// PingResponse trust ping response structure
+type PingResponse struct {
+	Type    string  `json:"@type,omitempty"`
+	ID      string  `json:"@id,omitempty"`
+	Comment string  `json:"comment,omitempty"`
+	Thread  *Thread `json:"~thread,omitempty"`
+	Timing  *Time   `json:"~timing,omitempty"`
+}
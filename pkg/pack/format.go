@@ -0,0 +1,109 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pack
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatLegacy libsodium based envelope of Aries RFC 0019
+	FormatLegacy = "legacy"
+	// FormatJWE JWE envelope
+	FormatJWE = "jwe"
+)
+
+// FormatPacker packs messages in the envelope format chosen for every message
+type FormatPacker interface {
+	Packer
+
+	// PackFormat encrypts the payload as Pack does, in the given envelope format
+	PackFormat(format string, payload []byte, senderKey string, recipientKeys []string) ([]byte, error)
+}
+
+// MultiPacker packs messages with the packer of the format chosen for every message, the default one otherwise,
+// and unpacks envelopes with the packer of the format detected from the received bytes
+type MultiPacker struct {
+	packers       map[string]Packer
+	defaultFormat string
+}
+
+// NewMultiPacker creates a new packer for the given formats, packers are keyed by format
+func NewMultiPacker(defaultFormat string, packers map[string]Packer) (*MultiPacker, error) {
+	if _, ok := packers[defaultFormat]; !ok {
+		return nil, errors.Errorf("no packer for default format %s", defaultFormat)
+	}
+
+	return &MultiPacker{packers: packers, defaultFormat: defaultFormat}, nil
+}
+
+// Pack encrypts the payload in the default format
+func (m *MultiPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	return m.PackFormat(m.defaultFormat, payload, senderKey, recipientKeys)
+}
+
+// PackFormat encrypts the payload in the given format
+func (m *MultiPacker) PackFormat(format string, payload []byte, senderKey string,
+	recipientKeys []string) ([]byte, error) {
+	packer, ok := m.packers[format]
+	if !ok {
+		return nil, errors.Errorf("unsupported envelope format %s", format)
+	}
+
+	return packer.Pack(payload, senderKey, recipientKeys)
+}
+
+// Unpack decrypts the envelope with the packer of its format
+func (m *MultiPacker) Unpack(envelope []byte) (*Envelope, error) {
+	format, err := DetectFormat(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	packer, ok := m.packers[format]
+	if !ok {
+		return nil, errors.Errorf("unsupported envelope format %s", format)
+	}
+
+	return packer.Unpack(envelope)
+}
+
+// PackWithFormat packs the payload in the given envelope format if the packer supports envelope formats,
+// in the default format of the packer if the format is empty
+func PackWithFormat(packer Packer, format string, payload []byte, senderKey string,
+	recipientKeys []string) ([]byte, error) {
+	if format == "" {
+		return packer.Pack(payload, senderKey, recipientKeys)
+	}
+
+	formatPacker, ok := packer.(FormatPacker)
+	if !ok {
+		return nil, errors.Errorf("packer doesn't support envelope format %s", format)
+	}
+
+	return formatPacker.PackFormat(format, payload, senderKey, recipientKeys)
+}
+
+// DetectFormat returns the format of the envelope: both formats carry a protected header,
+// the recipients are part of the protected header of legacy envelopes while they are top level JWE members
+func DetectFormat(envelope []byte) (string, error) {
+	members := &struct {
+		Protected  string          `json:"protected"`
+		Recipients json.RawMessage `json:"recipients"`
+	}{}
+	if err := json.Unmarshal(envelope, members); err != nil || members.Protected == "" {
+		return "", errors.New("not a DIDComm envelope")
+	}
+
+	if len(members.Recipients) > 0 {
+		return FormatJWE, nil
+	}
+
+	return FormatLegacy, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	format, err := DetectFormat([]byte(`{"protected":"e30","iv":"","ciphertext":"","tag":""}`))
+	require.NoError(t, err)
+	require.Equal(t, FormatLegacy, format)
+
+	format, err = DetectFormat([]byte(`{"protected":"e30","recipients":[{"header":{"kid":"key"}}]}`))
+	require.NoError(t, err)
+	require.Equal(t, FormatJWE, format)
+
+	for _, envelope := range []string{"invalid", `{}`, `{"@type":"plaintext"}`} {
+		_, err = DetectFormat([]byte(envelope))
+		require.Error(t, err)
+	}
+}
+
+func TestMultiPacker(t *testing.T) {
+	legacyPacker := &formatPacker{format: FormatLegacy}
+	jwePacker := &formatPacker{format: FormatJWE}
+
+	_, err := NewMultiPacker(FormatJWE, map[string]Packer{FormatLegacy: legacyPacker})
+	require.Error(t, err)
+
+	p, err := NewMultiPacker(FormatLegacy, map[string]Packer{FormatLegacy: legacyPacker, FormatJWE: jwePacker})
+	require.NoError(t, err)
+
+	envelope, err := p.Pack([]byte("msg"), "", []string{"key"})
+	require.NoError(t, err)
+	require.Equal(t, FormatLegacy, string(envelope))
+
+	envelope, err = p.PackFormat(FormatJWE, []byte("msg"), "", []string{"key"})
+	require.NoError(t, err)
+	require.Equal(t, FormatJWE, string(envelope))
+
+	_, err = p.PackFormat("other", []byte("msg"), "", []string{"key"})
+	require.Error(t, err)
+
+	// unpacked by the packer of the detected format
+	unpacked, err := p.Unpack([]byte(`{"protected":"e30","recipients":[]}`))
+	require.NoError(t, err)
+	require.Equal(t, FormatJWE, string(unpacked.Message))
+
+	unpacked, err = p.Unpack([]byte(`{"protected":"e30"}`))
+	require.NoError(t, err)
+	require.Equal(t, FormatLegacy, string(unpacked.Message))
+
+	_, err = p.Unpack([]byte(`{"@type":"plaintext"}`))
+	require.Error(t, err)
+
+	p, err = NewMultiPacker(FormatLegacy, map[string]Packer{FormatLegacy: legacyPacker})
+	require.NoError(t, err)
+	_, err = p.Unpack([]byte(`{"protected":"e30","recipients":[]}`))
+	require.Error(t, err)
+}
+
+// formatPacker packs and unpacks to the name of its format
+type formatPacker struct {
+	format string
+}
+
+func (f *formatPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	if len(recipientKeys) == 0 {
+		return nil, errors.New("no recipient")
+	}
+	return []byte(f.format), nil
+}
+
+func (f *formatPacker) Unpack(envelope []byte) (*Envelope, error) {
+	return &Envelope{Message: []byte(f.format)}, nil
+}
+
+func TestPackWithFormat(t *testing.T) {
+	legacyPacker := &formatPacker{format: FormatLegacy}
+	jwePacker := &formatPacker{format: FormatJWE}
+	p, err := NewMultiPacker(FormatLegacy, map[string]Packer{FormatLegacy: legacyPacker, FormatJWE: jwePacker})
+	require.NoError(t, err)
+
+	envelope, err := PackWithFormat(p, "", []byte("msg"), "", []string{"key"})
+	require.NoError(t, err)
+	require.Equal(t, FormatLegacy, string(envelope))
+
+	envelope, err = PackWithFormat(p, FormatJWE, []byte("msg"), "", []string{"key"})
+	require.NoError(t, err)
+	require.Equal(t, FormatJWE, string(envelope))
+
+	_, err = PackWithFormat(p, "unknown", []byte("msg"), "", []string{"key"})
+	require.Error(t, err)
+
+	// the envelope format of plain packers can't be chosen
+	envelope, err = PackWithFormat(legacyPacker, "", []byte("msg"), "", []string{"key"})
+	require.NoError(t, err)
+	require.Equal(t, FormatLegacy, string(envelope))
+	_, err = PackWithFormat(legacyPacker, FormatJWE, []byte("msg"), "", []string{"key"})
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keyconv
+
+import (
+	"crypto/sha512"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// curve25519P is the prime 2^255 - 19 of the field shared by Ed25519 and Curve25519
+var curve25519P, _ = new(big.Int).SetString(
+	"57896044618658097711785492504343953926634992332820282019728792003956564819949", 10)
+
+// PublicEd25519toCurve25519 converts the Ed25519 verkey to the X25519 public key of the same key pair,
+// the Montgomery u coordinate is derived from the Edwards y coordinate with u = (1 + y) / (1 - y)
+func PublicEd25519toCurve25519(pub []byte) (*[32]byte, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	// y is encoded little-endian, the most significant bit holds the sign of x
+	yBytes := reverse(pub)
+	yBytes[0] &= 0x7f
+	y := new(big.Int).SetBytes(yBytes)
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	denominator.ModInverse(denominator, curve25519P)
+
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, denominator)
+	u.Mod(u, curve25519P)
+
+	uBytes := u.Bytes()
+	curvePub := new([32]byte)
+	for i, b := range uBytes {
+		curvePub[len(uBytes)-1-i] = b
+	}
+
+	return curvePub, nil
+}
+
+// SecretEd25519toCurve25519 converts the Ed25519 secret to the X25519 private key of the same key pair,
+// which is the clamped scalar hashed from the Ed25519 seed
+func SecretEd25519toCurve25519(secret []byte) (*[32]byte, error) {
+	if len(secret) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 secret key")
+	}
+
+	hash := sha512.Sum512(secret[:ed25519.SeedSize])
+	hash[0] &= 248
+	hash[31] &= 127
+	hash[31] |= 64
+
+	curveSecret := new([32]byte)
+	copy(curveSecret[:], hash[:32])
+
+	return curveSecret, nil
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
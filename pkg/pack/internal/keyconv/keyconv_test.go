@@ -0,0 +1,55 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keyconv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestEd25519toCurve25519(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		curvePub, err := PublicEd25519toCurve25519(pub)
+		require.NoError(t, err)
+		curveSecret, err := SecretEd25519toCurve25519(priv)
+		require.NoError(t, err)
+
+		// converted keys are a X25519 key pair
+		expectedPub, err := curve25519.X25519(curveSecret[:], curve25519.Basepoint)
+		require.NoError(t, err)
+		require.Equal(t, expectedPub, curvePub[:])
+	}
+}
+
+func TestEd25519toCurve25519_InvalidKeys(t *testing.T) {
+	_, err := PublicEd25519toCurve25519([]byte("short"))
+	require.Error(t, err)
+
+	// identity point, y = 1
+	identity := make([]byte, ed25519.PublicKeySize)
+	identity[0] = 1
+	_, err = PublicEd25519toCurve25519(identity)
+	require.Error(t, err)
+
+	// y out of the field
+	outOfField := make([]byte, ed25519.PublicKeySize)
+	for i := range outOfField {
+		outOfField[i] = 0xff
+	}
+	_, err = PublicEd25519toCurve25519(outOfField)
+	require.Error(t, err)
+
+	_, err = SecretEd25519toCurve25519([]byte("short"))
+	require.Error(t, err)
+}
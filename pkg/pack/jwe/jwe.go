@@ -0,0 +1,398 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/internal/base64url"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/internal/keyconv"
+	"golang.org/x/crypto/curve25519"
+)
+
+// JWE envelope with the general JSON serialization of RFC 7516, the content encryption key is wrapped for
+// every recipient with a key agreed on X25519 keys converted from the Ed25519 verkeys
+const (
+	encAlgorithm = "A256GCM"
+	// anoncryptAlg ephemeral-static key agreement, the sender is anonymous
+	anoncryptAlg = "ECDH-ES+A256KW"
+	// authcryptAlg one-pass unified model key agreement, the sender is authenticated by its static key
+	// https://tools.ietf.org/html/draft-madden-jose-ecdh-1pu-03
+	authcryptAlg = "ECDH-1PU+A256KW"
+	envelopeType = "application/didcomm-encrypted+json"
+
+	keyType  = "OKP"
+	curve    = "X25519"
+	keySize  = 32
+	gcmNonce = 12
+)
+
+// Packer packs messages into JWE envelopes
+type Packer struct {
+	didProvider didprovider.Provider
+}
+
+// envelope JSON serialization of the JWE
+type envelope struct {
+	Protected  string      `json:"protected"`
+	Recipients []recipient `json:"recipients"`
+	IV         string      `json:"iv"`
+	CipherText string      `json:"ciphertext"`
+	Tag        string      `json:"tag"`
+}
+
+// protected header of the JWE, it is authenticated along with the content
+type protected struct {
+	Enc  string `json:"enc"`
+	Typ  string `json:"typ"`
+	Alg  string `json:"alg"`
+	SKID string `json:"skid,omitempty"`
+	EPK  *jwk   `json:"epk"`
+	APU  string `json:"apu,omitempty"`
+}
+
+// jwk ephemeral X25519 public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// recipient content encryption key wrapped for one recipient
+type recipient struct {
+	Header       recipientHeader `json:"header"`
+	EncryptedKey string          `json:"encrypted_key"`
+}
+
+type recipientHeader struct {
+	KID string `json:"kid"`
+}
+
+// New creates a new JWE packer, the DID provider holds the keys of the agent used to authenticate
+// as sender and to unpack the received envelopes. Only anoncrypt envelopes can be packed without it.
+func New(didProvider didprovider.Provider) *Packer {
+	return &Packer{didProvider: didProvider}
+}
+
+// Pack encrypts the payload for the recipient keys, with ECDH-1PU if the sender key is set or ECDH-ES otherwise
+func (p *Packer) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	if len(recipientKeys) == 0 {
+		return nil, errors.New("recipient keys are mandatory")
+	}
+
+	header := &protected{Enc: encAlgorithm, Typ: envelopeType, Alg: anoncryptAlg}
+	var senderSecret []byte
+	if senderKey != "" {
+		secret, err := p.curveSecret(senderKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get sender key %s", senderKey)
+		}
+		senderSecret = secret[:]
+		header.Alg = authcryptAlg
+		header.SKID = senderKey
+		header.APU = base64.RawURLEncoding.EncodeToString([]byte(senderKey))
+	}
+
+	ephemeralSecret, err := randomBytes(keySize)
+	if err != nil {
+		return nil, err
+	}
+	ephemeralPub, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate ephemeral key")
+	}
+	header.EPK = &jwk{Kty: keyType, Crv: curve, X: base64.RawURLEncoding.EncodeToString(ephemeralPub)}
+
+	cek, err := randomBytes(keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]recipient, 0, len(recipientKeys))
+	for _, recipientKey := range recipientKeys {
+		r, err := packRecipient(header, cek, recipientKey, ephemeralSecret, senderSecret)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *r)
+	}
+
+	protectedJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Protected Header Error")
+	}
+
+	return encryptContent(cek, payload, base64.RawURLEncoding.EncodeToString(protectedJSON), recipients)
+}
+
+// Unpack decrypts the JWE with the first of its recipient keys held by the DID provider
+func (p *Packer) Unpack(envelopeBytes []byte) (*pack.Envelope, error) {
+	if p.didProvider == nil {
+		return nil, errors.New("DID provider is mandatory to unpack")
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(envelopeBytes, env); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal JWE Error")
+	}
+
+	protectedJSON, err := base64url.Decode(env.Protected)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid protected header encoding")
+	}
+	header := &protected{}
+	if err := json.Unmarshal(protectedJSON, header); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Protected Header Error")
+	}
+	if header.Enc != encAlgorithm {
+		return nil, errors.Errorf("unsupported content encryption %s", header.Enc)
+	}
+
+	r, recipientSecret, err := p.findRecipient(env.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := unpackRecipient(header, r, recipientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := decryptContent(cek, env)
+	if err != nil {
+		return nil, err
+	}
+
+	unpacked := &pack.Envelope{Message: message, RecipientKey: r.Header.KID}
+	if header.Alg == authcryptAlg {
+		unpacked.SenderKey = header.SKID
+	}
+
+	return unpacked, nil
+}
+
+// curveSecret returns the X25519 private key of the agent's verkey
+func (p *Packer) curveSecret(verKey string) (*[32]byte, error) {
+	if p.didProvider == nil {
+		return nil, errors.New("DID provider is mandatory for authcrypt")
+	}
+
+	didInfo, err := p.didProvider.GetLocalDIDBasedOnVerKey(base58.Decode(verKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return keyconv.SecretEd25519toCurve25519(didInfo.Secret)
+}
+
+// findRecipient returns the first recipient of the JWE whose key is held by the DID provider
+func (p *Packer) findRecipient(recipients []recipient) (*recipient, *[32]byte, error) {
+	for i := range recipients {
+		secret, err := p.curveSecret(recipients[i].Header.KID)
+		if err != nil {
+			continue
+		}
+
+		return &recipients[i], secret, nil
+	}
+
+	return nil, nil, errors.New("no recipient key of the envelope found")
+}
+
+// packRecipient wraps the content encryption key with the key agreed between the sender and the recipient
+func packRecipient(header *protected, cek []byte, recipientKey string,
+	ephemeralSecret, senderSecret []byte) (*recipient, error) {
+	recipientPub, err := keyconv.PublicEd25519toCurve25519(base58.Decode(recipientKey))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recipient key %s", recipientKey)
+	}
+
+	z, err := curve25519.X25519(ephemeralSecret, recipientPub[:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed key agreement with recipient key %s", recipientKey)
+	}
+	if senderSecret != nil {
+		zs, err := curve25519.X25519(senderSecret, recipientPub[:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed key agreement with recipient key %s", recipientKey)
+		}
+		z = append(z, zs...)
+	}
+
+	keyEncryptionKey, err := kek(header, z)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := wrapKey(keyEncryptionKey, cek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recipient{
+		Header:       recipientHeader{KID: recipientKey},
+		EncryptedKey: base64.RawURLEncoding.EncodeToString(encryptedKey),
+	}, nil
+}
+
+// unpackRecipient unwraps the content encryption key with the key agreed between the sender and the recipient
+func unpackRecipient(header *protected, r *recipient, recipientSecret *[32]byte) ([]byte, error) {
+	z, err := agreeKey(header, recipientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := base64url.Decode(r.EncryptedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid encrypted key encoding")
+	}
+
+	keyEncryptionKey, err := kek(header, z)
+	if err != nil {
+		return nil, err
+	}
+
+	return unwrapKey(keyEncryptionKey, encryptedKey)
+}
+
+// agreeKey returns the shared secret of the recipient, agreed with the ephemeral key (ECDH-ES)
+// and with the sender key as well for authcrypt (ECDH-1PU)
+func agreeKey(header *protected, recipientSecret *[32]byte) ([]byte, error) {
+	if header.EPK == nil || header.EPK.Kty != keyType || header.EPK.Crv != curve {
+		return nil, errors.New("invalid ephemeral key")
+	}
+	ephemeralPub, err := base64url.Decode(header.EPK.X)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ephemeral key encoding")
+	}
+
+	z, err := curve25519.X25519(recipientSecret[:], ephemeralPub)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed key agreement with ephemeral key")
+	}
+
+	switch header.Alg {
+	case anoncryptAlg:
+		return z, nil
+	case authcryptAlg:
+		zs, err := agreeSenderKey(header.SKID, recipientSecret)
+		if err != nil {
+			return nil, err
+		}
+		return append(z, zs...), nil
+	default:
+		return nil, errors.Errorf("unsupported key management algorithm %s", header.Alg)
+	}
+}
+
+// agreeSenderKey returns the secret the recipient shares with the static key of the sender
+func agreeSenderKey(senderKey string, recipientSecret *[32]byte) ([]byte, error) {
+	senderPub, err := keyconv.PublicEd25519toCurve25519(base58.Decode(senderKey))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid sender key %s", senderKey)
+	}
+
+	zs, err := curve25519.X25519(recipientSecret[:], senderPub[:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed key agreement with sender key %s", senderKey)
+	}
+
+	return zs, nil
+}
+
+// kek derives the key encryption key from the shared secret
+func kek(header *protected, z []byte) ([]byte, error) {
+	apu, err := base64url.Decode(header.APU)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid apu encoding")
+	}
+
+	return concatKDF(z, header.Alg, apu, nil, keySize), nil
+}
+
+// encryptContent encrypts the payload with AES-GCM, the protected header is authenticated
+func encryptContent(cek, payload []byte, protectedB64 string, recipients []recipient) ([]byte, error) {
+	aead, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomBytes(gcmNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nil, nonce, payload, []byte(protectedB64))
+	tagOffset := len(sealed) - aead.Overhead()
+
+	envelopeJSON, err := json.Marshal(&envelope{
+		Protected:  protectedB64,
+		Recipients: recipients,
+		IV:         base64.RawURLEncoding.EncodeToString(nonce),
+		CipherText: base64.RawURLEncoding.EncodeToString(sealed[:tagOffset]),
+		Tag:        base64.RawURLEncoding.EncodeToString(sealed[tagOffset:]),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal JWE Error")
+	}
+
+	return envelopeJSON, nil
+}
+
+func decryptContent(cek []byte, env *envelope) ([]byte, error) {
+	aead, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := base64url.Decode(env.IV)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid content iv")
+	}
+	cipherText, err := base64url.Decode(env.CipherText)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ciphertext encoding")
+	}
+	tag, err := base64url.Decode(env.Tag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid tag encoding")
+	}
+
+	message, err := aead.Open(nil, nonce, append(cipherText, tag...), []byte(env.Protected))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt content")
+	}
+
+	return message, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create content cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate random bytes")
+	}
+
+	return b, nil
+}
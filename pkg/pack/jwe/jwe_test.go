@@ -0,0 +1,178 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+)
+
+var message = []byte(`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"}`)
+
+func TestPacker_Authcrypt(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	bob, bobKey := newAgent(t)
+	carol, carolKey := newAgent(t)
+
+	envelope, err := alice.Pack(message, aliceKey, []string{bobKey, carolKey})
+	require.NoError(t, err)
+
+	env, header := parseEnvelope(t, envelope)
+	require.Equal(t, authcryptAlg, header.Alg)
+	require.Equal(t, encAlgorithm, header.Enc)
+	require.Equal(t, envelopeType, header.Typ)
+	require.Equal(t, aliceKey, header.SKID)
+	require.NotNil(t, header.EPK)
+	require.Len(t, env.Recipients, 2)
+
+	// every recipient can unpack
+	for agent, key := range map[*Packer]string{bob: bobKey, carol: carolKey} {
+		unpacked, err := agent.Unpack(envelope)
+		require.NoError(t, err)
+		require.Equal(t, message, unpacked.Message)
+		require.Equal(t, aliceKey, unpacked.SenderKey)
+		require.Equal(t, key, unpacked.RecipientKey)
+	}
+
+	// not a recipient
+	other, _ := newAgent(t)
+	_, err = other.Unpack(envelope)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no recipient key")
+}
+
+func TestPacker_Anoncrypt(t *testing.T) {
+	bob, bobKey := newAgent(t)
+
+	// no DID provider needed to pack anonymously
+	envelope, err := New(nil).Pack(message, "", []string{bobKey})
+	require.NoError(t, err)
+	_, header := parseEnvelope(t, envelope)
+	require.Equal(t, anoncryptAlg, header.Alg)
+	require.Empty(t, header.SKID)
+
+	unpacked, err := bob.Unpack(envelope)
+	require.NoError(t, err)
+	require.Equal(t, message, unpacked.Message)
+	require.Empty(t, unpacked.SenderKey)
+	require.Equal(t, bobKey, unpacked.RecipientKey)
+}
+
+func TestPacker_Tampered(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	bob, bobKey := newAgent(t)
+	_, malloryKey := newAgent(t)
+
+	envelope, err := alice.Pack(message, aliceKey, []string{bobKey})
+	require.NoError(t, err)
+
+	// ciphertext
+	env, header := parseEnvelope(t, envelope)
+	cipherText, err := base64.RawURLEncoding.DecodeString(env.CipherText)
+	require.NoError(t, err)
+	cipherText[0] ^= 0xff
+	env.CipherText = base64.RawURLEncoding.EncodeToString(cipherText)
+	_, err = bob.Unpack(marshal(t, env))
+	require.Error(t, err)
+
+	// protected header is authenticated
+	env, _ = parseEnvelope(t, envelope)
+	header.Typ = "other"
+	env.Protected = base64.RawURLEncoding.EncodeToString(marshal(t, header))
+	_, err = bob.Unpack(marshal(t, env))
+	require.Error(t, err)
+
+	// the sender key can't be replaced without its secret
+	env, header = parseEnvelope(t, envelope)
+	header.SKID = malloryKey
+	header.APU = base64.RawURLEncoding.EncodeToString([]byte(malloryKey))
+	env.Protected = base64.RawURLEncoding.EncodeToString(marshal(t, header))
+	_, err = bob.Unpack(marshal(t, env))
+	require.Error(t, err)
+}
+
+func TestPacker_Errors(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	_, bobKey := newAgent(t)
+
+	_, err := alice.Pack(message, aliceKey, nil)
+	require.Error(t, err)
+
+	// sender key not held by the agent
+	_, err = alice.Pack(message, bobKey, []string{bobKey})
+	require.Error(t, err)
+
+	// authcrypt without DID provider
+	_, err = New(nil).Pack(message, aliceKey, []string{bobKey})
+	require.Error(t, err)
+
+	_, err = alice.Pack(message, aliceKey, []string{"invalid"})
+	require.Error(t, err)
+
+	_, err = New(nil).Unpack([]byte("{}"))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte("invalid"))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte(`{"protected":"!"}`))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte(`{"protected":"` + base64.RawURLEncoding.EncodeToString([]byte(`{"enc":"other"}`)) + `"}`))
+	require.Error(t, err)
+
+	// unsupported key management algorithm
+	envelope, err := New(nil).Pack(message, "", []string{aliceKey})
+	require.NoError(t, err)
+	env, header := parseEnvelope(t, envelope)
+	header.Alg = "other"
+	env.Protected = base64.RawURLEncoding.EncodeToString(marshal(t, header))
+	_, err = alice.Unpack(marshal(t, env))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported key management algorithm")
+
+	// missing ephemeral key
+	env, header = parseEnvelope(t, envelope)
+	header.EPK = nil
+	env.Protected = base64.RawURLEncoding.EncodeToString(marshal(t, header))
+	_, err = alice.Unpack(marshal(t, env))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid ephemeral key")
+}
+
+func newAgent(t *testing.T) (*Packer, string) {
+	didProvider := didbasic.NewProvider()
+	didInfo, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	return New(didProvider), base58.Encode(didInfo.VerKey)
+}
+
+func parseEnvelope(t *testing.T, envelopeBytes []byte) (*envelope, *protected) {
+	env := &envelope{}
+	require.NoError(t, json.Unmarshal(envelopeBytes, env))
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	require.NoError(t, err)
+
+	header := &protected{}
+	require.NoError(t, json.Unmarshal(headerJSON, header))
+
+	return env, header
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return b
+}
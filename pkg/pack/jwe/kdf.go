@@ -0,0 +1,42 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// concatKDF derives a key of the given size from the shared secret with the single step KDF
+// of NIST SP 800-56A using SHA-256, the other info is built as per RFC 7518 section 4.6.2
+func concatKDF(z []byte, alg string, apu, apv []byte, keySize int) []byte {
+	otherInfo := lengthPrefixed([]byte(alg))
+	otherInfo = append(otherInfo, lengthPrefixed(apu)...)
+	otherInfo = append(otherInfo, lengthPrefixed(apv)...)
+	otherInfo = append(otherInfo, uint32BigEndian(uint32(keySize*8))...)
+
+	var key []byte
+	for counter := uint32(1); len(key) < keySize; counter++ {
+		input := uint32BigEndian(counter)
+		input = append(input, z...)
+		input = append(input, otherInfo...)
+		digest := sha256.Sum256(input)
+		key = append(key, digest[:]...)
+	}
+
+	return key[:keySize]
+}
+
+func lengthPrefixed(data []byte) []byte {
+	return append(uint32BigEndian(uint32(len(data))), data...)
+}
+
+func uint32BigEndian(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
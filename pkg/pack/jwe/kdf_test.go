@@ -0,0 +1,27 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcatKDF(t *testing.T) {
+	// RFC 7518 appendix C
+	z := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132, 38, 156,
+		251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121, 140, 254, 144, 196}
+
+	key := concatKDF(z, "A128GCM", []byte("Alice"), []byte("Bob"), 16)
+	require.Equal(t, "VqqN6vgjbSBcIijNcacQGg", base64.RawURLEncoding.EncodeToString(key))
+
+	// longer keys span several hash rounds
+	key = concatKDF(z, "A128GCM", nil, nil, 48)
+	require.Len(t, key, 48)
+}
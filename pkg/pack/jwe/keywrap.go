@@ -0,0 +1,87 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// keyWrapIV default initial value of the AES key wrap
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+const keyWrapBlockSize = 8
+
+// wrapKey wraps the content encryption key with the key encryption key as per the AES key wrap of RFC 3394
+func wrapKey(kek, cek []byte) ([]byte, error) {
+	if len(cek) < 2*keyWrapBlockSize || len(cek)%keyWrapBlockSize != 0 {
+		return nil, errors.New("invalid key size to wrap")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create key wrap cipher")
+	}
+
+	n := len(cek) / keyWrapBlockSize
+	wrapped := make([]byte, keyWrapBlockSize+len(cek))
+	copy(wrapped, keyWrapIV)
+	copy(wrapped[keyWrapBlockSize:], cek)
+
+	buf := make([]byte, aes.BlockSize)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, wrapped[:keyWrapBlockSize])
+			copy(buf[keyWrapBlockSize:], wrapped[i*keyWrapBlockSize:(i+1)*keyWrapBlockSize])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(wrapped[:keyWrapBlockSize], binary.BigEndian.Uint64(buf)^t)
+			copy(wrapped[i*keyWrapBlockSize:(i+1)*keyWrapBlockSize], buf[keyWrapBlockSize:])
+		}
+	}
+
+	return wrapped, nil
+}
+
+// unwrapKey unwraps the content encryption key with the key encryption key as per the AES key wrap of RFC 3394
+func unwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 3*keyWrapBlockSize || len(wrapped)%keyWrapBlockSize != 0 {
+		return nil, errors.New("invalid wrapped key size")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create key wrap cipher")
+	}
+
+	n := len(wrapped)/keyWrapBlockSize - 1
+	unwrapped := make([]byte, len(wrapped))
+	copy(unwrapped, wrapped)
+
+	buf := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(unwrapped[:keyWrapBlockSize])^t)
+			copy(buf[keyWrapBlockSize:], unwrapped[i*keyWrapBlockSize:(i+1)*keyWrapBlockSize])
+			block.Decrypt(buf, buf)
+
+			copy(unwrapped[:keyWrapBlockSize], buf[:keyWrapBlockSize])
+			copy(unwrapped[i*keyWrapBlockSize:(i+1)*keyWrapBlockSize], buf[keyWrapBlockSize:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(unwrapped[:keyWrapBlockSize], keyWrapIV) != 1 {
+		return nil, errors.New("failed to unwrap key: integrity check failed")
+	}
+
+	return unwrapped[keyWrapBlockSize:], nil
+}
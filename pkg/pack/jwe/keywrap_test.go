@@ -0,0 +1,44 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package jwe
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapKey(t *testing.T) {
+	// RFC 3394 section 4.6, 256 bits of key data with a 256 bits KEK
+	kek, err := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	require.NoError(t, err)
+	cek, err := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+	require.NoError(t, err)
+	expected, err := hex.DecodeString("28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")
+	require.NoError(t, err)
+
+	wrapped, err := wrapKey(kek, cek)
+	require.NoError(t, err)
+	require.Equal(t, expected, wrapped)
+
+	unwrapped, err := unwrapKey(kek, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, cek, unwrapped)
+
+	// integrity check
+	wrapped[0] ^= 0xff
+	_, err = unwrapKey(kek, wrapped)
+	require.Error(t, err)
+
+	_, err = wrapKey(kek, cek[:7])
+	require.Error(t, err)
+	_, err = unwrapKey(kek, expected[:16])
+	require.Error(t, err)
+	_, err = wrapKey(kek[:5], cek)
+	require.Error(t, err)
+}
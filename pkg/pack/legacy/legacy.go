@@ -0,0 +1,344 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package legacy
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/pkg/errors"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/internal/base64url"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/internal/keyconv"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Envelope format of Aries RFC 0019
+// https://github.com/hyperledger/aries-rfcs/tree/master/features/0019-encryption-envelope
+const (
+	encAlgorithm = "xchacha20poly1305_ietf"
+	envelopeType = "JWM/1.0"
+	authcrypt    = "Authcrypt"
+	anoncrypt    = "Anoncrypt"
+
+	nonceSize = 24
+)
+
+// Packer packs messages into the libsodium based envelopes of Aries RFC 0019,
+// content is encrypted with XChaCha20-Poly1305 under a key boxed for every recipient
+type Packer struct {
+	didProvider didprovider.Provider
+}
+
+// envelope JSON serialization of the packed message
+type envelope struct {
+	Protected  string `json:"protected"`
+	IV         string `json:"iv"`
+	CipherText string `json:"ciphertext"`
+	Tag        string `json:"tag"`
+}
+
+// protected header of the envelope, it is authenticated along with the content
+type protected struct {
+	Enc        string      `json:"enc"`
+	Typ        string      `json:"typ"`
+	Alg        string      `json:"alg"`
+	Recipients []recipient `json:"recipients"`
+}
+
+// recipient content encryption key boxed for one recipient
+type recipient struct {
+	EncryptedKey string          `json:"encrypted_key"`
+	Header       recipientHeader `json:"header"`
+}
+
+type recipientHeader struct {
+	KID    string `json:"kid"`
+	Sender string `json:"sender,omitempty"`
+	IV     string `json:"iv,omitempty"`
+}
+
+// New creates a new legacy packer, the DID provider holds the keys of the agent used to authenticate
+// as sender and to unpack the received envelopes. Only anoncrypt envelopes can be packed without it.
+func New(didProvider didprovider.Provider) *Packer {
+	return &Packer{didProvider: didProvider}
+}
+
+// Pack encrypts the payload for the recipient keys, with authcrypt if the sender key is set or anoncrypt otherwise
+func (p *Packer) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	if len(recipientKeys) == 0 {
+		return nil, errors.New("recipient keys are mandatory")
+	}
+
+	cek := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate content encryption key")
+	}
+
+	alg := anoncrypt
+	var senderSecret *[32]byte
+	if senderKey != "" {
+		var err error
+		senderSecret, err = p.curveSecret(senderKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get sender key %s", senderKey)
+		}
+		alg = authcrypt
+	}
+
+	recipients := make([]recipient, 0, len(recipientKeys))
+	for _, recipientKey := range recipientKeys {
+		r, err := packRecipient(cek, recipientKey, senderKey, senderSecret)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *r)
+	}
+
+	protectedJSON, err := json.Marshal(&protected{
+		Enc:        encAlgorithm,
+		Typ:        envelopeType,
+		Alg:        alg,
+		Recipients: recipients,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Protected Header Error")
+	}
+
+	return encryptContent(cek, payload, base64.URLEncoding.EncodeToString(protectedJSON))
+}
+
+// Unpack decrypts the envelope with the first of its recipient keys held by the DID provider
+func (p *Packer) Unpack(envelopeBytes []byte) (*pack.Envelope, error) {
+	if p.didProvider == nil {
+		return nil, errors.New("DID provider is mandatory to unpack")
+	}
+
+	env := &envelope{}
+	if err := json.Unmarshal(envelopeBytes, env); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Envelope Error")
+	}
+
+	protectedJSON, err := base64url.Decode(env.Protected)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid protected header encoding")
+	}
+	header := &protected{}
+	if err := json.Unmarshal(protectedJSON, header); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Protected Header Error")
+	}
+	if header.Enc != encAlgorithm {
+		return nil, errors.Errorf("unsupported content encryption %s", header.Enc)
+	}
+
+	r, recipientSecret, err := p.findRecipient(header.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, senderKey, err := unpackRecipient(header.Alg, r, recipientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := decryptContent(cek, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pack.Envelope{Message: message, SenderKey: senderKey, RecipientKey: r.Header.KID}, nil
+}
+
+// curveSecret returns the X25519 private key of the agent's verkey
+func (p *Packer) curveSecret(verKey string) (*[32]byte, error) {
+	if p.didProvider == nil {
+		return nil, errors.New("DID provider is mandatory for authcrypt")
+	}
+
+	didInfo, err := p.didProvider.GetLocalDIDBasedOnVerKey(base58.Decode(verKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return keyconv.SecretEd25519toCurve25519(didInfo.Secret)
+}
+
+// findRecipient returns the first recipient of the envelope whose key is held by the DID provider
+func (p *Packer) findRecipient(recipients []recipient) (*recipient, *[32]byte, error) {
+	for i := range recipients {
+		didInfo, err := p.didProvider.GetLocalDIDBasedOnVerKey(base58.Decode(recipients[i].Header.KID))
+		if err != nil {
+			continue
+		}
+
+		secret, err := keyconv.SecretEd25519toCurve25519(didInfo.Secret)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &recipients[i], secret, nil
+	}
+
+	return nil, nil, errors.New("no recipient key of the envelope found")
+}
+
+// packRecipient boxes the content encryption key for the recipient, from the sender for authcrypt
+// along with the sender key sealed for the recipient, or from an ephemeral key for anoncrypt
+func packRecipient(cek []byte, recipientKey, senderKey string, senderSecret *[32]byte) (*recipient, error) {
+	recipientPub, err := keyconv.PublicEd25519toCurve25519(base58.Decode(recipientKey))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recipient key %s", recipientKey)
+	}
+
+	if senderSecret == nil {
+		encryptedKey, err := box.SealAnonymous(nil, cek, recipientPub, rand.Reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to seal content encryption key")
+		}
+
+		return &recipient{
+			EncryptedKey: base64.URLEncoding.EncodeToString(encryptedKey),
+			Header:       recipientHeader{KID: recipientKey},
+		}, nil
+	}
+
+	nonce := new([nonceSize]byte)
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate nonce")
+	}
+
+	sender, err := box.SealAnonymous(nil, []byte(senderKey), recipientPub, rand.Reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to seal sender key")
+	}
+
+	return &recipient{
+		EncryptedKey: base64.URLEncoding.EncodeToString(box.Seal(nil, cek, nonce, recipientPub, senderSecret)),
+		Header: recipientHeader{
+			KID:    recipientKey,
+			Sender: base64.URLEncoding.EncodeToString(sender),
+			IV:     base64.URLEncoding.EncodeToString(nonce[:]),
+		},
+	}, nil
+}
+
+// unpackRecipient opens the content encryption key boxed for the recipient, the sender key is returned for authcrypt
+func unpackRecipient(alg string, r *recipient, recipientSecret *[32]byte) ([]byte, string, error) {
+	recipientPub, err := keyconv.PublicEd25519toCurve25519(base58.Decode(r.Header.KID))
+	if err != nil {
+		return nil, "", err
+	}
+
+	encryptedKey, err := base64url.Decode(r.EncryptedKey)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid encrypted key encoding")
+	}
+
+	switch alg {
+	case anoncrypt:
+		cek, ok := box.OpenAnonymous(nil, encryptedKey, recipientPub, recipientSecret)
+		if !ok {
+			return nil, "", errors.New("failed to open content encryption key")
+		}
+		return cek, "", nil
+	case authcrypt:
+		return openAuthcryptKey(encryptedKey, r.Header, recipientPub, recipientSecret)
+	default:
+		return nil, "", errors.Errorf("unsupported envelope algorithm %s", alg)
+	}
+}
+
+func openAuthcryptKey(encryptedKey []byte, header recipientHeader,
+	recipientPub, recipientSecret *[32]byte) ([]byte, string, error) {
+	sealedSender, err := base64url.Decode(header.Sender)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid sender encoding")
+	}
+	sender, ok := box.OpenAnonymous(nil, sealedSender, recipientPub, recipientSecret)
+	if !ok {
+		return nil, "", errors.New("failed to open sender key")
+	}
+
+	senderPub, err := keyconv.PublicEd25519toCurve25519(base58.Decode(string(sender)))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid sender key")
+	}
+
+	iv, err := base64url.Decode(header.IV)
+	if err != nil || len(iv) != nonceSize {
+		return nil, "", errors.New("invalid recipient iv")
+	}
+	nonce := new([nonceSize]byte)
+	copy(nonce[:], iv)
+
+	cek, ok := box.Open(nil, encryptedKey, nonce, senderPub, recipientSecret)
+	if !ok {
+		return nil, "", errors.New("failed to open content encryption key")
+	}
+
+	return cek, string(sender), nil
+}
+
+// encryptContent encrypts the payload with XChaCha20-Poly1305, the protected header is authenticated
+func encryptContent(cek, payload []byte, protectedB64 string) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(cek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create content cipher")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "failed to generate nonce")
+	}
+
+	sealed := aead.Seal(nil, nonce, payload, []byte(protectedB64))
+	tagOffset := len(sealed) - aead.Overhead()
+
+	envelopeJSON, err := json.Marshal(&envelope{
+		Protected:  protectedB64,
+		IV:         base64.URLEncoding.EncodeToString(nonce),
+		CipherText: base64.URLEncoding.EncodeToString(sealed[:tagOffset]),
+		Tag:        base64.URLEncoding.EncodeToString(sealed[tagOffset:]),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Envelope Error")
+	}
+
+	return envelopeJSON, nil
+}
+
+func decryptContent(cek []byte, env *envelope) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(cek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create content cipher")
+	}
+
+	nonce, err := base64url.Decode(env.IV)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid content iv")
+	}
+	cipherText, err := base64url.Decode(env.CipherText)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid ciphertext encoding")
+	}
+	tag, err := base64url.Decode(env.Tag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid tag encoding")
+	}
+
+	message, err := aead.Open(nil, nonce, append(cipherText, tag...), []byte(env.Protected))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt content")
+	}
+
+	return message, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package legacy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+)
+
+var message = []byte(`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"}`)
+
+func TestPacker_Authcrypt(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	bob, bobKey := newAgent(t)
+	carol, carolKey := newAgent(t)
+
+	envelope, err := alice.Pack(message, aliceKey, []string{bobKey, carolKey})
+	require.NoError(t, err)
+
+	header := protectedHeader(t, envelope)
+	require.Equal(t, authcrypt, header.Alg)
+	require.Equal(t, encAlgorithm, header.Enc)
+	require.Equal(t, envelopeType, header.Typ)
+	require.Len(t, header.Recipients, 2)
+
+	// every recipient can unpack
+	for agent, key := range map[*Packer]string{bob: bobKey, carol: carolKey} {
+		unpacked, err := agent.Unpack(envelope)
+		require.NoError(t, err)
+		require.Equal(t, message, unpacked.Message)
+		require.Equal(t, aliceKey, unpacked.SenderKey)
+		require.Equal(t, key, unpacked.RecipientKey)
+	}
+
+	// not a recipient
+	other, _ := newAgent(t)
+	_, err = other.Unpack(envelope)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no recipient key")
+}
+
+func TestPacker_Anoncrypt(t *testing.T) {
+	bob, bobKey := newAgent(t)
+
+	// no DID provider needed to pack anonymously
+	envelope, err := New(nil).Pack(message, "", []string{bobKey})
+	require.NoError(t, err)
+	require.Equal(t, anoncrypt, protectedHeader(t, envelope).Alg)
+
+	unpacked, err := bob.Unpack(envelope)
+	require.NoError(t, err)
+	require.Equal(t, message, unpacked.Message)
+	require.Empty(t, unpacked.SenderKey)
+	require.Equal(t, bobKey, unpacked.RecipientKey)
+}
+
+func TestPacker_Tampered(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	bob, bobKey := newAgent(t)
+
+	envelope, err := alice.Pack(message, aliceKey, []string{bobKey})
+	require.NoError(t, err)
+
+	// ciphertext
+	env := &struct {
+		Protected  string `json:"protected"`
+		IV         string `json:"iv"`
+		CipherText string `json:"ciphertext"`
+		Tag        string `json:"tag"`
+	}{}
+	require.NoError(t, json.Unmarshal(envelope, env))
+	cipherText, err := base64.URLEncoding.DecodeString(env.CipherText)
+	require.NoError(t, err)
+	cipherText[0] ^= 0xff
+	env.CipherText = base64.URLEncoding.EncodeToString(cipherText)
+	tampered, err := json.Marshal(env)
+	require.NoError(t, err)
+	_, err = bob.Unpack(tampered)
+	require.Error(t, err)
+
+	// protected header is authenticated
+	require.NoError(t, json.Unmarshal(envelope, env))
+	header := protectedHeader(t, envelope)
+	header.Typ = "other"
+	headerJSON, err := json.Marshal(header)
+	require.NoError(t, err)
+	env.Protected = base64.URLEncoding.EncodeToString(headerJSON)
+	tampered, err = json.Marshal(env)
+	require.NoError(t, err)
+	_, err = bob.Unpack(tampered)
+	require.Error(t, err)
+}
+
+func TestPacker_Errors(t *testing.T) {
+	alice, aliceKey := newAgent(t)
+	_, bobKey := newAgent(t)
+
+	_, err := alice.Pack(message, aliceKey, nil)
+	require.Error(t, err)
+
+	// sender key not held by the agent
+	_, err = alice.Pack(message, bobKey, []string{bobKey})
+	require.Error(t, err)
+
+	// authcrypt without DID provider
+	_, err = New(nil).Pack(message, aliceKey, []string{bobKey})
+	require.Error(t, err)
+
+	_, err = alice.Pack(message, aliceKey, []string{"invalid"})
+	require.Error(t, err)
+
+	_, err = New(nil).Unpack([]byte("{}"))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte("invalid"))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte(`{"protected":"!"}`))
+	require.Error(t, err)
+
+	_, err = alice.Unpack([]byte(`{"protected":"` + base64.URLEncoding.EncodeToString([]byte(`{"enc":"other"}`)) + `"}`))
+	require.Error(t, err)
+}
+
+func newAgent(t *testing.T) (*Packer, string) {
+	didProvider := didbasic.NewProvider()
+	didInfo, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+
+	return New(didProvider), base58.Encode(didInfo.VerKey)
+}
+
+func protectedHeader(t *testing.T, envelope []byte) *protected {
+	env := &struct {
+		Protected string `json:"protected"`
+	}{}
+	require.NoError(t, json.Unmarshal(envelope, env))
+
+	headerJSON, err := base64.URLEncoding.DecodeString(env.Protected)
+	require.NoError(t, err)
+
+	header := &protected{}
+	require.NoError(t, json.Unmarshal(headerJSON, header))
+
+	return header
+}
@@ -0,0 +1,26 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pack
+
+// Packer packs messages into DIDComm envelopes and unpacks the envelopes received from other agents
+type Packer interface {
+	// Pack encrypts the payload for every recipient key. The envelope is authenticated with the sender key
+	// (authcrypt) or anonymous if the sender key is empty (anoncrypt). Keys are base58 encoded verkeys.
+	Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error)
+
+	// Unpack decrypts the envelope with the key of one of its recipients held by the agent
+	Unpack(envelope []byte) (*Envelope, error)
+}
+
+// Envelope unpacked DIDComm envelope
+type Envelope struct {
+	Message []byte
+	// SenderKey base58 encoded verkey of the sender, empty for anoncrypt envelopes
+	SenderKey string
+	// RecipientKey base58 encoded verkey the envelope was decrypted with
+	RecipientKey string
+}
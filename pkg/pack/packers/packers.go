@@ -0,0 +1,26 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package packers
+
+import (
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/jwe"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+)
+
+// NewDefault creates the default packer of the agent, legacy RFC 0019 and JWE envelopes are packed and unpacked
+// with the keys held by the DID provider, messages are packed in the legacy format unless another one is chosen
+func NewDefault(didProvider didprovider.Provider) *pack.MultiPacker {
+	// the default format has a packer, the multi packer can't fail to be created
+	packer, _ := pack.NewMultiPacker(pack.FormatLegacy, map[string]pack.Packer{
+		pack.FormatLegacy: legacy.New(didProvider),
+		pack.FormatJWE:    jwe.New(didProvider),
+	})
+
+	return packer
+}
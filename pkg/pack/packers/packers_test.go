@@ -0,0 +1,43 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package packers
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/require"
+	didbasic "github.com/trustbloc/aries-framework-go/pkg/did/core/provider/basic"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+func TestNewDefault(t *testing.T) {
+	didProvider := didbasic.NewProvider()
+	myDID, err := didProvider.CreateLocalDID(nil)
+	require.NoError(t, err)
+	packer := NewDefault(didProvider)
+	recipientKeys := []string{base58.Encode(myDID.VerKey)}
+
+	// legacy envelopes by default
+	envelope, err := packer.Pack([]byte("hi"), "", recipientKeys)
+	require.NoError(t, err)
+	format, err := pack.DetectFormat(envelope)
+	require.NoError(t, err)
+	require.Equal(t, pack.FormatLegacy, format)
+	unpacked, err := packer.Unpack(envelope)
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(unpacked.Message))
+
+	envelope, err = packer.PackFormat(pack.FormatJWE, []byte("hi"), "", recipientKeys)
+	require.NoError(t, err)
+	format, err = pack.DetectFormat(envelope)
+	require.NoError(t, err)
+	require.Equal(t, pack.FormatJWE, format)
+	unpacked, err = packer.Unpack(envelope)
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(unpacked.Message))
+}
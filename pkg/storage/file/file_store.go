@@ -0,0 +1,213 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package filestore
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+const (
+	dirPerm  = 0700
+	filePerm = 0600
+	tmpExt   = ".tmp"
+)
+
+// Provider file storage provider, each store is a sub directory of the provider's base directory
+type Provider struct {
+	dir    string
+	stores map[string]*Store
+	lock   sync.Mutex
+}
+
+// NewProvider instance of file storage provider keeping its stores under the given directory
+func NewProvider(dir string) (*Provider, error) {
+	if dir == "" {
+		return nil, errors.New("storage directory is mandatory")
+	}
+
+	if err := os.MkdirAll(filepath.Clean(dir), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "failed to create storage directory %s", dir)
+	}
+
+	return &Provider{dir: filepath.Clean(dir), stores: make(map[string]*Store)}, nil
+}
+
+// OpenStore opens and returns the file store for the given name
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	if name == "" {
+		return nil, errors.New("store name is mandatory")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	store, ok := p.stores[name]
+	if ok {
+		return store, nil
+	}
+
+	store, err := NewStore(filepath.Join(p.dir, encodeName(name)))
+	if err != nil {
+		return nil, err
+	}
+	p.stores[name] = store
+
+	return store, nil
+}
+
+// Close releases the stores opened by this provider, their data is kept on disk
+func (p *Provider) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.stores = make(map[string]*Store)
+
+	return nil
+}
+
+// Store file key-value store, every value is kept in its own file named after the encoded key
+type Store struct {
+	dir  string
+	lock sync.RWMutex
+}
+
+// NewStore instance of file store keeping its data under the given directory
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Clean(dir), dirPerm); err != nil {
+		return nil, errors.Wrapf(err, "failed to create store directory %s", dir)
+	}
+
+	return &Store{dir: filepath.Clean(dir)}, nil
+}
+
+// Put stores the key-value pair, the value is written to a temporary file first so that it's replaced atomically
+func (s *Store) Put(k string, v []byte) error {
+	if k == "" {
+		return errors.New("key is mandatory")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := s.path(k)
+	if err := ioutil.WriteFile(path+tmpExt, v, filePerm); err != nil {
+		return errors.Wrapf(err, "failed to write value for key %s", k)
+	}
+
+	return os.Rename(path+tmpExt, path)
+}
+
+// Get fetches the value associated with the given key
+func (s *Store) Get(k string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.read(k)
+}
+
+// Delete deletes the key-value pair associated with the given key
+func (s *Store) Delete(k string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := os.Remove(s.path(k))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete value for key %s", k)
+	}
+
+	return nil
+}
+
+// Iterate calls fn, in key order, for every key-value pair whose key starts with the given prefix
+func (s *Store) Iterate(prefix string, fn func(k string, v []byte) error) error {
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		v, e := s.Get(k)
+		if e == storage.ErrDataNotFound {
+			// deleted while iterating
+			continue
+		}
+		if e != nil {
+			return e
+		}
+		if e = fn(k, v); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// keys returns the sorted keys starting with the given prefix
+func (s *Store) keys(prefix string) ([]string, error) {
+	s.lock.RLock()
+	files, err := ioutil.ReadDir(s.dir)
+	s.lock.RUnlock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read store directory %s", s.dir)
+	}
+
+	var keys []string
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), tmpExt) {
+			continue
+		}
+		k, e := decodeName(f.Name())
+		if e != nil {
+			// not one of ours
+			continue
+		}
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (s *Store) read(k string) ([]byte, error) {
+	v, err := ioutil.ReadFile(s.path(k))
+	if os.IsNotExist(err) {
+		return nil, storage.ErrDataNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read value for key %s", k)
+	}
+
+	return v, nil
+}
+
+func (s *Store) path(k string) string {
+	return filepath.Join(s.dir, encodeName(k))
+}
+
+// encodeName encodes keys and store names into file system safe names
+func encodeName(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func decodeName(name string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(name)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package filestore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	prov, err := NewProvider(dir)
+	require.NoError(t, err)
+
+	store, err := prov.OpenStore("test")
+	require.NoError(t, err)
+
+	sameStore, err := prov.OpenStore("test")
+	require.NoError(t, err)
+	require.Equal(t, store, sameStore)
+
+	_, err = store.Get("k1")
+	require.Equal(t, storage.ErrDataNotFound, err)
+
+	require.Error(t, store.Put("", []byte("v")))
+	require.NoError(t, store.Put("k1", []byte("v1")))
+	require.NoError(t, store.Put("k/2", []byte("v2")))
+	require.NoError(t, store.Put("other", []byte("v3")))
+
+	v, err := store.Get("k1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	// files which aren't store entries are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, encodeName("test"), "not base64!"), nil, filePerm))
+
+	var keys []string
+	require.NoError(t, store.Iterate("k", func(k string, v []byte) error {
+		keys = append(keys, k)
+		return nil
+	}))
+	require.Equal(t, []string{"k/2", "k1"}, keys)
+
+	require.Error(t, store.Iterate("", func(k string, v []byte) error {
+		return errors.New("stop")
+	}))
+
+	require.NoError(t, store.Delete("k1"))
+	require.NoError(t, store.Delete("k1"))
+	_, err = store.Get("k1")
+	require.Equal(t, storage.ErrDataNotFound, err)
+
+	// data survives the provider
+	require.NoError(t, prov.Close())
+	prov, err = NewProvider(dir)
+	require.NoError(t, err)
+	store, err = prov.OpenStore("test")
+	require.NoError(t, err)
+	v, err = store.Get("k/2")
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), v)
+}
+
+func TestFileStore_Errors(t *testing.T) {
+	_, err := NewProvider("")
+	require.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	prov, err := NewProvider(dir)
+	require.NoError(t, err)
+
+	_, err = prov.OpenStore("")
+	require.Error(t, err)
+
+	// store directory can't be created over a file
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, encodeName("file")), nil, filePerm))
+	_, err = prov.OpenStore("file")
+	require.Error(t, err)
+
+	store, err := NewStore(filepath.Join(dir, "removed"))
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "removed")))
+	require.Error(t, store.Iterate("", func(k string, v []byte) error { return nil }))
+	require.Error(t, store.Put("k", []byte("v")))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package memstore
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+// Provider in-memory storage provider
+type Provider struct {
+	stores map[string]*Store
+	lock   sync.RWMutex
+}
+
+// NewProvider instance of in-memory storage provider
+func NewProvider() *Provider {
+	return &Provider{stores: make(map[string]*Store)}
+}
+
+// OpenStore opens and returns the in-memory store for the given name
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	store, ok := p.stores[name]
+	if !ok {
+		store = NewStore()
+		p.stores[name] = store
+	}
+
+	return store, nil
+}
+
+// Close drops all the in-memory stores
+func (p *Provider) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.stores = make(map[string]*Store)
+
+	return nil
+}
+
+// Store in-memory key-value store
+type Store struct {
+	db   map[string][]byte
+	lock sync.RWMutex
+}
+
+// NewStore instance of in-memory store
+func NewStore() *Store {
+	return &Store{db: make(map[string][]byte)}
+}
+
+// Put stores the key-value pair
+func (s *Store) Put(k string, v []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.db[k] = append([]byte(nil), v...)
+
+	return nil
+}
+
+// Get fetches the value associated with the given key
+func (s *Store) Get(k string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	v, ok := s.db[k]
+	if !ok {
+		return nil, storage.ErrDataNotFound
+	}
+
+	return append([]byte(nil), v...), nil
+}
+
+// Delete deletes the key-value pair associated with the given key
+func (s *Store) Delete(k string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.db, k)
+
+	return nil
+}
+
+// Iterate calls fn, in key order, for every key-value pair whose key starts with the given prefix
+func (s *Store) Iterate(prefix string, fn func(k string, v []byte) error) error {
+	s.lock.RLock()
+	var keys []string
+	for k := range s.db {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	s.lock.RUnlock()
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, err := s.Get(k)
+		if err == storage.ErrDataNotFound {
+			// deleted while iterating
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package memstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+func TestMemStore(t *testing.T) {
+	prov := NewProvider()
+	store, err := prov.OpenStore("test")
+	require.NoError(t, err)
+
+	// same store is returned for the same name
+	sameStore, err := prov.OpenStore("test")
+	require.NoError(t, err)
+	require.Equal(t, store, sameStore)
+
+	_, err = store.Get("k1")
+	require.Equal(t, storage.ErrDataNotFound, err)
+
+	value := []byte("v1")
+	require.NoError(t, store.Put("k1", value))
+	value[0] = 'x'
+
+	v, err := store.Get("k1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	require.NoError(t, store.Put("k2", []byte("v2")))
+	require.NoError(t, store.Put("other", []byte("v3")))
+
+	var keys []string
+	require.NoError(t, store.Iterate("k", func(k string, v []byte) error {
+		keys = append(keys, k)
+		return nil
+	}))
+	require.Equal(t, []string{"k1", "k2"}, keys)
+
+	require.Error(t, store.Iterate("", func(k string, v []byte) error {
+		return errors.New("stop")
+	}))
+
+	require.NoError(t, store.Delete("k1"))
+	require.NoError(t, store.Delete("k1"))
+	_, err = store.Get("k1")
+	require.Equal(t, storage.ErrDataNotFound, err)
+
+	require.NoError(t, prov.Close())
+	store, err = prov.OpenStore("test")
+	require.NoError(t, err)
+	_, err = store.Get("k2")
+	require.Equal(t, storage.ErrDataNotFound, err)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package storage
+
+import "errors"
+
+// ErrDataNotFound is returned when data is not found for the given key
+var ErrDataNotFound = errors.New("data not found")
+
+// Provider storage provider interface
+type Provider interface {
+	// OpenStore opens a store with the given name and returns it, the store is created if it doesn't exist
+	OpenStore(name string) (Store, error)
+
+	// Close closes all stores opened by this provider
+	Close() error
+}
+
+// Store key-value storage interface
+type Store interface {
+	// Put stores the key-value pair
+	Put(k string, v []byte) error
+
+	// Get fetches the value associated with the given key, ErrDataNotFound is returned if the key doesn't exist
+	Get(k string) ([]byte, error)
+
+	// Delete deletes the key-value pair associated with the given key, deleting a missing key is not an error
+	Delete(k string) error
+
+	// Iterate calls fn for every key-value pair whose key starts with the given prefix,
+	// iteration stops at the first error returned by fn
+	Iterate(prefix string, fn func(k string, v []byte) error) error
+}
@@ -0,0 +1,59 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"context"
+)
+
+// ContextOutboundTransport is an outbound transport whose sends can be cancelled or given a deadline
+type ContextOutboundTransport interface {
+	OutboundTransport
+
+	// SendContext sends data as Send does, it gives up once the context is done
+	SendContext(ctx context.Context, data string, destination string) (string, error)
+}
+
+// AdaptContext returns the transport if it supports contexts, or an adapter for transports which don't.
+// The adapter can't cancel a send in progress, it returns once the context is done while the send
+// completes in the background.
+func AdaptContext(ot OutboundTransport) ContextOutboundTransport {
+	if cot, ok := ot.(ContextOutboundTransport); ok {
+		return cot
+	}
+
+	return &contextAdapter{OutboundTransport: ot}
+}
+
+type contextAdapter struct {
+	OutboundTransport
+}
+
+type sendResult struct {
+	resp string
+	err  error
+}
+
+func (a *contextAdapter) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// buffered so that the send doesn't block once the caller is gone
+	result := make(chan sendResult, 1)
+	go func() {
+		resp, err := a.Send(data, destination)
+		result <- sendResult{resp: resp, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type blockingTransport struct {
+	release chan struct{}
+}
+
+func (b *blockingTransport) Send(data string, destination string) (string, error) {
+	<-b.release
+	return "ok", nil
+}
+
+type contextTransport struct {
+	blockingTransport
+}
+
+func (c *contextTransport) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	return "context", nil
+}
+
+func TestAdaptContext(t *testing.T) {
+	t.Run("passes through context aware transports", func(t *testing.T) {
+		ct := &contextTransport{}
+		require.Equal(t, ct, AdaptContext(ct))
+	})
+
+	t.Run("send completes", func(t *testing.T) {
+		bt := &blockingTransport{release: make(chan struct{})}
+		close(bt.release)
+
+		resp, err := AdaptContext(bt).SendContext(context.Background(), "data", "http://example.com")
+		require.NoError(t, err)
+		require.Equal(t, "ok", resp)
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		ft := &failingTransport{err: errors.New("send failed")}
+
+		_, err := AdaptContext(ft).SendContext(context.Background(), "data", "http://example.com")
+		require.EqualError(t, err, "send failed")
+	})
+
+	t.Run("context already cancelled", func(t *testing.T) {
+		ft := &failingTransport{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := AdaptContext(ft).SendContext(ctx, "data", "http://example.com")
+		require.Equal(t, context.Canceled, err)
+		require.Zero(t, ft.calls)
+	})
+
+	t.Run("deadline exceeded while sending", func(t *testing.T) {
+		bt := &blockingTransport{release: make(chan struct{})}
+		defer close(bt.release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := AdaptContext(bt).SendContext(ctx, "data", "http://example.com")
+		require.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+type failingTransport struct {
+	err   error
+	calls int
+}
+
+func (f *failingTransport) Send(data string, destination string) (string, error) {
+	f.calls++
+	return "", f.err
+}
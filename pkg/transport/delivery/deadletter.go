@@ -0,0 +1,99 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package delivery
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+)
+
+const (
+	// DeadLetterStoreName name of the store holding the messages which couldn't be delivered
+	DeadLetterStoreName = "deadletter"
+
+	deadLetterKeyPrefix = "dl_"
+)
+
+// DeadLetter is a message which couldn't be delivered
+type DeadLetter struct {
+	ID          string    `json:"id"`
+	Data        string    `json:"data"`
+	Destination string    `json:"destination"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError"`
+	FailedTime  time.Time `json:"failedTime"`
+}
+
+// DeadLetterStore persists the messages which couldn't be delivered so that they can be inspected
+// and sent again
+type DeadLetterStore struct {
+	store storage.Store
+}
+
+// NewDeadLetterStore creates a new dead letter store on top of the given store
+func NewDeadLetterStore(store storage.Store) (*DeadLetterStore, error) {
+	if store == nil {
+		return nil, errors.New("store is mandatory")
+	}
+
+	return &DeadLetterStore{store: store}, nil
+}
+
+// Put saves the dead letter
+func (d *DeadLetterStore) Put(deadLetter *DeadLetter) error {
+	if deadLetter == nil || deadLetter.ID == "" {
+		return errors.New("dead letter id is mandatory")
+	}
+
+	deadLetterBytes, err := json.Marshal(deadLetter)
+	if err != nil {
+		return errors.Wrapf(err, "Marshal Dead Letter Error")
+	}
+
+	return d.store.Put(deadLetterKeyPrefix+deadLetter.ID, deadLetterBytes)
+}
+
+// Get fetches the dead letter with the given ID
+func (d *DeadLetterStore) Get(id string) (*DeadLetter, error) {
+	deadLetterBytes, err := d.store.Get(deadLetterKeyPrefix + id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get dead letter %s", id)
+	}
+
+	deadLetter := &DeadLetter{}
+	if err := json.Unmarshal(deadLetterBytes, deadLetter); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Dead Letter Error")
+	}
+
+	return deadLetter, nil
+}
+
+// List fetches all the dead letters
+func (d *DeadLetterStore) List() ([]*DeadLetter, error) {
+	var deadLetters []*DeadLetter
+	err := d.store.Iterate(deadLetterKeyPrefix, func(k string, v []byte) error {
+		deadLetter := &DeadLetter{}
+		if err := json.Unmarshal(v, deadLetter); err != nil {
+			return errors.Wrapf(err, "Unmarshal Dead Letter Error")
+		}
+		deadLetters = append(deadLetters, deadLetter)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+// Delete deletes the dead letter with the given ID, such as once it was sent again
+func (d *DeadLetterStore) Delete(id string) error {
+	return d.store.Delete(deadLetterKeyPrefix + id)
+}
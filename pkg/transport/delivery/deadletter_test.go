@@ -0,0 +1,65 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package delivery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/storage"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	_, err := NewDeadLetterStore(nil)
+	require.Error(t, err)
+
+	store := memstore.NewStore()
+	deadLetters, err := NewDeadLetterStore(store)
+	require.NoError(t, err)
+
+	first := &DeadLetter{ID: "first", Data: "data", Destination: destination, Attempts: 5,
+		LastError: "status error", FailedTime: time.Now().UTC()}
+	require.NoError(t, deadLetters.Put(first))
+	require.NoError(t, deadLetters.Put(&DeadLetter{ID: "second"}))
+	require.Error(t, deadLetters.Put(&DeadLetter{}))
+	require.Error(t, deadLetters.Put(nil))
+
+	deadLetter, err := deadLetters.Get("first")
+	require.NoError(t, err)
+	require.Equal(t, first.Data, deadLetter.Data)
+	require.Equal(t, first.Attempts, deadLetter.Attempts)
+	require.True(t, first.FailedTime.Equal(deadLetter.FailedTime))
+
+	list, err := deadLetters.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	require.NoError(t, deadLetters.Delete("first"))
+	_, err = deadLetters.Get("first")
+	require.Error(t, err)
+	require.Equal(t, storage.ErrDataNotFound, pkgerrors.Cause(err))
+
+	// invalid entries
+	require.NoError(t, store.Put(deadLetterKeyPrefix+"invalid", []byte("invalid")))
+	_, err = deadLetters.Get("invalid")
+	require.Error(t, err)
+	_, err = deadLetters.List()
+	require.Error(t, err)
+}
+
+// failingStore fails to put values
+type failingStore struct {
+	storage.Store
+}
+
+func (f *failingStore) Put(k string, v []byte) error {
+	return errors.New("put error")
+}
@@ -0,0 +1,277 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package delivery
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const (
+	defaultMaxAttempts  = 5
+	defaultInitialDelay = time.Second
+	defaultMaxDelay     = time.Minute
+)
+
+// RetryableError is implemented by the send errors which know whether sending again may succeed,
+// such as the HTTP status errors of the HTTP outbound transport and the certificate errors of its TLS checks
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// RetryAfterError is implemented by the send errors carrying the delay requested by the other agent
+// before sending again, such as the Retry-After header of an HTTP response
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Error is returned when the message couldn't be delivered, the message was saved as a dead letter
+type Error struct {
+	DeadLetterID string
+	Attempts     int
+	Err          error
+}
+
+// Error returns the last send error
+func (e *Error) Error() string {
+	return fmt.Sprintf("message not delivered after %d attempts, saved as dead letter %s: %s",
+		e.Attempts, e.DeadLetterID, e.Err)
+}
+
+// Cause returns the last send error
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Outbound is an outbound transport retrying to send through the wrapped transport after transient failures,
+// with an exponential backoff and jitter. Messages which can't be delivered go to the dead letter store.
+type Outbound struct {
+	transport    transport.OutboundTransport
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	deadLetters  *DeadLetterStore
+	sleep        func(ctx context.Context, d time.Duration) error
+}
+
+type deliveryOpts struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	deadLetters  *DeadLetterStore
+}
+
+// Opt configures the delivery of outbound messages
+type Opt func(opts *deliveryOpts)
+
+// WithMaxAttempts sets the number of attempts to send a message, 5 by default
+func WithMaxAttempts(maxAttempts int) Opt {
+	return func(opts *deliveryOpts) {
+		opts.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubling after every attempt up to the max delay.
+// Defaults are a second and a minute.
+func WithBackoff(initialDelay, maxDelay time.Duration) Opt {
+	return func(opts *deliveryOpts) {
+		opts.initialDelay = initialDelay
+		opts.maxDelay = maxDelay
+	}
+}
+
+// WithDeadLetterStore sets the store of the messages which couldn't be delivered, in memory by default
+func WithDeadLetterStore(deadLetters *DeadLetterStore) Opt {
+	return func(opts *deliveryOpts) {
+		opts.deadLetters = deadLetters
+	}
+}
+
+// New creates a new outbound transport delivering messages through the given transport
+func New(ot transport.OutboundTransport, opts ...Opt) (*Outbound, error) {
+	if ot == nil {
+		return nil, errors.New("outbound transport is mandatory")
+	}
+
+	delivOpts := &deliveryOpts{
+		maxAttempts:  defaultMaxAttempts,
+		initialDelay: defaultInitialDelay,
+		maxDelay:     defaultMaxDelay,
+	}
+	// Apply options
+	for _, opt := range opts {
+		opt(delivOpts)
+	}
+	if delivOpts.maxAttempts < 1 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	if delivOpts.initialDelay <= 0 || delivOpts.maxDelay < delivOpts.initialDelay {
+		return nil, errors.New("invalid backoff delays")
+	}
+	if delivOpts.deadLetters == nil {
+		deadLetters, err := NewDeadLetterStore(memstore.NewStore())
+		if err != nil {
+			return nil, err
+		}
+		delivOpts.deadLetters = deadLetters
+	}
+
+	return &Outbound{
+		transport:    ot,
+		maxAttempts:  delivOpts.maxAttempts,
+		initialDelay: delivOpts.initialDelay,
+		maxDelay:     delivOpts.maxDelay,
+		deadLetters:  delivOpts.deadLetters,
+		sleep:        sleepContext,
+	}, nil
+}
+
+// Send sends the data to the destination, retrying after transient failures until the max attempts.
+// An Error is returned if the message couldn't be delivered, the last send error is its cause.
+func (o *Outbound) Send(data string, destination string) (string, error) {
+	return o.SendContext(context.Background(), data, destination)
+}
+
+// SendContext sends the data as Send does, retries stop once the context is done.
+// The context error is returned if the context is cancelled, the message isn't saved as a dead letter.
+func (o *Outbound) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	ot := transport.AdaptContext(o.transport)
+
+	var err error
+	attempts := 0
+	for attempts < o.maxAttempts {
+		attempts++
+
+		var resp string
+		resp, err = ot.SendContext(ctx, data, destination)
+		if err == nil {
+			return resp, nil
+		}
+		if !Retryable(err) || attempts == o.maxAttempts {
+			break
+		}
+
+		if o.sleep(ctx, o.delay(attempts, err)) != nil {
+			break
+		}
+	}
+	// the caller gave up on the message, it isn't a dead letter
+	if ctx.Err() == context.Canceled {
+		return "", ctx.Err()
+	}
+
+	deadLetter := &DeadLetter{
+		ID:          uuid.New().String(),
+		Data:        data,
+		Destination: destination,
+		Attempts:    attempts,
+		LastError:   err.Error(),
+		FailedTime:  time.Now().UTC(),
+	}
+	if e := o.deadLetters.Put(deadLetter); e != nil {
+		return "", errors.Wrapf(e, "failed to save dead letter after send error: %s", err)
+	}
+
+	return "", &Error{DeadLetterID: deadLetter.ID, Attempts: attempts, Err: err}
+}
+
+// DeadLetters returns the store of the messages which couldn't be delivered
+func (o *Outbound) DeadLetters() *DeadLetterStore {
+	return o.deadLetters
+}
+
+// Retryable tells whether sending again may succeed after the send error: network failures and
+// errors known to be transient are retried, other errors are permanent. Certificate errors are permanent
+// even though they are returned as network errors, the peer is rejected whatever the attempt.
+func Retryable(err error) bool {
+	network := false
+	for err != nil {
+		switch cause := errors.Cause(err).(type) {
+		case RetryableError:
+			return cause.Retryable()
+		case x509.CertificateInvalidError, x509.HostnameError, x509.UnknownAuthorityError,
+			x509.ConstraintViolationError, x509.SystemRootsError:
+			return false
+		case *url.Error:
+			network, err = true, cause.Err
+		case *net.OpError:
+			network, err = true, cause.Err
+		case net.Error:
+			return true
+		case interface{ Unwrap() error }:
+			err = cause.Unwrap()
+		default:
+			return network
+		}
+	}
+
+	return network
+}
+
+// delay returns the delay before the next attempt, the exponential backoff with jitter
+// unless the other agent requested a longer one, up to the max delay
+func (o *Outbound) delay(attempt int, err error) time.Duration {
+	backoff := o.initialDelay
+	for i := 1; i < attempt && backoff < o.maxDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > o.maxDelay {
+		backoff = o.maxDelay
+	}
+
+	// equal jitter: between half and the full backoff
+	delay := backoff/2 + randomDuration(backoff/2)
+
+	if retryAfterErr, ok := errors.Cause(err).(RetryAfterError); ok && retryAfterErr.RetryAfter() > delay {
+		delay = retryAfterErr.RetryAfter()
+	}
+	if delay > o.maxDelay {
+		delay = o.maxDelay
+	}
+
+	return delay
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// randomDuration returns a random duration up to max
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)+1))
+	if err != nil {
+		return max
+	}
+
+	return time.Duration(n.Int64())
+}
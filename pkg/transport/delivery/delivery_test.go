@@ -0,0 +1,250 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package delivery
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const destination = "https://example.com/agent"
+
+func TestOutbound_Retry(t *testing.T) {
+	ot := &scriptedTransport{errs: []error{
+		&statusError{retryable: true},
+		pkgerrors.Wrap(&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "post failed"),
+	}}
+	o, delays := newOutbound(t, ot, WithBackoff(time.Second, 10*time.Second))
+
+	resp, err := o.Send("data", destination)
+	require.NoError(t, err)
+	require.Equal(t, "response", resp)
+	require.Equal(t, 3, ot.calls)
+
+	// exponential backoff with jitter
+	require.Len(t, *delays, 2)
+	require.True(t, (*delays)[0] >= 500*time.Millisecond && (*delays)[0] <= time.Second)
+	require.True(t, (*delays)[1] >= time.Second && (*delays)[1] <= 2*time.Second)
+
+	deadLetters, err := o.DeadLetters().List()
+	require.NoError(t, err)
+	require.Empty(t, deadLetters)
+}
+
+func TestOutbound_RetryAfter(t *testing.T) {
+	ot := &scriptedTransport{errs: []error{&statusError{retryable: true, retryAfter: time.Hour}}}
+	o, delays := newOutbound(t, ot)
+
+	_, err := o.Send("data", destination)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{time.Minute}, *delays)
+
+	// requested delays are honoured up to the max delay
+	ot = &scriptedTransport{errs: []error{&statusError{retryable: true, retryAfter: 30 * time.Second}}}
+	o, delays = newOutbound(t, ot)
+
+	_, err = o.Send("data", destination)
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{30 * time.Second}, *delays)
+}
+
+func TestOutbound_DeadLetter(t *testing.T) {
+	sendErr := &statusError{retryable: true}
+	ot := &scriptedTransport{errs: []error{sendErr, sendErr, sendErr, sendErr}}
+	o, delays := newOutbound(t, ot, WithMaxAttempts(3), WithBackoff(time.Second, 2*time.Second))
+
+	_, err := o.Send("data", destination)
+	require.Error(t, err)
+	deliveryErr, ok := err.(*Error)
+	require.True(t, ok)
+	require.Equal(t, 3, deliveryErr.Attempts)
+	require.Equal(t, sendErr, pkgerrors.Cause(err))
+	require.Equal(t, 3, ot.calls)
+	require.Len(t, *delays, 2)
+	require.True(t, (*delays)[1] <= 2*time.Second)
+
+	deadLetter, err := o.DeadLetters().Get(deliveryErr.DeadLetterID)
+	require.NoError(t, err)
+	require.Equal(t, "data", deadLetter.Data)
+	require.Equal(t, destination, deadLetter.Destination)
+	require.Equal(t, 3, deadLetter.Attempts)
+	require.Equal(t, sendErr.Error(), deadLetter.LastError)
+	require.False(t, deadLetter.FailedTime.IsZero())
+}
+
+func TestOutbound_PermanentFailure(t *testing.T) {
+	for _, sendErr := range []error{
+		&statusError{retryable: false},
+		&transport.NoTransportForSchemeError{Scheme: "mem", Destination: "mem://agent"},
+		errors.New("unknown error"),
+	} {
+		ot := &scriptedTransport{errs: []error{sendErr}}
+		o, delays := newOutbound(t, ot)
+
+		_, err := o.Send("data", destination)
+		require.Error(t, err)
+		require.Equal(t, sendErr, pkgerrors.Cause(err))
+		require.Equal(t, 1, ot.calls)
+		require.Empty(t, *delays)
+
+		deadLetters, err := o.DeadLetters().List()
+		require.NoError(t, err)
+		require.Len(t, deadLetters, 1)
+		require.Equal(t, 1, deadLetters[0].Attempts)
+	}
+}
+
+func TestOutbound_Context(t *testing.T) {
+	sendErr := &statusError{retryable: true}
+	ot := &scriptedTransport{errs: []error{sendErr, sendErr}}
+	o, err := New(ot, WithBackoff(time.Hour, time.Hour))
+	require.NoError(t, err)
+
+	// retries stop once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = o.SendContext(ctx, "data", destination)
+	require.Error(t, err)
+	require.Equal(t, sendErr, pkgerrors.Cause(err))
+	require.Equal(t, 1, ot.calls)
+
+	deadLetters, err := o.DeadLetters().List()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+
+	// nothing is sent with a done context
+	_, err = o.SendContext(ctx, "data", destination)
+	require.Error(t, err)
+	require.Equal(t, context.DeadlineExceeded, pkgerrors.Cause(err))
+	require.Equal(t, 1, ot.calls)
+
+	require.NoError(t, sleepContext(context.Background(), time.Millisecond))
+}
+
+func TestOutbound_Cancel(t *testing.T) {
+	sendErr := &statusError{retryable: true}
+	ot := &scriptedTransport{errs: []error{sendErr, sendErr}}
+	o, err := New(ot, WithBackoff(time.Hour, time.Hour))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	// the message cancelled by the caller isn't a dead letter
+	_, err = o.SendContext(ctx, "data", destination)
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, ot.calls)
+
+	deadLetters, err := o.DeadLetters().List()
+	require.NoError(t, err)
+	require.Empty(t, deadLetters)
+}
+
+func TestOutbound_DeadLetterFailure(t *testing.T) {
+	deadLetters, err := NewDeadLetterStore(&failingStore{Store: memstore.NewStore()})
+	require.NoError(t, err)
+	o, _ := newOutbound(t, &scriptedTransport{errs: []error{errors.New("send error")}},
+		WithDeadLetterStore(deadLetters))
+
+	_, err = o.Send("data", destination)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to save dead letter")
+	require.Contains(t, err.Error(), "send error")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	require.Error(t, err)
+
+	ot := &scriptedTransport{}
+	_, err = New(ot, WithMaxAttempts(0))
+	require.Error(t, err)
+	_, err = New(ot, WithBackoff(0, time.Second))
+	require.Error(t, err)
+	_, err = New(ot, WithBackoff(time.Minute, time.Second))
+	require.Error(t, err)
+}
+
+func TestRetryable(t *testing.T) {
+	require.True(t, Retryable(&statusError{retryable: true}))
+	require.False(t, Retryable(&statusError{retryable: false}))
+	require.True(t, Retryable(pkgerrors.Wrap(&net.DNSError{IsTimeout: true}, "lookup failed")))
+	require.False(t, Retryable(errors.New("other")))
+	require.True(t, Retryable(&url.Error{Op: "Post", URL: destination, Err: io.EOF}))
+
+	// certificate errors are permanent
+	require.False(t, Retryable(&url.Error{Op: "Post", URL: destination, Err: &net.OpError{
+		Op: "remote error", Err: &statusError{retryable: false}}}))
+	require.False(t, Retryable(pkgerrors.Wrap(&url.Error{Op: "Post", URL: destination,
+		Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}}, "post failed")))
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err := http.Get(server.URL) // nolint: bodyclose
+	require.Error(t, err)
+	require.False(t, Retryable(err))
+}
+
+func newOutbound(t *testing.T, ot transport.OutboundTransport, opts ...Opt) (*Outbound, *[]time.Duration) {
+	o, err := New(ot, opts...)
+	require.NoError(t, err)
+
+	delays := &[]time.Duration{}
+	o.sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return ctx.Err()
+	}
+
+	return o, delays
+}
+
+// scriptedTransport fails with the scripted errors then succeeds
+type scriptedTransport struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedTransport) Send(data string, destination string) (string, error) {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return "", s.errs[s.calls-1]
+	}
+	return "response", nil
+}
+
+type statusError struct {
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return "status error"
+}
+
+func (e *statusError) Retryable() bool {
+	return e.retryable
+}
+
+func (e *statusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
@@ -0,0 +1,25 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import "net"
+
+// IsWildcardAddr tells whether the listen address binds every interface (e.g. ":8080", "0.0.0.0:8080", "[::]:8080"),
+// such a listener has no address other agents can reach it at
+func IsWildcardAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		// invalid addresses fail when listening
+		return false
+	}
+	if host == "" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
@@ -0,0 +1,22 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWildcardAddr(t *testing.T) {
+	for _, addr := range []string{":8080", "0.0.0.0:8080", "[::]:8080", ":0"} {
+		require.True(t, IsWildcardAddr(addr), addr)
+	}
+	for _, addr := range []string{"localhost:8080", "127.0.0.1:0", "[::1]:8080", "example.com:443", "invalid"} {
+		require.False(t, IsWildcardAddr(addr), addr)
+	}
+}
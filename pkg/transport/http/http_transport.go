@@ -7,22 +7,32 @@ SPDX-License-Identifier: Apache-2.0
 package http
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
 	"github.com/trustbloc/aries-framework-go/pkg/transport"
 	tlsCertPool "github.com/trustbloc/aries-framework-go/pkg/transport/http/tls"
 )
 
-const commContentType = "application/didcomm-envelope-enc"
+const (
+	commContentType = transport.MediaTypeEnvelope
+
+	// defaultDialTimeout dial timeout of the default transport, used if the config has no timeout
+	defaultDialTimeout = 30 * time.Second
+)
 
 // OutboundCommHTTP is the HTTP transport implementation of CommTransport
 // it embeds an http.Server and has an http.Client instance
@@ -35,7 +45,33 @@ type OutboundCommHTTP struct {
 type OutboundCommConfig struct {
 	Timeout      time.Duration
 	CACertsPaths string
-	caCertPool   tlsCertPool.CertPool
+	// ClientCertPath and ClientKeyPath are the PEM files of the certificate presented to agents requiring
+	// mutual TLS
+	ClientCertPath string
+	ClientKeyPath  string
+	// GetClientCertificate returns the certificate presented to agents requiring mutual TLS,
+	// it takes precedence over the client certificate files
+	GetClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	// PinnedSPKIHashes are base64 encoded SHA-256 hashes of server public keys (see tls.SPKIHash),
+	// when set the chain presented by the server must contain one of them
+	PinnedSPKIHashes []string
+	// CACertsDir is a directory of PEM files polled for CA certs added or removed at runtime, on top of the
+	// CACertsPaths ones (see tls.WatchDir)
+	CACertsDir string
+	// CACertsDirInterval is the interval CACertsDir is polled at, tls.DefaultWatchInterval if not set
+	CACertsDirInterval time.Duration
+	// CRLPaths are comma separated paths of CRL files (PEM or DER), servers presenting a certificate
+	// revoked by them are rejected
+	CRLPaths string
+	// VerifyOCSPStaple rejects servers stapling an invalid OCSP response, one revoking their certificate
+	// or one past its next update
+	VerifyOCSPStaple bool
+	// OCSPMaxAge rejects stapled OCSP responses produced longer ago, no limit if not set
+	OCSPMaxAge time.Duration
+	// MinTLSVersion is the minimum TLS version accepted, TLS 1.2 if not set
+	MinTLSVersion uint16
+	caCertPool    tlsCertPool.CertPool
+	certsWatcher  *tlsCertPool.DirWatcher
 }
 
 // NewOutboundCommFromConfig creates a new instance of CommHTTP to Post requests to other Agents
@@ -74,101 +110,316 @@ func NewOutboundCommFromClient(client *http.Client) (*OutboundCommHTTP, error) {
 	return cm, nil
 }
 
+// CertPool returns the CA cert pool of the transport, certs added to it are trusted by the connections
+// opened afterwards. It is nil if the transport was created from a client.
+func (cs *OutboundCommHTTP) CertPool() tlsCertPool.CertPool {
+	if cs.cfg == nil {
+		return nil
+	}
+
+	return cs.cfg.caCertPool
+}
+
+// Close stops watching the CA certs directory, if any
+func (cs *OutboundCommHTTP) Close() error {
+	if cs.cfg != nil && cs.cfg.certsWatcher != nil {
+		cs.cfg.certsWatcher.Stop()
+	}
+
+	return nil
+}
+
 // Send sends a2a exchange data via HTTP (client side)
 func (cs *OutboundCommHTTP) Send(data string, url string) (string, error) {
-	resp, err := cs.client.Post(url, commContentType, bytes.NewBuffer([]byte(data)))
+	return cs.SendContext(context.Background(), data, url)
+}
+
+// SendContext sends a2a exchange data via HTTP (client side), the request is cancelled once the context is done.
+// The context deadline applies on top of the client timeout.
+func (cs *OutboundCommHTTP) SendContext(ctx context.Context, data string, url string) (string, error) {
+	resp, err := cs.SendMessage(ctx, &transport.Message{MediaType: commContentType, Body: strings.NewReader(data)}, url)
+	if err != nil || resp == nil {
+		return "", err
+	}
+
+	respData, err := resp.Bytes()
 	if err != nil {
-		log.Printf("HTTP Transport - Error posting did envelope to agent at [%s]: %v", url, err)
 		return "", err
 	}
+	return string(respData), nil
+}
 
-	var respData string
-	if resp != nil {
-		isStatusSuccess := resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK
-		if !isStatusSuccess {
-			return "", errors.Errorf("Warning - Received non success POST HTTP status from agent at [%s]: status : %v", url, resp.Status)
-		}
-		// handle response
-		defer func() {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Printf("HTTP Transport - Error closing response body: %v", err)
-			}
-		}()
-		buf := new(bytes.Buffer)
-		_, err := buf.ReadFrom(resp.Body)
+// SendMessage posts the message with its media type as content type, the body is streamed as the request
+// is written. The response is nil if the agent didn't send one back.
+func (cs *OutboundCommHTTP) SendMessage(ctx context.Context, msg *transport.Message,
+	url string) (*transport.Message, error) {
+	mediaType := msg.MediaType
+	if mediaType == "" {
+		mediaType = commContentType
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, msg.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request to agent at [%s]", url)
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := cs.client.Do(req.WithContext(ctx))
+	if err != nil {
+		log.Printf("HTTP Transport - Error posting did envelope to agent at [%s]: %v", url, err)
+		return nil, err
+	}
+	defer func() {
+		err := resp.Body.Close()
 		if err != nil {
-			return "", err
+			log.Printf("HTTP Transport - Error closing response body: %v", err)
 		}
-		respData = buf.String()
+	}()
+
+	isStatusSuccess := resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK
+	if !isStatusSuccess {
+		return nil, newStatusError(url, resp)
+	}
+
+	respData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
 	}
-	return respData, nil
+	if len(respData) == 0 {
+		return nil, nil
+	}
+	return transport.NewMessage(resp.Header.Get("Content-Type"), respData), nil
 }
 
 // creates a new instance of HTTP transport as a client
 func newHTTPClient(cfg *OutboundCommConfig) (*http.Client, error) {
-	var err error
-	var caCertPool tlsCertPool.CertPool
-	if cfg.CACertsPaths != "" {
-		caCertPool, err = tlsCertPool.NewCertPool(false)
+	caCertPool, err := newCACertPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the proxy, timeouts, idle connections and HTTP/2 settings of the default transport
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	// update the config's caCertPool
+	cfg.caCertPool = &closingCertPool{CertPool: caCertPool, transport: httpTransport}
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		log.Printf("HTTP Transport - Failed to build TLS config: %s", err)
+		return nil, err
+	}
+	httpTransport.TLSClientConfig = tlsConfig
+	httpTransport.DialTLSContext = dialTLSContext(cfg, tlsConfig, httpTransport.TLSHandshakeTimeout)
+
+	if cfg.CACertsDir != "" {
+		cfg.certsWatcher, err = tlsCertPool.WatchDir(cfg.caCertPool, cfg.CACertsDir, cfg.CACertsDirInterval)
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to create new Cert Pool")
+			return nil, err
 		}
+	}
 
-		caCertsPaths := strings.Split(cfg.CACertsPaths, ",")
-		var caCerts []string
-		for _, path := range caCertsPaths {
-			if path == "" {
-				continue
-			}
-			// Create a pool with server certificates
-			caCert, e := ioutil.ReadFile(filepath.Clean(path))
-			if e != nil {
-				return nil, errors.Wrap(e, "Failed Reading server certificate")
-			}
-			caCerts = append(caCerts, string(caCert))
+	return &http.Client{
+		Transport: httpTransport,
+		Timeout:   cfg.Timeout,
+	}, nil
+}
+
+// newCACertPool returns a cert pool with the CA certs files of the config,
+// the system cert pool if the config has neither CA certs files nor directory
+func newCACertPool(cfg *OutboundCommConfig) (tlsCertPool.CertPool, error) {
+	if cfg.CACertsPaths == "" && cfg.CACertsDir == "" {
+		return tlsCertPool.NewCertPool(true)
+	}
+
+	caCertPool, err := tlsCertPool.NewCertPool(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create new Cert Pool")
+	}
+
+	var caCerts []string
+	for _, path := range strings.Split(cfg.CACertsPaths, ",") {
+		if path == "" {
+			continue
+		}
+		// Create a pool with server certificates
+		caCert, e := ioutil.ReadFile(filepath.Clean(path))
+		if e != nil {
+			return nil, errors.Wrap(e, "Failed Reading server certificate")
 		}
+		caCerts = append(caCerts, string(caCert))
+	}
 
-		caCertPool.Add(tlsCertPool.DecodeCerts(caCerts)...)
-	} else {
-		caCertPool, err = tlsCertPool.NewCertPool(true)
+	caCertPool.Add(tlsCertPool.DecodeCerts(caCerts)...)
+
+	return caCertPool, nil
+}
+
+// closingCertPool closes the idle connections of the transport when certs are removed from the pool,
+// so that connections verified against a removed cert aren't reused. Connections in use at that time
+// are reused until they are closed.
+type closingCertPool struct {
+	tlsCertPool.CertPool
+	transport *http.Transport
+}
+
+func (p *closingCertPool) Remove(certs ...*x509.Certificate) {
+	p.CertPool.Remove(certs...)
+	p.closeIdleConnections(len(certs))
+}
+
+func (p *closingCertPool) RemoveFrom(source string, certs ...*x509.Certificate) {
+	p.CertPool.RemoveFrom(source, certs...)
+	p.closeIdleConnections(len(certs))
+}
+
+func (p *closingCertPool) Replace(certs ...*x509.Certificate) {
+	p.CertPool.Replace(certs...)
+	p.transport.CloseIdleConnections()
+}
+
+func (p *closingCertPool) closeIdleConnections(removed int) {
+	if removed > 0 {
+		p.transport.CloseIdleConnections()
+	}
+}
+
+// dialTLSContext returns a dial function trusting the current certs of the config's cert pool,
+// so that certs added or removed at runtime apply to the connections opened afterwards
+func dialTLSContext(cfg *OutboundCommConfig, tlsConfig *tls.Config,
+	handshakeTimeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialTimeout := cfg.Timeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultDialTimeout
+	}
+	netDialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// the handshake is bounded as the default transport bounds it
+		ctx, cancel := context.WithTimeout(ctx, dialTimeout+handshakeTimeout)
+		defer cancel()
+
+		rootCAs, err := cfg.caCertPool.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		connConfig := tlsConfig.Clone()
+		connConfig.RootCAs = rootCAs
+
+		dialer := &tls.Dialer{NetDialer: netDialer, Config: connConfig}
+		conn, err := dialer.DialContext(ctx, network, addr)
 		if err != nil {
 			return nil, err
 		}
+
+		if cfg.VerifyOCSPStaple {
+			if err = verifyOCSPStaple(conn.(*tls.Conn), addr, cfg.OCSPMaxAge); err != nil {
+				return nil, err
+			}
+		}
+
+		return conn, nil
 	}
+}
 
-	// update the config's caCertPool
-	cfg.caCertPool = caCertPool
+// verifyOCSPStaple checks the OCSP response stapled by the server, the connection is closed if it is rejected
+func verifyOCSPStaple(conn *tls.Conn, addr string, maxAge time.Duration) error {
+	state := conn.ConnectionState()
+	err := tlsCertPool.VerifyOCSPStaple(state.OCSPResponse, state.VerifiedChains, maxAge)
+	if err != nil {
+		if e := conn.Close(); e != nil {
+			log.Printf("HTTP Transport - Error closing connection to %s: %v", addr, e)
+		}
+	}
+
+	return err
+}
 
-	tlsConfig, err := buildNewCertPool(cfg.caCertPool)
+func buildTLSConfig(cfg *OutboundCommConfig) (*tls.Config, error) {
+	cp, err := cfg.caCertPool.Get()
 	if err != nil {
-		log.Printf("HTTP Transport - Failed to build/get Cert Pool: %s", err)
 		return nil, err
 	}
 
-	return &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-		},
-		Timeout: cfg.Timeout,
-	}, nil
-}
+	minVersion := cfg.MinTLSVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+
+	clientCerts, err := loadClientCertificates(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-func buildNewCertPool(tlsCertPool tlsCertPool.CertPool) (*tls.Config, error) {
-	cp, err := tlsCertPool.Get()
+	verifyPeerCertificate, err := peerCertificateVerifier(cfg)
 	if err != nil {
 		return nil, err
 	}
-	// Create TLS configuration
-	// add other agents (server) certs here.. for now we trust CA certs
-	// Since this config cannot be manipulated once the client is created,
-	// we must find a way to trust other agents here (or only trust root CAs).
-	tlsConfig := &tls.Config{
+
+	return &tls.Config{
 		// if RootCAs is nil, client will use the host's root CA instead
-		RootCAs:      cp,
-		Certificates: nil,
+		RootCAs:               cp,
+		MinVersion:            minVersion,
+		Certificates:          clientCerts,
+		GetClientCertificate:  cfg.GetClientCertificate,
+		VerifyPeerCertificate: verifyPeerCertificate,
+	}, nil
+}
+
+type verifyPeerFunc func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// peerCertificateVerifier returns the checks of the server chains on top of the standard verification,
+// the pinned public keys and the revoked certificates, nil if there is none
+func peerCertificateVerifier(cfg *OutboundCommConfig) (verifyPeerFunc, error) {
+	var verifiers []verifyPeerFunc
+	if len(cfg.PinnedSPKIHashes) > 0 {
+		verifiers = append(verifiers, tlsCertPool.VerifyPinnedSPKI(cfg.PinnedSPKIHashes...))
+	}
+
+	if cfg.CRLPaths != "" {
+		var crlPaths []string
+		for _, path := range strings.Split(cfg.CRLPaths, ",") {
+			if path != "" {
+				crlPaths = append(crlPaths, path)
+			}
+		}
+
+		revocationChecker, err := tlsCertPool.NewRevocationChecker(crlPaths...)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, revocationChecker.VerifyPeerCertificate)
 	}
-	return tlsConfig, nil
+
+	if len(verifiers) == 0 {
+		return nil, nil
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, verify := range verifiers {
+			if err := verify(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// loadClientCertificates loads the client certificate files of the config, if any
+func loadClientCertificates(cfg *OutboundCommConfig) ([]tls.Certificate, error) {
+	if cfg.GetClientCertificate != nil || (cfg.ClientCertPath == "" && cfg.ClientKeyPath == "") {
+		return nil, nil
+	}
+	if cfg.ClientCertPath == "" || cfg.ClientKeyPath == "" {
+		return nil, errors.New("both client certificate and key paths are required for mutual TLS")
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(filepath.Clean(cfg.ClientCertPath), filepath.Clean(cfg.ClientKeyPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed Reading client certificate")
+	}
+
+	return []tls.Certificate{clientCert}, nil
 }
 
 // DIDCommRequestHandler will create a new handler to enforce Did-Comm HTTP transport specs
@@ -200,8 +451,94 @@ func DIDCommRequestHandler(handler http.Handler, commHandler *transport.DIDCommH
 	})
 }
 
+// DIDCommDispatchHandler will create a new handler to enforce Did-Comm HTTP transport specs on the single
+// agent endpoint path, inbound messages are routed by their @type through the dispatcher
+// then other requests are routed to the passed in handler argument
+func DIDCommDispatchHandler(handler http.Handler, path string, msgDispatcher *dispatcher.Dispatcher) http.Handler {
+	if msgDispatcher == nil {
+		panic("Missing mandatory path and dispatcher")
+	}
+
+	return DIDCommInboundHandler(handler, path, msgDispatcher.Dispatch)
+}
+
+// DIDCommInboundHandler will create a new handler to enforce Did-Comm HTTP transport specs on the single
+// agent endpoint path, inbound payloads are passed to the inbound function, such as one unpacking envelopes
+// before dispatching them, then other requests are routed to the passed in handler argument
+func DIDCommInboundHandler(handler http.Handler, path string, inbound func([]byte) error) http.Handler {
+	if path == "" || inbound == nil {
+		panic("Missing mandatory path and inbound function")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path {
+			processPOSTRequest(w, r, inbound)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// DIDCommMessageHandler will create a new handler accepting messages of the given media types on the path,
+// DIDComm envelopes by default, their body is streamed to the inbound function without being buffered
+// then other requests are routed to the passed in handler argument
+func DIDCommMessageHandler(handler http.Handler, path string, inbound transport.MessageHandler,
+	mediaTypes ...string) http.Handler {
+	if path == "" || inbound == nil {
+		panic("Missing mandatory path and inbound function")
+	}
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{commContentType}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path {
+			processMessageRequest(w, r, inbound, mediaTypes)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// processMessageRequest passes the body of the request to the inbound function if its media type is accepted
+func processMessageRequest(w http.ResponseWriter, r *http.Request, inbound transport.MessageHandler,
+	mediaTypes []string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "HTTP Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ct := r.Header.Get("Content-type")
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil || !contains(mediaTypes, mediaType) {
+		http.Error(w, fmt.Sprintf("Unsupported Content-type \"%s\"", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	writeResponse(w, nil, inbound(&transport.Message{MediaType: mediaType, Body: r.Body}))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // TODO Log error message with common trustbloc/logger-lib
 func processPOSTRequest(w http.ResponseWriter, r *http.Request, router func([]byte) error) {
+	processReturnRouteRequest(w, r, func(payload []byte) ([]byte, error) {
+		return nil, router(payload)
+	})
+}
+
+// processReturnRouteRequest processes the request as processPOSTRequest does,
+// the response of the router is sent back in the response body if not empty
+func processReturnRouteRequest(w http.ResponseWriter, r *http.Request, router func([]byte) ([]byte, error)) {
 	if valid := validMethodAndContentType(w, r); !valid {
 		return
 	}
@@ -209,13 +546,45 @@ func processPOSTRequest(w http.ResponseWriter, r *http.Request, router func([]by
 	if !valid {
 		return
 	}
-	err := router(body)
+	response, err := router(body)
+	writeResponse(w, response, err)
+}
+
+// writeResponse sends back the response of a router, a problem report or an error status if it failed
+func writeResponse(w http.ResponseWriter, response []byte, err error) {
+	if problemErr, ok := errors.Cause(err).(*dispatcher.ProblemReportError); ok {
+		writeProblemReport(w, problemErr)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error processing the request", http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusAccepted)
+	if len(response) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", commContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(response); err != nil {
+		log.Printf("HTTP Transport - Error writing return route response: %v", err)
+	}
+}
 
+// writeProblemReport sends the problem report back to the sender
+func writeProblemReport(w http.ResponseWriter, problemErr *dispatcher.ProblemReportError) {
+	report, err := json.Marshal(problemErr.Report)
+	if err != nil {
+		http.Error(w, "Error processing the request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if _, err := w.Write(report); err != nil {
+		log.Printf("HTTP Transport - Error writing problem report: %v", err)
+	}
 }
 
 // validateAndGetPayload validate and get the payload from the request
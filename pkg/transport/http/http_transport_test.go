@@ -7,19 +7,33 @@
 package http
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
 	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/delivery"
+	tlsCertPool "github.com/trustbloc/aries-framework-go/pkg/transport/http/tls"
+	"golang.org/x/crypto/ocsp"
 )
 
 type httpTestCase struct {
@@ -387,3 +401,551 @@ func TestNewOutboundComm(t *testing.T) {
 	}
 
 }
+
+func TestNewOutboundComm_DefaultTransport(t *testing.T) {
+	oc, err := NewOutboundCommFromConfig(&OutboundCommConfig{Timeout: 10 * time.Second})
+	require.NoError(t, err)
+
+	// the settings of the default transport are kept
+	httpTransport, ok := oc.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	require.NotNil(t, httpTransport.Proxy)
+	require.NotNil(t, httpTransport.DialContext)
+	require.Equal(t, defaultTransport.TLSHandshakeTimeout, httpTransport.TLSHandshakeTimeout)
+	require.Equal(t, defaultTransport.MaxIdleConns, httpTransport.MaxIdleConns)
+	require.Equal(t, defaultTransport.IdleConnTimeout, httpTransport.IdleConnTimeout)
+	require.True(t, httpTransport.ForceAttemptHTTP2)
+	require.NotNil(t, httpTransport.TLSClientConfig)
+	require.NotNil(t, httpTransport.DialTLSContext)
+}
+
+func TestOutboundCommHTTP_SendContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	oc, err := NewOutboundCommFromClient(&http.Client{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = oc.SendContext(ctx, "data", server.URL)
+	require.Error(t, err)
+	require.Equal(t, context.DeadlineExceeded, ctx.Err())
+	require.True(t, time.Since(start) < clientTimeout)
+}
+
+func TestOutboundCommHTTP_SendMessage(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(data)
+
+		if body == "ping" {
+			w.Header().Set("Content-Type", transport.MediaTypeJSON)
+			_, err = w.Write([]byte("pong"))
+			require.NoError(t, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	oc, err := NewOutboundCommFromClient(&http.Client{})
+	require.NoError(t, err)
+
+	resp, err := oc.SendMessage(context.Background(), transport.NewMessage(transport.MediaTypeJSON, []byte("ping")),
+		server.URL)
+	require.NoError(t, err)
+	require.Equal(t, transport.MediaTypeJSON, contentType)
+	require.Equal(t, "ping", body)
+	require.Equal(t, transport.MediaTypeJSON, resp.MediaType)
+	data, err := resp.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(data))
+
+	// the body is streamed and the media type defaults to envelopes
+	reader, writer := io.Pipe()
+	go func() {
+		_, e := writer.Write([]byte("streamed"))
+		require.NoError(t, e)
+		require.NoError(t, writer.Close())
+	}()
+	resp, err = oc.SendMessage(context.Background(), &transport.Message{Body: reader}, server.URL)
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Equal(t, commContentType, contentType)
+	require.Equal(t, "streamed", body)
+
+	_, err = oc.SendMessage(context.Background(), transport.NewMessage(commContentType, nil), "%")
+	require.Error(t, err)
+}
+
+func TestOutboundCommHTTP_MutualTLS(t *testing.T) {
+	serverCert, err := tls.LoadX509KeyPair(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem")
+	require.NoError(t, err)
+	caCert, err := ioutil.ReadFile(certPrefix + "ec-cacert.pem")
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(caCert))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MaxVersion:   tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+	// the server certificate is issued for localhost
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	clientCert, err := tls.LoadX509KeyPair(certPrefix+"ec-pubCert2.pem", certPrefix+"ec-key2.pem")
+	require.NoError(t, err)
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+
+	send := func(cfg *OutboundCommConfig) error {
+		cfg.Timeout = clientTimeout
+		cfg.CACertsPaths = certPrefix + "ec-pubCert1.pem"
+		oc, e := NewOutboundCommFromConfig(cfg)
+		require.NoError(t, e)
+
+		_, e = oc.Send("data", url)
+		return e
+	}
+
+	require.Error(t, send(&OutboundCommConfig{}))
+	require.NoError(t, send(&OutboundCommConfig{
+		ClientCertPath: certPrefix + "ec-pubCert2.pem",
+		ClientKeyPath:  certPrefix + "ec-key2.pem",
+	}))
+	require.NoError(t, send(&OutboundCommConfig{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		},
+	}))
+
+	// server certificate pinning
+	require.NoError(t, send(&OutboundCommConfig{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		},
+		PinnedSPKIHashes: []string{tlsCertPool.SPKIHash(serverLeaf)},
+	}))
+	err = send(&OutboundCommConfig{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		},
+		PinnedSPKIHashes: []string{"unknown"},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no pinned public key")
+	require.False(t, delivery.Retryable(err))
+
+	// the server doesn't support TLS 1.3
+	require.Error(t, send(&OutboundCommConfig{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		},
+		MinTLSVersion: tls.VersionTLS13,
+	}))
+
+	// invalid client certificate files
+	_, err = NewOutboundCommFromConfig(&OutboundCommConfig{ClientCertPath: certPrefix + "ec-pubCert2.pem"})
+	require.EqualError(t, err, "both client certificate and key paths are required for mutual TLS")
+	_, err = NewOutboundCommFromConfig(&OutboundCommConfig{ClientCertPath: "badpath", ClientKeyPath: "badpath"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Failed Reading client certificate")
+}
+
+func TestOutboundCommHTTP_CertPoolReload(t *testing.T) {
+	serverCert, err := tls.LoadX509KeyPair(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem")
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// new connection for each request to verify the server against the current certs
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server.StartTLS()
+	defer server.Close()
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+
+	t.Run("certs added at runtime", func(t *testing.T) {
+		oc, err := NewOutboundCommFromConfig(&OutboundCommConfig{
+			Timeout:      clientTimeout,
+			CACertsPaths: certPrefix + "ec-pubCert2.pem",
+		})
+		require.NoError(t, err)
+
+		_, err = oc.Send("data", url)
+		require.Error(t, err)
+
+		oc.CertPool().Add(serverLeaf)
+		_, err = oc.Send("data", url)
+		require.NoError(t, err)
+		require.NoError(t, oc.Close())
+	})
+
+	t.Run("watched certs directory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "certs")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+		oc, err := NewOutboundCommFromConfig(&OutboundCommConfig{
+			Timeout:            clientTimeout,
+			CACertsDir:         dir,
+			CACertsDirInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, oc.Close()) }()
+
+		_, err = oc.Send("data", url)
+		require.Error(t, err)
+
+		pemCert, err := ioutil.ReadFile(certPrefix + "ec-pubCert1.pem")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "agent.pem"), pemCert, 0600))
+		waitForSend(t, oc, url, true)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "agent.pem")))
+		waitForSend(t, oc, url, false)
+	})
+
+	t.Run("watched certs directory with the CA certs files", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "certs")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+		pemCert, err := ioutil.ReadFile(certPrefix + "ec-pubCert1.pem")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "agent.pem"), pemCert, 0600))
+
+		oc, err := NewOutboundCommFromConfig(&OutboundCommConfig{
+			Timeout:            clientTimeout,
+			CACertsPaths:       certPrefix + "ec-pubCert1.pem",
+			CACertsDir:         dir,
+			CACertsDirInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, oc.Close()) }()
+
+		// the cert of the CA certs files is kept when its copy is removed from the directory
+		require.NoError(t, os.Remove(filepath.Join(dir, "agent.pem")))
+		time.Sleep(100 * time.Millisecond)
+		_, err = oc.Send("data", url)
+		require.NoError(t, err)
+	})
+
+	_, err = NewOutboundCommFromConfig(&OutboundCommConfig{CACertsDir: "badpath"})
+	require.Error(t, err)
+
+	oc, err := NewOutboundCommFromClient(&http.Client{})
+	require.NoError(t, err)
+	require.Nil(t, oc.CertPool())
+	require.NoError(t, oc.Close())
+}
+
+func TestOutboundCommHTTP_CertRemovalClosesConnections(t *testing.T) {
+	serverCert, err := tls.LoadX509KeyPair(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem")
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server.StartTLS()
+	defer server.Close()
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+
+	for _, remove := range []func(oc *OutboundCommHTTP){
+		func(oc *OutboundCommHTTP) { oc.CertPool().Remove(serverLeaf) },
+		func(oc *OutboundCommHTTP) { oc.CertPool().RemoveFrom("", serverLeaf) },
+		func(oc *OutboundCommHTTP) { oc.CertPool().Replace() },
+	} {
+		oc, err := NewOutboundCommFromConfig(&OutboundCommConfig{
+			Timeout:      clientTimeout,
+			CACertsPaths: certPrefix + "ec-pubCert1.pem",
+		})
+		require.NoError(t, err)
+
+		_, err = oc.Send("data", url)
+		require.NoError(t, err)
+
+		// the kept alive connection isn't reused once the cert it was verified against is removed
+		remove(oc)
+		_, err = oc.Send("data", url)
+		require.Error(t, err)
+		require.NoError(t, oc.Close())
+	}
+}
+
+func TestOutboundCommHTTP_Revocation(t *testing.T) {
+	pemKey, err := ioutil.ReadFile(certPrefix + "ec-cakey.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(pemKey)
+	require.NotNil(t, block)
+	caKey, err := x509.ParseECPrivateKey(block.Bytes)
+	require.NoError(t, err)
+	pemCACert, err := ioutil.ReadFile(certPrefix + "ec-cacert.pem")
+	require.NoError(t, err)
+	caCert := tlsCertPool.DecodeCerts([]string{string(pemCACert)})[0]
+
+	serverCert, err := tls.LoadX509KeyPair(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem")
+	require.NoError(t, err)
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	require.NoError(t, err)
+
+	// the server staples an OCSP response revoking its certificate
+	serverCert.OCSPStaple, err = ocsp.CreateResponse(caCert, caCert, ocsp.Response{
+		Status:       ocsp.Revoked,
+		SerialNumber: serverLeaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, caKey)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server.StartTLS()
+	defer server.Close()
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	// the test CA cert has no key usage
+	crlIssuer := *caCert
+	crlIssuer.KeyUsage |= x509.KeyUsageCRLSign
+	writeCRL := func(nextUpdate time.Time, revoked ...x509.RevocationListEntry) string {
+		crl, e := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+			NextUpdate:                nextUpdate,
+			RevokedCertificateEntries: revoked,
+		}, &crlIssuer, caKey)
+		require.NoError(t, e)
+		crlFile, e := ioutil.TempFile("", "crl")
+		require.NoError(t, e)
+		_, e = crlFile.Write(crl)
+		require.NoError(t, e)
+		require.NoError(t, crlFile.Close())
+		return crlFile.Name()
+	}
+	crlPath := writeCRL(time.Now().Add(time.Hour), x509.RevocationListEntry{
+		SerialNumber:   serverLeaf.SerialNumber,
+		RevocationTime: time.Now(),
+	})
+	defer func() { require.NoError(t, os.Remove(crlPath)) }()
+	expiredCRLPath := writeCRL(time.Now().Add(-time.Minute))
+	defer func() { require.NoError(t, os.Remove(expiredCRLPath)) }()
+
+	send := func(cfg *OutboundCommConfig) error {
+		cfg.Timeout = clientTimeout
+		cfg.CACertsPaths = certPrefix + "ec-cacert.pem"
+		oc, e := NewOutboundCommFromConfig(cfg)
+		require.NoError(t, e)
+
+		_, e = oc.Send("data", url)
+		return e
+	}
+
+	require.NoError(t, send(&OutboundCommConfig{}))
+
+	err = send(&OutboundCommConfig{CRLPaths: crlPath + ","})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is revoked")
+	require.False(t, delivery.Retryable(err))
+
+	// servers are rejected once the CRL of their issuer is expired
+	err = send(&OutboundCommConfig{CRLPaths: expiredCRLPath})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expired on")
+	require.False(t, delivery.Retryable(err))
+
+	err = send(&OutboundCommConfig{VerifyOCSPStaple: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "revoked according to the stapled OCSP response")
+	require.False(t, delivery.Retryable(err))
+
+	_, err = NewOutboundCommFromConfig(&OutboundCommConfig{CRLPaths: "badpath"})
+	require.Error(t, err)
+}
+
+// waitForSend waits until sending to the url succeeds or fails as expected
+func waitForSend(t *testing.T, oc *OutboundCommHTTP, url string, success bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := oc.Send("data", url)
+		if (err == nil) == success {
+			return
+		}
+		require.True(t, time.Now().Before(deadline), "send result not as expected in time: %v", err)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDIDCommDispatchHandler(t *testing.T) {
+	const agentEndpoint = "/agent"
+
+	msgDispatcher := dispatcher.New()
+	require.NoError(t, msgDispatcher.Register("spec/connections/1.0/", func(payload []byte) error {
+		if strings.Contains(string(payload), "invalid") {
+			return errors.New("Invalid payload")
+		}
+		return nil
+	}))
+
+	handler := DIDCommDispatchHandler(mockHttpHandler{}, agentEndpoint, msgDispatcher)
+
+	tcs := []struct {
+		name           string
+		url            string
+		payload        string
+		expectedStatus int
+	}{
+		{
+			name:           "known message type",
+			url:            agentEndpoint,
+			payload:        `{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/request"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "handler error",
+			url:            agentEndpoint,
+			payload:        `{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/connections/1.0/invalid"}`,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "unknown message type",
+			url:            agentEndpoint,
+			payload:        `{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/unknown/1.0/message","@id":"12345"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "other path",
+			url:            "/badurl",
+			payload:        `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tc.url, strings.NewReader(tc.payload))
+			require.NoError(t, err)
+			req.Header.Set("Content-type", commContentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+
+	// problem report is sent back to the sender
+	req, err := http.NewRequest("POST", agentEndpoint,
+		strings.NewReader(`{"@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/unknown/1.0/message","@id":"12345"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-type", commContentType)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	report := &didexchange.ProblemReport{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), report))
+	require.Equal(t, dispatcher.ProblemReportType, report.Type)
+	require.Equal(t, "12345", report.Thread.ID)
+
+	require.Panics(t, func() { DIDCommDispatchHandler(mockHttpHandler{}, "", msgDispatcher) })
+	require.Panics(t, func() { DIDCommDispatchHandler(mockHttpHandler{}, agentEndpoint, nil) })
+}
+
+func TestDIDCommInboundHandler(t *testing.T) {
+	const agentEndpoint = "/agent"
+
+	var received []byte
+	handler := DIDCommInboundHandler(mockHttpHandler{}, agentEndpoint, func(payload []byte) error {
+		received = payload
+		return nil
+	})
+
+	req, err := http.NewRequest("POST", agentEndpoint, strings.NewReader(`{"protected":"header"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-type", commContentType)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.Equal(t, `{"protected":"header"}`, string(received))
+
+	require.Panics(t, func() { DIDCommInboundHandler(mockHttpHandler{}, "", func([]byte) error { return nil }) })
+	require.Panics(t, func() { DIDCommInboundHandler(mockHttpHandler{}, agentEndpoint, nil) })
+}
+
+func TestDIDCommMessageHandler(t *testing.T) {
+	const agentEndpoint = "/agent"
+
+	var received *transport.Message
+	var receivedBody []byte
+	handler := DIDCommMessageHandler(mockHttpHandler{}, agentEndpoint, func(msg *transport.Message) error {
+		received = msg
+		var err error
+		receivedBody, err = msg.Bytes()
+		if string(receivedBody) == "fail" {
+			return errors.New("inbound failure")
+		}
+		return err
+	}, commContentType, transport.MediaTypeJSON)
+
+	post := func(contentType, body string) int {
+		req, err := http.NewRequest(http.MethodPost, agentEndpoint, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-type", contentType)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusAccepted, post("application/json; charset=utf-8", `{"@id":"1"}`))
+	require.Equal(t, transport.MediaTypeJSON, received.MediaType)
+	require.Equal(t, `{"@id":"1"}`, string(receivedBody))
+
+	require.Equal(t, http.StatusAccepted, post(commContentType, "envelope"))
+	require.Equal(t, commContentType, received.MediaType)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, post("application/cbor", "data"))
+	require.Equal(t, http.StatusUnsupportedMediaType, post("", "data"))
+	require.Equal(t, http.StatusInternalServerError, post(commContentType, "fail"))
+
+	req, err := http.NewRequest(http.MethodGet, agentEndpoint, nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	// envelopes are accepted by default
+	handler = DIDCommMessageHandler(mockHttpHandler{}, agentEndpoint, func(msg *transport.Message) error {
+		return nil
+	})
+	require.Equal(t, http.StatusAccepted, post(commContentType, "envelope"))
+	require.Equal(t, http.StatusUnsupportedMediaType, post(transport.MediaTypeJSON, "{}"))
+
+	require.Panics(t, func() {
+		DIDCommMessageHandler(mockHttpHandler{}, "", func(*transport.Message) error { return nil })
+	})
+	require.Panics(t, func() { DIDCommMessageHandler(mockHttpHandler{}, agentEndpoint, nil) })
+}
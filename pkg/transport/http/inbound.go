@@ -0,0 +1,213 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// defaultInboundPath path of the agent endpoint if not set
+const defaultInboundPath = "/"
+
+// InboundHTTP is the HTTP inbound transport, it owns the listener receiving the envelopes
+// other agents post to the agent endpoint
+type InboundHTTP struct {
+	addr     string
+	path     string
+	certFile string
+	keyFile  string
+	external string
+	listener net.Listener
+	server   *http.Server
+	wg       sync.WaitGroup
+	lock     sync.Mutex
+}
+
+type inboundOpts struct {
+	path     string
+	certFile string
+	keyFile  string
+	external string
+}
+
+// InboundOpt configures the HTTP inbound transport
+type InboundOpt func(opts *inboundOpts)
+
+// WithInboundPath sets the path of the agent endpoint, "/" by default
+func WithInboundPath(path string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.path = path
+	}
+}
+
+// WithInboundTLS serves the agent endpoint over HTTPS with the given certificate and key files
+func WithInboundTLS(certFile, keyFile string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.certFile = certFile
+		opts.keyFile = keyFile
+	}
+}
+
+// WithInboundExternalEndpoint sets the URL other agents reach the agent endpoint at, e.g. behind a proxy,
+// it is returned by Endpoint instead of the listener address. It is mandatory to listen on a wildcard address.
+func WithInboundExternalEndpoint(endpoint string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.external = endpoint
+	}
+}
+
+// NewInbound creates a new HTTP inbound transport listening on the given address once started
+func NewInbound(addr string, opts ...InboundOpt) (*InboundHTTP, error) {
+	if addr == "" {
+		return nil, errors.New("address is mandatory")
+	}
+
+	inOpts := &inboundOpts{path: defaultInboundPath}
+	// Apply options
+	for _, opt := range opts {
+		opt(inOpts)
+	}
+	if (inOpts.certFile == "") != (inOpts.keyFile == "") {
+		return nil, errors.New("both TLS certificate and key files are mandatory")
+	}
+	if inOpts.external == "" && transport.IsWildcardAddr(addr) {
+		return nil, errors.Errorf("external endpoint is mandatory to listen on wildcard address %s", addr)
+	}
+
+	return &InboundHTTP{
+		addr:     addr,
+		path:     inOpts.path,
+		certFile: inOpts.certFile,
+		keyFile:  inOpts.keyFile,
+		external: inOpts.external,
+	}, nil
+}
+
+// Start listens on the address of the transport, the envelopes posted to the agent endpoint are unpacked
+// with the packer then passed to the handler whose response is sent back in the response body
+func (i *InboundHTTP) Start(packer pack.Packer, handler transport.InboundMessageHandler) error {
+	if packer == nil || handler == nil {
+		return errors.New("packer and message handler are mandatory")
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.server != nil {
+		return errors.New("inbound HTTP transport already started")
+	}
+
+	server, err := i.newServer(packer, handler)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", i.addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", i.addr)
+	}
+	i.listener = listener
+	i.server = server
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		if err := i.serve(server, listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP Transport - Inbound transport stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// newServer returns the server of the agent endpoint, the envelopes posted to it are unpacked
+// then passed to the handler
+func (i *InboundHTTP) newServer(packer pack.Packer, handler transport.InboundMessageHandler) (*http.Server, error) {
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != i.path {
+				http.NotFound(w, r)
+				return
+			}
+			processReturnRouteRequest(w, r, func(payload []byte) ([]byte, error) {
+				envelope, err := packer.Unpack(payload)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to unpack inbound message")
+				}
+				return handler(r.Header.Get("Content-Type"), envelope)
+			})
+		}),
+	}
+	if i.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(i.certFile, i.keyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load TLS certificate")
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	return server, nil
+}
+
+func (i *InboundHTTP) serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// certificates are already loaded in the TLS config
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
+}
+
+// Stop stops listening and waits for the requests in progress until the context is done
+func (i *InboundHTTP) Stop(ctx context.Context) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.server == nil {
+		return errors.New("inbound HTTP transport not started")
+	}
+
+	err := i.server.Shutdown(ctx)
+	i.wg.Wait()
+	i.server = nil
+	i.listener = nil
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to shutdown inbound HTTP transport")
+	}
+
+	return nil
+}
+
+// Endpoint returns the URL of the agent endpoint, it is empty until the transport is started
+func (i *InboundHTTP) Endpoint() string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.listener == nil {
+		return ""
+	}
+	if i.external != "" {
+		return i.external
+	}
+
+	scheme := "http://"
+	if i.certFile != "" {
+		scheme = "https://"
+	}
+
+	return scheme + i.listener.Addr().String() + i.path
+}
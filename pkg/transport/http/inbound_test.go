@@ -0,0 +1,153 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+func TestInboundHTTP(t *testing.T) {
+	inbound, err := NewInbound("localhost:0", WithInboundPath("/agent"))
+	require.NoError(t, err)
+	require.Empty(t, inbound.Endpoint())
+
+	var received *pack.Envelope
+	var receivedType string
+	require.NoError(t, inbound.Start(&mockPacker{}, func(mediaType string, envelope *pack.Envelope) ([]byte, error) {
+		received, receivedType = envelope, mediaType
+		switch string(envelope.Message) {
+		case "return-route":
+			return []byte("response"), nil
+		case "problem":
+			return nil, &dispatcher.ProblemReportError{}
+		}
+		return nil, nil
+	}))
+	require.Error(t, inbound.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+
+	endpoint := inbound.Endpoint()
+	require.True(t, strings.HasPrefix(endpoint, "http://127.0.0.1:"))
+	require.True(t, strings.HasSuffix(endpoint, "/agent"))
+
+	// unpacked envelope passed to the handler
+	require.Equal(t, http.StatusAccepted, post(t, endpoint, "message"))
+	require.Equal(t, "message", string(received.Message))
+	require.Equal(t, commContentType, receivedType)
+
+	// return route response
+	resp, err := http.Post(endpoint, commContentType, bytes.NewBufferString("return-route"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, commContentType, resp.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "response", string(body))
+
+	require.Equal(t, http.StatusBadRequest, post(t, endpoint, "problem"))
+
+	// unpack failure
+	require.Equal(t, http.StatusBadRequest, post(t, endpoint, ""))
+	require.Equal(t, http.StatusInternalServerError, post(t, endpoint, "invalid"))
+
+	require.Equal(t, http.StatusNotFound, post(t, strings.TrimSuffix(endpoint, "/agent")+"/other", "message"))
+
+	require.NoError(t, inbound.Stop(context.Background()))
+	require.Empty(t, inbound.Endpoint())
+	require.Error(t, inbound.Stop(context.Background()))
+
+	_, err = http.Post(endpoint, commContentType, bytes.NewBufferString("message"))
+	require.Error(t, err)
+}
+
+func TestInboundHTTP_TLS(t *testing.T) {
+	inbound, err := NewInbound("localhost:0", WithInboundTLS(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem"))
+	require.NoError(t, err)
+	require.NoError(t, inbound.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		return envelope.Message, nil
+	}))
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, inbound.Stop(ctx))
+	}()
+
+	endpoint := inbound.Endpoint()
+	require.True(t, strings.HasPrefix(endpoint, "https://127.0.0.1:"))
+
+	// the certificate is issued for localhost
+	respData, err := oCommHTTPClient.Send("message", strings.Replace(endpoint, "127.0.0.1", "localhost", 1))
+	require.NoError(t, err)
+	require.Equal(t, "message", respData)
+}
+
+func TestNewInbound(t *testing.T) {
+	_, err := NewInbound("")
+	require.Error(t, err)
+
+	_, err = NewInbound("localhost:0", WithInboundTLS(certPrefix+"ec-pubCert1.pem", ""))
+	require.Error(t, err)
+
+	handler := func(string, *pack.Envelope) ([]byte, error) { return nil, nil }
+
+	inbound, err := NewInbound("localhost:0", WithInboundTLS("badpath", "badpath"))
+	require.NoError(t, err)
+	require.Error(t, inbound.Start(&mockPacker{}, handler))
+
+	inbound, err = NewInbound("invalid-address")
+	require.NoError(t, err)
+	require.Error(t, inbound.Start(&mockPacker{}, handler))
+	require.Error(t, inbound.Start(nil, handler))
+	require.Error(t, inbound.Start(&mockPacker{}, nil))
+}
+
+func TestInbound_ExternalEndpoint(t *testing.T) {
+	_, err := NewInbound(":0")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "external endpoint is mandatory")
+
+	inbound, err := NewInbound(":0", WithInboundExternalEndpoint("https://agent.example.com/didcomm"))
+	require.NoError(t, err)
+	require.Empty(t, inbound.Endpoint())
+
+	require.NoError(t, inbound.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+	require.Equal(t, "https://agent.example.com/didcomm", inbound.Endpoint())
+	require.NoError(t, inbound.Stop(context.Background()))
+}
+
+// mockPacker unpacks envelopes to their content, "invalid" envelopes fail
+type mockPacker struct{}
+
+func (m *mockPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	return payload, nil
+}
+
+func (m *mockPacker) Unpack(envelope []byte) (*pack.Envelope, error) {
+	if string(envelope) == "invalid" {
+		return nil, errors.New("invalid envelope")
+	}
+	return &pack.Envelope{Message: envelope}, nil
+}
+
+// post posts the body to the URL and returns the response status code
+func post(t *testing.T, url, body string) int {
+	resp, err := http.Post(url, commContentType, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp.StatusCode
+}
@@ -0,0 +1,69 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned when the agent at URL answers with a non success HTTP status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	retryAfter time.Duration
+}
+
+func newStatusError(url string, resp *http.Response) *StatusError {
+	return &StatusError{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// Error returns the status received from the agent
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Warning - Received non success POST HTTP status from agent at [%s]: status : %v",
+		e.URL, e.Status)
+}
+
+// Retryable tells whether sending again may succeed: server errors, request timeouts and
+// too many requests are transient while other client errors are permanent
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// RetryAfter returns the delay before sending again requested by the Retry-After header, zero if not set
+func (e *StatusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// parseRetryAfter parses the Retry-After header value, either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
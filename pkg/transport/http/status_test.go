@@ -0,0 +1,61 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	outbound, err := NewOutboundCommFromClient(server.Client())
+	require.NoError(t, err)
+
+	_, err = outbound.Send("data", server.URL)
+	require.Error(t, err)
+	statusErr, ok := errors.Cause(err).(*StatusError)
+	require.True(t, ok)
+	require.Equal(t, server.URL, statusErr.URL)
+	require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	require.True(t, statusErr.Retryable())
+	require.Equal(t, 2*time.Minute, statusErr.RetryAfter())
+	require.Contains(t, statusErr.Error(), "503")
+
+	for code, retryable := range map[int]bool{
+		http.StatusInternalServerError:  true,
+		http.StatusBadGateway:           true,
+		http.StatusRequestTimeout:       true,
+		http.StatusTooManyRequests:      true,
+		http.StatusBadRequest:           false,
+		http.StatusNotFound:             false,
+		http.StatusUnsupportedMediaType: false,
+	} {
+		require.Equal(t, retryable, (&StatusError{StatusCode: code}).Retryable(), code)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	require.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("invalid", now))
+	require.Equal(t, 90*time.Second, parseRetryAfter("Mon, 01 Jul 2019 12:01:30 GMT", now))
+	require.Equal(t, time.Duration(0), parseRetryAfter("Mon, 01 Jul 2019 11:00:00 GMT", now))
+}
@@ -18,9 +18,11 @@ import (
 // cert pool implementation.
 // It optionally allows loading the system trust store.
 type certPool struct {
-	certPool       *x509.CertPool
-	certs          []*x509.Certificate
-	certsByName    map[string][]int
+	certPool    *x509.CertPool
+	certs       []*x509.Certificate
+	certsByName map[string][]int
+	// holders are the sources holding each cert, "" for the certs added with Add
+	holders        map[*x509.Certificate]map[string]bool
 	lock           sync.RWMutex
 	dirty          int32
 	systemCertPool bool
@@ -36,6 +38,7 @@ func NewCertPool(useSystemCertPool bool) (CertPool, error) {
 
 	newCertPool := &certPool{
 		certsByName:    make(map[string][]int),
+		holders:        make(map[*x509.Certificate]map[string]bool),
 		certPool:       c,
 		systemCertPool: useSystemCertPool,
 	}
@@ -64,87 +67,164 @@ func (c *certPool) Get() (*x509.CertPool, error) {
 
 //Add adds given certs to cert pool queue, those certs will be added to certpool during subsequent Get() call
 func (c *certPool) Add(certs ...*x509.Certificate) {
+	c.AddFrom("", certs...)
+}
+
+//AddFrom adds given certs on behalf of the source, such as a watched directory. They stay in the pool
+//until every source holding them released them, or they are removed
+func (c *certPool) AddFrom(source string, certs ...*x509.Certificate) {
 	if len(certs) == 0 {
 		return
 	}
 
-	//filter certs to be added, check if they already exist or duplicate
-	certsToBeAdded := c.filterCerts(certs...)
-
-	if len(certsToBeAdded) > 0 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-		c.lock.Lock()
-		defer c.lock.Unlock()
+	added := false
+	for _, cert := range certs {
+		if cert == nil {
+			continue
+		}
 
-		for _, newCert := range certsToBeAdded {
+		stored := c.find(cert)
+		if stored == nil {
 			// Store cert name index
-			name := string(newCert.RawSubject)
+			name := string(cert.RawSubject)
 			c.certsByName[name] = append(c.certsByName[name], len(c.certs))
 			// Store cert
-			c.certs = append(c.certs, newCert)
+			c.certs = append(c.certs, cert)
+			c.holders[cert] = make(map[string]bool)
+			stored = cert
+			added = true
 		}
+		c.holders[stored][source] = true
+	}
 
+	if added {
 		atomic.CompareAndSwapInt32(&c.dirty, 0, 1)
 	}
 }
 
-func (c *certPool) swapCertPool() error {
+//Remove removes given certs from cert pool whatever the sources holding them, certpool is rebuilt without
+//them during subsequent Get() call
+func (c *certPool) Remove(certs ...*x509.Certificate) {
+	if len(certs) == 0 {
+		return
+	}
 
-	newCertPool, err := loadSystemCertPool(c.systemCertPool)
-	if err != nil {
-		return err
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.keep(func(cert *x509.Certificate) bool {
+		return !containsCert(certs, cert)
+	})
+}
+
+//RemoveFrom releases given certs held by the source, they are removed from cert pool unless another source
+//holds them. Certpool is rebuilt without them during subsequent Get() call
+func (c *certPool) RemoveFrom(source string, certs ...*x509.Certificate) {
+	if len(certs) == 0 {
+		return
 	}
 
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	//add all new certs in queue to new cert pool
+	c.keep(func(cert *x509.Certificate) bool {
+		if containsCert(certs, cert) {
+			delete(c.holders[cert], source)
+		}
+		return len(c.holders[cert]) > 0
+	})
+}
+
+//Replace replaces all certs added to cert pool by given certs, whatever their sources. Certpool is rebuilt
+//with them during subsequent Get() call
+func (c *certPool) Replace(certs ...*x509.Certificate) {
+	kept := make([]*x509.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		if cert != nil && !containsCert(kept, cert) {
+			kept = append(kept, cert)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.holders = make(map[*x509.Certificate]map[string]bool)
+	for _, cert := range kept {
+		c.holders[cert] = map[string]bool{"": true}
+	}
+	c.setCerts(kept)
+}
+
+// keep keeps the certs matching the filter, the pool is unchanged if they all match. The lock must be held.
+func (c *certPool) keep(filter func(cert *x509.Certificate) bool) {
+	kept := make([]*x509.Certificate, 0, len(c.certs))
 	for _, cert := range c.certs {
-		newCertPool.AddCert(cert)
+		if filter(cert) {
+			kept = append(kept, cert)
+		} else {
+			delete(c.holders, cert)
+		}
+	}
+	if len(kept) == len(c.certs) {
+		return
 	}
 
-	//swap old certpool with new one
-	c.certPool = newCertPool
+	c.setCerts(kept)
+}
 
+// find returns the stored cert equal to the given one, nil if there is none. The lock must be held.
+func (c *certPool) find(cert *x509.Certificate) *x509.Certificate {
+	for _, i := range c.certsByName[string(cert.RawSubject)] {
+		if c.certs[i].Equal(cert) {
+			return c.certs[i]
+		}
+	}
 	return nil
 }
 
-//filterCerts remove certs from list if they already exist in pool or duplicate
-func (c *certPool) filterCerts(certs ...*x509.Certificate) []*x509.Certificate {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// setCerts sets the certs and rebuilds the name index as their positions changed, the lock must be held
+func (c *certPool) setCerts(certs []*x509.Certificate) {
+	c.certs = certs
+	c.certsByName = make(map[string][]int)
+	for i, cert := range c.certs {
+		name := string(cert.RawSubject)
+		c.certsByName[name] = append(c.certsByName[name], i)
+	}
 
-	filtered := []*x509.Certificate{}
+	atomic.CompareAndSwapInt32(&c.dirty, 0, 1)
+}
 
-CertLoop:
-	for _, cert := range certs {
-		if cert == nil {
-			continue
-		}
-		possibilities := c.certsByName[string(cert.RawSubject)]
-		for _, p := range possibilities {
-			if c.certs[p].Equal(cert) {
-				continue CertLoop
-			}
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c != nil && c.Equal(cert) {
+			return true
 		}
-		filtered = append(filtered, cert)
 	}
-
-	//remove duplicate from list of certs being passed
-	return removeDuplicates(filtered...)
+	return false
 }
 
-func removeDuplicates(certs ...*x509.Certificate) []*x509.Certificate {
-	encountered := map[*x509.Certificate]bool{}
-	result := []*x509.Certificate{}
+func (c *certPool) swapCertPool() error {
 
-	for v := range certs {
-		if !encountered[certs[v]] {
-			encountered[certs[v]] = true
-			result = append(result, certs[v])
-		}
+	newCertPool, err := loadSystemCertPool(c.systemCertPool)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	//add all new certs in queue to new cert pool
+	for _, cert := range c.certs {
+		newCertPool.AddCert(cert)
 	}
-	return result
+
+	//swap old certpool with new one
+	c.certPool = newCertPool
+
+	return nil
 }
 
 func loadSystemCertPool(useSystemCertPool bool) (*x509.CertPool, error) {
@@ -168,6 +248,18 @@ type CertPool interface {
 	//Add allows adding certificates to CertPool
 	//Call Get() after Add() to get the updated certpool
 	Add(certs ...*x509.Certificate)
+	//AddFrom allows adding certificates on behalf of a source, such as a watched directory
+	//Call Get() after AddFrom() to get the updated certpool
+	AddFrom(source string, certs ...*x509.Certificate)
+	//Remove allows removing certificates from CertPool whatever their sources, such as compromised ones
+	//Call Get() after Remove() to get the updated certpool
+	Remove(certs ...*x509.Certificate)
+	//RemoveFrom releases certificates added by a source, they are kept if another source added them
+	//Call Get() after RemoveFrom() to get the updated certpool
+	RemoveFrom(source string, certs ...*x509.Certificate)
+	//Replace replaces all the certificates added to CertPool, the system ones are kept
+	//Call Get() after Replace() to get the updated certpool
+	Replace(certs ...*x509.Certificate)
 }
 
 // DecodeCerts will decode a list of pemCertsList (string) into a list of x509 certificates
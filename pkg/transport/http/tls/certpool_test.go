@@ -71,6 +71,127 @@ func TestCertPool_AddAndDecodeAndGet(t *testing.T) {
 	require.Empty(t, certList)
 }
 
+func TestCertPool_Remove(t *testing.T) {
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+	cert3 := readCert(t, certPrefix+"ec-pubCert3.pem")
+	caCert := readCert(t, certPrefix+"ec-cacert.pem")
+	cp.Add(cert1, cert2, cert3, caCert)
+
+	p, err := cp.Get()
+	require.NoError(t, err)
+	require.Len(t, p.Subjects(), 4)
+
+	c := cp.(*certPool)
+	cp.Remove()
+	cp.Remove(cert2, nil)
+	p, err = cp.Get()
+	require.NoError(t, err)
+	require.Len(t, p.Subjects(), 3)
+
+	// the name index points to the remaining certs
+	require.Len(t, c.certsByName, 2)
+	for _, positions := range c.certsByName {
+		for _, i := range positions {
+			require.True(t, i < len(c.certs))
+		}
+	}
+	require.Len(t, c.certsByName[string(cert1.RawSubject)], 2)
+
+	// removed certs can be added back, certs still in the pool aren't added twice
+	cp.Add(cert1, cert2)
+	p, err = cp.Get()
+	require.NoError(t, err)
+	require.Len(t, p.Subjects(), 4)
+
+	// removing unknown certs doesn't change the pool
+	cp.Remove(cert2)
+	_, err = cp.Get()
+	require.NoError(t, err)
+	cp.Remove(cert2)
+	require.Equal(t, int32(0), c.dirty)
+}
+
+func TestCertPool_Sources(t *testing.T) {
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+	caCert := readCert(t, certPrefix+"ec-cacert.pem")
+	cp.Add(caCert)
+	cp.AddFrom("dir", caCert, cert1, nil)
+	cp.AddFrom("other", readCert(t, certPrefix+"ec-pubCert1.pem"))
+	cp.AddFrom("dir", cert2)
+	require.Equal(t, 3, poolSize(t, cp))
+
+	// certs are kept while another source holds them
+	cp.RemoveFrom("dir", caCert, cert1, cert2)
+	require.Equal(t, 2, poolSize(t, cp))
+	require.True(t, hasCert(cp, caCert))
+	require.True(t, hasCert(cp, cert1))
+
+	cp.RemoveFrom("other", cert1)
+	cp.RemoveFrom("dir")
+	require.Equal(t, 1, poolSize(t, cp))
+
+	// removed certs are removed whatever their sources
+	cp.AddFrom("dir", caCert)
+	cp.Remove(caCert)
+	require.Equal(t, 0, poolSize(t, cp))
+
+	cp.AddFrom("dir", cert1)
+	cp.Replace(cert2)
+	cp.RemoveFrom("dir", cert1, cert2)
+	require.Equal(t, 1, poolSize(t, cp))
+	require.True(t, hasCert(cp, cert2))
+}
+
+func TestCertPool_Replace(t *testing.T) {
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+	caCert := readCert(t, certPrefix+"ec-cacert.pem")
+	cp.Add(cert1, cert2)
+	_, err = cp.Get()
+	require.NoError(t, err)
+
+	// duplicates and nil certs are dropped
+	cp.Replace(caCert, cert2, nil, caCert, readCert(t, certPrefix+"ec-cacert.pem"))
+	p, err := cp.Get()
+	require.NoError(t, err)
+	require.Len(t, p.Subjects(), 2)
+
+	c := cp.(*certPool)
+	require.Len(t, c.certsByName[string(caCert.RawSubject)], 1)
+	require.Len(t, c.certsByName[string(cert1.RawSubject)], 1)
+	require.True(t, c.certs[c.certsByName[string(cert1.RawSubject)][0]].Equal(cert2))
+
+	cp.Replace()
+	p, err = cp.Get()
+	require.NoError(t, err)
+	require.Empty(t, p.Subjects())
+	require.Empty(t, c.certsByName)
+
+	// the system certs are kept
+	systemPool, err := NewCertPool(true)
+	require.NoError(t, err)
+	p, err = systemPool.Get()
+	require.NoError(t, err)
+	systemSize := len(p.Subjects())
+
+	systemPool.Add(cert1)
+	systemPool.Replace(cert2, caCert)
+	p, err = systemPool.Get()
+	require.NoError(t, err)
+	require.Len(t, p.Subjects(), systemSize+2)
+}
+
 const badCert = `
 -----BEGIN BADHEADER-----
 MIICUjCCAbMCCQDoex4ibR3sAzAKBggqhkjOPQQDAjBtMQswCQYDVQQGEwJDQTEQ
@@ -0,0 +1,23 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+// CertificateError is returned when the peer certificate is rejected by the pinned public keys or the revocation
+// checks, it is permanent: connecting again to the same peer fails the same way
+type CertificateError struct {
+	Err error
+}
+
+// Error returns the reason the peer certificate is rejected
+func (e *CertificateError) Error() string {
+	return e.Err.Error()
+}
+
+// Retryable returns false, sending again to the peer fails as long as its certificate is rejected
+func (e *CertificateError) Retryable() bool {
+	return false
+}
@@ -0,0 +1,43 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// SPKIHash returns the base64 encoded SHA-256 hash of the subject public key info of the certificate,
+// such as the pins of HTTP public key pinning
+func SPKIHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// VerifyPinnedSPKI returns a tls.Config VerifyPeerCertificate function accepting the peer only if one of the
+// certificates of its verified chains has a subject public key info matching one of the given SPKI hashes
+func VerifyPinnedSPKI(spkiHashes ...string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	pins := make(map[string]bool, len(spkiHashes))
+	for _, hash := range spkiHashes {
+		pins[hash] = true
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if pins[SPKIHash(cert)] {
+					return nil
+				}
+			}
+		}
+
+		return &CertificateError{Err: errors.New("no pinned public key found in the peer certificate chains")}
+	}
+}
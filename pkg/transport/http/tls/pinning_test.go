@@ -0,0 +1,47 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPinnedSPKI(t *testing.T) {
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+	caCert := readCert(t, certPrefix+"ec-cacert.pem")
+
+	hash := sha256.Sum256(cert1.RawSubjectPublicKeyInfo)
+	require.Equal(t, base64.StdEncoding.EncodeToString(hash[:]), SPKIHash(cert1))
+	require.NotEqual(t, SPKIHash(cert1), SPKIHash(cert2))
+
+	verify := VerifyPinnedSPKI(SPKIHash(cert1), SPKIHash(caCert))
+
+	// leaf or issuer pinned
+	require.NoError(t, verify(nil, [][]*x509.Certificate{{cert1, caCert}}))
+	require.NoError(t, verify(nil, [][]*x509.Certificate{{cert2, caCert}}))
+	require.NoError(t, verify(nil, [][]*x509.Certificate{{cert2}, {cert1}}))
+
+	require.Error(t, verify(nil, [][]*x509.Certificate{{cert2}}))
+	require.Error(t, verify(nil, nil))
+	require.Error(t, VerifyPinnedSPKI()(nil, [][]*x509.Certificate{{cert1}}))
+}
+
+func readCert(t *testing.T, path string) *x509.Certificate {
+	pemCert, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	certs := DecodeCerts([]string{string(pemCert)})
+	require.Len(t, certs, 1)
+	return certs[0]
+}
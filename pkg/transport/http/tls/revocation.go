@@ -0,0 +1,187 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationChecker checks certificates against locally supplied certificate revocation lists (CRL)
+type RevocationChecker struct {
+	crlsByIssuer map[string][]*x509.RevocationList
+	lock         sync.RWMutex
+}
+
+// NewRevocationChecker creates a new revocation checker with the CRL files (PEM or DER) at the given paths
+func NewRevocationChecker(crlPaths ...string) (*RevocationChecker, error) {
+	r := &RevocationChecker{crlsByIssuer: make(map[string][]*x509.RevocationList)}
+
+	for _, path := range crlPaths {
+		crl, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed Reading CRL")
+		}
+		if e := r.AddCRL(crl); e != nil {
+			return nil, errors.Wrapf(e, "invalid CRL %s", path)
+		}
+	}
+
+	return r, nil
+}
+
+// AddCRL adds the PEM or DER encoded CRL to the checker, its signature is verified against the issuer
+// of the checked certificates
+func (r *RevocationChecker) AddCRL(crl []byte) error {
+	if block, _ := pem.Decode(crl); block != nil && block.Type == "X509 CRL" {
+		crl = block.Bytes
+	}
+
+	revocationList, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse CRL")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	issuer := revocationList.Issuer.String()
+	r.crlsByIssuer[issuer] = append(r.crlsByIssuer[issuer], revocationList)
+
+	return nil
+}
+
+// IsRevoked tells whether the cert is revoked by one of the CRLs of its issuer,
+// CRLs which aren't signed by the issuer are ignored. An error is returned if the CRLs of the issuer
+// are all past their next update, the revocation status of the cert is unknown then.
+func (r *RevocationChecker) IsRevoked(cert, issuer *x509.Certificate) (bool, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var expired *x509.RevocationList
+	current := false
+	for _, crl := range r.crlsByIssuer[cert.Issuer.String()] {
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+
+		// revocations are final, those of an expired CRL still hold
+		for _, revoked := range crl.RevokedCertificateEntries {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+
+		if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+			expired = crl
+			continue
+		}
+		current = true
+	}
+
+	if expired != nil && !current {
+		return false, errors.Errorf("CRL of %s expired on %s", cert.Issuer, expired.NextUpdate.Format(time.RFC3339))
+	}
+
+	return false, nil
+}
+
+// VerifyPeerCertificate is a tls.Config VerifyPeerCertificate function accepting the peer only if one of its
+// verified chains has no revoked certificate and no certificate of unknown revocation status
+func (r *RevocationChecker) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 {
+		return &CertificateError{Err: errors.New("no verified certificate chain to check for revocation")}
+	}
+
+	var firstErr error
+	for _, chain := range verifiedChains {
+		err := r.checkChain(chain)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return &CertificateError{Err: firstErr}
+}
+
+// checkChain returns an error for the first cert of the chain which is revoked or whose CRL expired
+func (r *RevocationChecker) checkChain(chain []*x509.Certificate) error {
+	for i, cert := range chain {
+		// the last cert of the chain is self signed
+		issuer := cert
+		if i+1 < len(chain) {
+			issuer = chain[i+1]
+		}
+
+		revoked, err := r.IsRevoked(cert, issuer)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return errors.Errorf("certificate %s is revoked", cert.SerialNumber)
+		}
+	}
+
+	return nil
+}
+
+// VerifyOCSPStaple checks the OCSP response stapled by the peer during the TLS handshake, the peer is rejected if
+// the response is invalid, revokes its certificate, is past its next update or was produced more than maxAge ago
+// (no limit if zero). Nothing is checked if the peer didn't staple a response.
+func VerifyOCSPStaple(staple []byte, verifiedChains [][]*x509.Certificate, maxAge time.Duration) error {
+	if len(staple) == 0 {
+		return nil
+	}
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		return &CertificateError{Err: errors.New("no verified certificate chain to check the OCSP response against")}
+	}
+
+	leaf, issuer := verifiedChains[0][0], verifiedChains[0][0]
+	if len(verifiedChains[0]) > 1 {
+		issuer = verifiedChains[0][1]
+	}
+
+	resp, err := ocsp.ParseResponseForCert(staple, leaf, issuer)
+	if err != nil {
+		return &CertificateError{Err: errors.Wrap(err, "invalid stapled OCSP response")}
+	}
+	if resp.Status == ocsp.Revoked {
+		return &CertificateError{
+			Err: errors.Errorf("certificate %s is revoked according to the stapled OCSP response", leaf.SerialNumber),
+		}
+	}
+
+	return checkOCSPFreshness(resp, maxAge)
+}
+
+// checkOCSPFreshness rejects OCSP responses past their next update or older than maxAge if set
+func checkOCSPFreshness(resp *ocsp.Response, maxAge time.Duration) error {
+	now := time.Now()
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+		return &CertificateError{
+			Err: errors.Errorf("stapled OCSP response expired on %s", resp.NextUpdate.Format(time.RFC3339)),
+		}
+	}
+	if maxAge > 0 && now.Sub(resp.ThisUpdate) > maxAge {
+		return &CertificateError{
+			Err: errors.Errorf("stapled OCSP response of %s is older than %s",
+				resp.ThisUpdate.Format(time.RFC3339), maxAge),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRevocationChecker(t *testing.T) {
+	caCert, caKey := readCA(t)
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+
+	dir, err := ioutil.TempDir("", "crls")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	// DER and PEM encoded CRLs
+	crl := createCRL(t, caCert, caKey, cert1)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crl"), crl, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.pem"),
+		pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600))
+
+	r, err := NewRevocationChecker(filepath.Join(dir, "ca.crl"), filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+
+	requireRevoked(t, r, cert1, caCert, true)
+	requireRevoked(t, r, cert2, caCert, false)
+	requireRevoked(t, r, caCert, caCert, false)
+
+	require.NoError(t, r.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert2, caCert}}))
+	require.NoError(t, r.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert1, caCert}, {cert2, caCert}}))
+	err = r.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert1, caCert}})
+	require.EqualError(t, err, "certificate "+cert1.SerialNumber.String()+" is revoked")
+	require.Error(t, r.VerifyPeerCertificate(nil, nil))
+
+	// CRLs which aren't signed by the issuer are ignored
+	r, err = NewRevocationChecker()
+	require.NoError(t, err)
+	require.NoError(t, r.AddCRL(crl))
+	requireRevoked(t, r, cert1, cert2, false)
+
+	require.Error(t, r.AddCRL([]byte("invalid")))
+	_, err = NewRevocationChecker("badpath")
+	require.Error(t, err)
+	_, err = NewRevocationChecker(certPrefix + "ec-pubCert1.pem")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid CRL")
+}
+
+func TestRevocationChecker_ExpiredCRL(t *testing.T) {
+	caCert, caKey := readCA(t)
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	cert2 := readCert(t, certPrefix+"ec-pubCert2.pem")
+	expired := createCRLUpdatedAt(t, caCert, caKey, time.Now().Add(-time.Minute), cert1)
+
+	r, err := NewRevocationChecker()
+	require.NoError(t, err)
+	require.NoError(t, r.AddCRL(expired))
+
+	// the revocation status is unknown once the CRL is expired, its revocations still hold
+	_, err = r.IsRevoked(cert2, caCert)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expired on")
+	requireRevoked(t, r, cert1, caCert, true)
+
+	err = r.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert2, caCert}})
+	require.Error(t, err)
+	require.IsType(t, &CertificateError{}, err)
+
+	// a current CRL of the issuer takes over
+	require.NoError(t, r.AddCRL(createCRL(t, caCert, caKey)))
+	requireRevoked(t, r, cert2, caCert, false)
+	require.NoError(t, r.VerifyPeerCertificate(nil, [][]*x509.Certificate{{cert2, caCert}}))
+}
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	caCert, caKey := readCA(t)
+	cert1 := readCert(t, certPrefix+"ec-pubCert1.pem")
+	chains := [][]*x509.Certificate{{cert1, caCert}}
+
+	require.NoError(t, VerifyOCSPStaple(nil, chains, 0))
+	require.NoError(t, VerifyOCSPStaple(createOCSPResponse(t, caCert, caKey, cert1, ocsp.Good), chains, 0))
+	require.NoError(t, VerifyOCSPStaple(createOCSPResponse(t, caCert, caKey, cert1, ocsp.Good), chains, time.Hour))
+
+	err := VerifyOCSPStaple(createOCSPResponse(t, caCert, caKey, cert1, ocsp.Revoked), chains, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is revoked according to the stapled OCSP response")
+
+	err = VerifyOCSPStaple([]byte("invalid"), chains, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid stapled OCSP response")
+
+	// the response must be signed by the issuer
+	err = VerifyOCSPStaple(createOCSPResponse(t, caCert, caKey, cert1, ocsp.Good), [][]*x509.Certificate{{cert1}}, 0)
+	require.Error(t, err)
+
+	require.Error(t, VerifyOCSPStaple([]byte("staple"), nil, 0))
+
+	// responses past their next update or older than the max age are rejected
+	staple, err := ocsp.CreateResponse(caCert, caCert, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: cert1.SerialNumber,
+		ThisUpdate:   time.Now().Add(-2 * time.Hour),
+		NextUpdate:   time.Now().Add(-time.Hour),
+	}, caKey)
+	require.NoError(t, err)
+	err = VerifyOCSPStaple(staple, chains, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "stapled OCSP response expired on")
+
+	err = VerifyOCSPStaple(createOCSPResponse(t, caCert, caKey, cert1, ocsp.Good), chains, time.Second)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is older than 1s")
+	require.IsType(t, &CertificateError{}, err)
+}
+
+func readCA(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	pemKey, err := ioutil.ReadFile(certPrefix + "ec-cakey.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(pemKey)
+	require.NotNil(t, block)
+	caKey, err := x509.ParseECPrivateKey(block.Bytes)
+	require.NoError(t, err)
+
+	return readCert(t, certPrefix+"ec-cacert.pem"), caKey
+}
+
+func createCRL(t *testing.T, caCert *x509.Certificate, caKey crypto.Signer, revoked ...*x509.Certificate) []byte {
+	return createCRLUpdatedAt(t, caCert, caKey, time.Now().Add(time.Hour), revoked...)
+}
+
+func createCRLUpdatedAt(t *testing.T, caCert *x509.Certificate, caKey crypto.Signer, nextUpdate time.Time,
+	revoked ...*x509.Certificate) []byte {
+	var revokedCerts []x509.RevocationListEntry
+	for _, cert := range revoked {
+		revokedCerts = append(revokedCerts, x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: revokedCerts,
+	}, crlIssuer(caCert), caKey)
+	require.NoError(t, err)
+	return crl
+}
+
+// crlIssuer returns the CA cert allowed to sign CRLs, the test CA cert has no key usage
+func crlIssuer(caCert *x509.Certificate) *x509.Certificate {
+	issuer := *caCert
+	issuer.KeyUsage |= x509.KeyUsageCRLSign
+	return &issuer
+}
+
+func requireRevoked(t *testing.T, r *RevocationChecker, cert, issuer *x509.Certificate, revoked bool) {
+	isRevoked, err := r.IsRevoked(cert, issuer)
+	require.NoError(t, err)
+	require.Equal(t, revoked, isRevoked)
+}
+
+func createOCSPResponse(t *testing.T, caCert *x509.Certificate, caKey crypto.Signer, cert *x509.Certificate,
+	status int) []byte {
+	resp, err := ocsp.CreateResponse(caCert, caCert, ocsp.Response{
+		Status:       status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, caKey)
+	require.NoError(t, err)
+	return resp
+}
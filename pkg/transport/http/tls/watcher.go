@@ -0,0 +1,173 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultWatchInterval is the interval directories are polled at if none is given
+const DefaultWatchInterval = 10 * time.Second
+
+// watchedFile is a PEM file of the watched directory and the certs it held when it was last read
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+	certs   []*x509.Certificate
+}
+
+// unchanged tells whether the file wasn't modified since it was read, judging by its modification time and size
+func (f *watchedFile) unchanged(entry os.FileInfo) bool {
+	return f != nil && f.modTime.Equal(entry.ModTime()) && f.size == entry.Size()
+}
+
+// DirWatcher polls a directory of PEM files (*.pem, *.crt), the certs of new or updated files are added
+// to the cert pool and the certs of removed files are removed from it unless they were also added otherwise,
+// such as from the CA certs files of the HTTP transport
+type DirWatcher struct {
+	pool     CertPool
+	dir      string
+	interval time.Duration
+	files    map[string]*watchedFile
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatchDir loads the certs of the PEM files in the directory into the cert pool then polls the directory
+// at the given interval, DefaultWatchInterval if not positive, until the watcher is stopped
+func WatchDir(pool CertPool, dir string, interval time.Duration) (*DirWatcher, error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w := &DirWatcher{
+		pool:     pool,
+		dir:      dir,
+		interval: interval,
+		files:    make(map[string]*watchedFile),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := w.scan(); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Stop stops polling the directory, the certs loaded so far are kept in the cert pool
+func (w *DirWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *DirWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.scan(); err != nil {
+				log.Printf("Failed to reload certificates from %s: %s", w.dir, err)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// scan reads the new or updated PEM files of the directory and updates the cert pool
+func (w *DirWatcher) scan() error {
+	entries, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read certificates directory %s", w.dir)
+	}
+
+	files := make(map[string]*watchedFile)
+	var added []*x509.Certificate
+	changed := false
+	for _, entry := range entries {
+		if !isPEMFile(entry) {
+			continue
+		}
+
+		path := filepath.Join(w.dir, entry.Name())
+		if previous := w.files[path]; previous.unchanged(entry) {
+			files[path] = previous
+			continue
+		}
+
+		pemCerts, e := ioutil.ReadFile(filepath.Clean(path))
+		if e != nil {
+			return errors.Wrapf(e, "failed to read certificate file %s", path)
+		}
+		files[path] = &watchedFile{
+			modTime: entry.ModTime(),
+			size:    entry.Size(),
+			certs:   DecodeCerts([]string{string(pemCerts)}),
+		}
+		added = append(added, files[path].certs...)
+		changed = true
+	}
+	if !changed && len(files) == len(w.files) {
+		return nil
+	}
+
+	// certs are added on behalf of the directory so that the ones also added by others are kept
+	w.pool.RemoveFrom(w.dir, removedCerts(w.files, files)...)
+	w.pool.AddFrom(w.dir, added...)
+	w.files = files
+
+	return nil
+}
+
+// removedCerts returns the certs which were in the previous files but none of the current ones
+func removedCerts(previous, current map[string]*watchedFile) []*x509.Certificate {
+	var kept []*x509.Certificate
+	for _, file := range current {
+		kept = append(kept, file.certs...)
+	}
+
+	var removed []*x509.Certificate
+	for path, file := range previous {
+		if current[path] == file {
+			continue
+		}
+		for _, cert := range file.certs {
+			if !containsCert(kept, cert) {
+				removed = append(removed, cert)
+			}
+		}
+	}
+	return removed
+}
+
+func isPEMFile(entry os.FileInfo) bool {
+	if entry.IsDir() {
+		return false
+	}
+
+	ext := strings.ToLower(filepath.Ext(entry.Name()))
+	return ext == ".pem" || ext == ".crt"
+}
@@ -0,0 +1,136 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tls
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const watchInterval = 10 * time.Millisecond
+
+func TestWatchDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	copyFile(t, certPrefix+"ec-pubCert1.pem", filepath.Join(dir, "agent1.pem"))
+	copyFile(t, certPrefix+"ec-key1.pem", filepath.Join(dir, "agent1.key"))
+
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+
+	w, err := WatchDir(cp, dir, watchInterval)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	// the directory is loaded before watching it
+	require.Equal(t, 1, poolSize(t, cp))
+
+	// new files are added
+	copyFile(t, certPrefix+"ec-pubCert2.pem", filepath.Join(dir, "agent2.crt"))
+	copyFile(t, certPrefix+"ec-pubCert2.pem", filepath.Join(dir, "agent2-copy.pem"))
+	waitForPoolSize(t, cp, 2)
+
+	// certs are kept as long as a file holds them
+	require.NoError(t, os.Remove(filepath.Join(dir, "agent2.crt")))
+	time.Sleep(5 * watchInterval)
+	require.Equal(t, 2, poolSize(t, cp))
+
+	// removed files are removed
+	require.NoError(t, os.Remove(filepath.Join(dir, "agent2-copy.pem")))
+	waitForPoolSize(t, cp, 1)
+
+	// updated files are reloaded
+	copyFile(t, certPrefix+"ec-pubCert3.pem", filepath.Join(dir, "agent1.pem"))
+	cert3 := readCert(t, certPrefix+"ec-pubCert3.pem")
+	waitFor(t, func() bool {
+		return poolSize(t, cp) == 1 && hasCert(cp, cert3)
+	})
+
+	w.Stop()
+	copyFile(t, certPrefix+"ec-pubCert2.pem", filepath.Join(dir, "agent2.pem"))
+	time.Sleep(5 * watchInterval)
+	require.Equal(t, 1, poolSize(t, cp))
+}
+
+func TestWatchDir_KeepsOtherCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	copyFile(t, certPrefix+"ec-cacert.pem", filepath.Join(dir, "ca.pem"))
+
+	// the CA is also loaded from a file which isn't watched
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+	caCert := readCert(t, certPrefix+"ec-cacert.pem")
+	cp.Add(caCert)
+
+	w, err := WatchDir(cp, dir, watchInterval)
+	require.NoError(t, err)
+	defer w.Stop()
+
+	copyFile(t, certPrefix+"ec-pubCert1.pem", filepath.Join(dir, "agent1.pem"))
+	waitForPoolSize(t, cp, 2)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "ca.pem")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "agent1.pem")))
+	waitForPoolSize(t, cp, 1)
+	require.True(t, hasCert(cp, caCert))
+}
+
+func TestWatchDir_Errors(t *testing.T) {
+	cp, err := NewCertPool(false)
+	require.NoError(t, err)
+
+	_, err = WatchDir(cp, "badpath", watchInterval)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read certificates directory")
+}
+
+func copyFile(t *testing.T, from, to string) {
+	data, err := ioutil.ReadFile(from)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(to, data, 0600))
+}
+
+func hasCert(cp CertPool, cert *x509.Certificate) bool {
+	c := cp.(*certPool)
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return containsCert(c.certs, cert)
+}
+
+func poolSize(t *testing.T, cp CertPool) int {
+	p, err := cp.Get()
+	require.NoError(t, err)
+	return len(p.Subjects())
+}
+
+func waitForPoolSize(t *testing.T, cp CertPool, size int) {
+	waitFor(t, func() bool {
+		return poolSize(t, cp) == size
+	})
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "condition not met in time")
+		}
+		time.Sleep(watchInterval)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mem
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// Scheme URL scheme of the in-memory agent endpoints
+const Scheme = "mem"
+
+// Hub connects the agents of a process without opening sockets, messages sent to mem://<name> by the outbound
+// transport of the hub are delivered to the inbound transport registered under that name
+type Hub struct {
+	inbounds map[string]*Inbound
+	lock     sync.RWMutex
+}
+
+// NewHub creates a new in-memory transport hub
+func NewHub() *Hub {
+	return &Hub{inbounds: make(map[string]*Inbound)}
+}
+
+// Inbound returns a new inbound transport receiving the messages sent to mem://<name> once started
+func (h *Hub) Inbound(name string) *Inbound {
+	return &Inbound{hub: h, name: name}
+}
+
+// Outbound returns the outbound transport delivering messages to the inbound transports of the hub
+func (h *Hub) Outbound() *Outbound {
+	return &Outbound{hub: h}
+}
+
+// Endpoints returns the endpoints of the started inbound transports
+func (h *Hub) Endpoints() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	endpoints := make([]string, 0, len(h.inbounds))
+	for name := range h.inbounds {
+		endpoints = append(endpoints, endpoint(name))
+	}
+	return endpoints
+}
+
+func (h *Hub) register(inbound *Inbound) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.inbounds[inbound.name]; ok {
+		return errors.Errorf("an agent is already registered at %s", endpoint(inbound.name))
+	}
+	h.inbounds[inbound.name] = inbound
+
+	return nil
+}
+
+func (h *Hub) unregister(inbound *Inbound) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.inbounds[inbound.name] == inbound {
+		delete(h.inbounds, inbound.name)
+	}
+}
+
+func (h *Hub) inbound(destination string) (*Inbound, error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid destination %s", destination)
+	}
+	if !strings.EqualFold(u.Scheme, Scheme) {
+		return nil, errors.Errorf("unsupported scheme of destination %s", destination)
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	inbound, ok := h.inbounds[u.Host]
+	if !ok {
+		return nil, errors.Errorf("no agent registered at %s", destination)
+	}
+
+	return inbound, nil
+}
+
+// Outbound is the outbound transport of the hub, messages are delivered synchronously: Send returns once the
+// receiving agent handled the message, along with its response if any. Only envelopes are delivered.
+type Outbound struct {
+	hub *Hub
+}
+
+// Send delivers the data to the agent registered at the destination
+func (o *Outbound) Send(data string, destination string) (string, error) {
+	return o.SendContext(context.Background(), data, destination)
+}
+
+// SendContext delivers the data to the agent registered at the destination unless the context is done
+func (o *Outbound) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	resp, err := o.SendMessage(ctx, transport.NewMessage(transport.MediaTypeEnvelope, []byte(data)), destination)
+	if err != nil || resp == nil {
+		return "", err
+	}
+
+	respData, err := resp.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return string(respData), nil
+}
+
+// SendMessage delivers the message to the agent registered at the destination unless the context is done,
+// the response is nil if the agent didn't send one back
+func (o *Outbound) SendMessage(ctx context.Context, msg *transport.Message,
+	destination string) (*transport.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	inbound, err := o.hub.inbound(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read message to %s", destination)
+	}
+
+	resp, err := inbound.deliver(msg.MediaType, data)
+	if err != nil || len(resp) == 0 {
+		return nil, err
+	}
+	return transport.NewMessage(transport.MediaTypeEnvelope, resp), nil
+}
+
+func endpoint(name string) string {
+	return Scheme + "://" + name
+}
@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mem
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	outbound := hub.Outbound()
+
+	alice := hub.Inbound("alice")
+	require.Equal(t, "mem://alice", alice.Endpoint())
+	var received []string
+	require.NoError(t, alice.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		received = append(received, string(envelope.Message))
+		if string(envelope.Message) == "ping" {
+			return []byte("pong"), nil
+		}
+		return nil, nil
+	}))
+
+	bob := hub.Inbound("bob")
+	require.NoError(t, bob.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		return nil, errors.New("bob failure")
+	}))
+
+	endpoints := hub.Endpoints()
+	sort.Strings(endpoints)
+	require.Equal(t, []string{"mem://alice", "mem://bob"}, endpoints)
+
+	// messages are delivered to the handler of the destination, along with the response
+	resp, err := outbound.Send("hello", "mem://alice")
+	require.NoError(t, err)
+	require.Empty(t, resp)
+	resp, err = outbound.Send("ping", "MEM://alice")
+	require.NoError(t, err)
+	require.Equal(t, "pong", resp)
+	require.Equal(t, []string{"hello", "ping"}, received)
+
+	_, err = outbound.Send("hello", "mem://bob")
+	require.EqualError(t, err, "bob failure")
+
+	// unpack failure
+	_, err = outbound.Send("invalid", "mem://alice")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to unpack envelope sent to mem://alice")
+
+	for _, destination := range []string{"mem://carol", "http://alice", "://alice"} {
+		_, err = outbound.Send("hello", destination)
+		require.Error(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = outbound.SendContext(ctx, "hello", "mem://alice")
+	require.Equal(t, context.Canceled, err)
+
+	// endpoints can be registered once
+	require.Error(t, alice.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+	other := hub.Inbound("alice")
+	err = other.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil })
+	require.EqualError(t, err, "an agent is already registered at mem://alice")
+	require.Error(t, other.Start(nil, nil))
+
+	// stopped agents no longer receive messages
+	require.NoError(t, alice.Stop(context.Background()))
+	require.Error(t, alice.Stop(context.Background()))
+	_, err = outbound.Send("hello", "mem://alice")
+	require.EqualError(t, err, "no agent registered at mem://alice")
+	require.Equal(t, []string{"mem://bob"}, hub.Endpoints())
+
+	// the endpoint can be registered again
+	require.NoError(t, other.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+	_, err = outbound.Send("hello", "mem://alice")
+	require.NoError(t, err)
+}
+
+func TestOutbound_SendMessage(t *testing.T) {
+	hub := NewHub()
+	outbound := hub.Outbound()
+
+	var mediaTypes []string
+	alice := hub.Inbound("alice")
+	require.NoError(t, alice.Start(&mockPacker{}, func(mediaType string, envelope *pack.Envelope) ([]byte, error) {
+		mediaTypes = append(mediaTypes, mediaType)
+		if string(envelope.Message) == "ping" {
+			return []byte("pong"), nil
+		}
+		return nil, nil
+	}))
+
+	resp, err := outbound.SendMessage(context.Background(),
+		transport.NewMessage(transport.MediaTypeEnvelope, []byte("ping")), alice.Endpoint())
+	require.NoError(t, err)
+	require.Equal(t, transport.MediaTypeEnvelope, resp.MediaType)
+	data, err := resp.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(data))
+
+	// the media type defaults to envelopes
+	resp, err = outbound.SendMessage(context.Background(), &transport.Message{}, alice.Endpoint())
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Equal(t, []string{transport.MediaTypeEnvelope, transport.MediaTypeEnvelope}, mediaTypes)
+
+	// only envelopes are delivered
+	_, err = outbound.SendMessage(context.Background(),
+		transport.NewMessage(transport.MediaTypeJSON, []byte("{}")), alice.Endpoint())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported media type")
+	require.Len(t, mediaTypes, 2)
+}
+
+func TestHub_NestedDelivery(t *testing.T) {
+	hub := NewHub()
+	outbound := hub.Outbound()
+
+	received := make(chan string, 1)
+	alice := hub.Inbound("alice")
+	require.NoError(t, alice.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		received <- string(envelope.Message)
+		return nil, nil
+	}))
+
+	// bob replies to alice while handling her message
+	bob := hub.Inbound("bob")
+	require.NoError(t, bob.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		_, err := outbound.Send("reply to "+string(envelope.Message), alice.Endpoint())
+		return nil, err
+	}))
+
+	_, err := outbound.Send("hello", bob.Endpoint())
+	require.NoError(t, err)
+	require.Equal(t, "reply to hello", <-received)
+}
+
+func TestInbound_StopWaitsForDeliveries(t *testing.T) {
+	hub := NewHub()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	alice := hub.Inbound("alice")
+	require.NoError(t, alice.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}))
+
+	sent := make(chan error)
+	go func() {
+		_, err := hub.Outbound().Send("hello", alice.Endpoint())
+		sent <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, alice.Stop(ctx))
+
+	close(release)
+	require.NoError(t, <-sent)
+}
+
+// the hub transports implement the transport interfaces
+var _ transport.InboundTransport = (*Inbound)(nil)
+var _ transport.MessageOutboundTransport = (*Outbound)(nil)
+
+type mockPacker struct{}
+
+func (m *mockPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	return payload, nil
+}
+
+func (m *mockPacker) Unpack(envelope []byte) (*pack.Envelope, error) {
+	if string(envelope) == "invalid" {
+		return nil, errors.New("invalid envelope")
+	}
+	return &pack.Envelope{Message: envelope}, nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mem
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// Inbound is the inbound transport of an agent registered at a mem://<name> endpoint of the hub
+type Inbound struct {
+	hub     *Hub
+	name    string
+	packer  pack.Packer
+	handler transport.InboundMessageHandler
+	wg      sync.WaitGroup
+	lock    sync.RWMutex
+}
+
+// Start registers the agent at its endpoint, the messages delivered to it are unpacked with the packer
+// then passed to the handler
+func (i *Inbound) Start(packer pack.Packer, handler transport.InboundMessageHandler) error {
+	if packer == nil || handler == nil {
+		return errors.New("packer and handler are mandatory")
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.handler != nil {
+		return errors.New("inbound transport already started")
+	}
+	if err := i.hub.register(i); err != nil {
+		return err
+	}
+	i.packer = packer
+	i.handler = handler
+
+	return nil
+}
+
+// Stop unregisters the agent from its endpoint, waiting for the messages in progress until the context is done
+func (i *Inbound) Stop(ctx context.Context) error {
+	i.lock.Lock()
+	if i.handler == nil {
+		i.lock.Unlock()
+		return errors.New("inbound memory transport not started")
+	}
+	i.hub.unregister(i)
+	i.handler = nil
+	i.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		i.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Endpoint returns the mem://<name> endpoint of the agent
+func (i *Inbound) Endpoint() string {
+	return endpoint(i.name)
+}
+
+// deliver unpacks the envelope and passes it to the handler along with its media type, returning its response
+func (i *Inbound) deliver(mediaType string, data []byte) ([]byte, error) {
+	if !transport.IsEnvelope(mediaType) {
+		return nil, errors.Errorf("unsupported media type %s of message sent to %s", mediaType, i.Endpoint())
+	}
+	if mediaType == "" {
+		mediaType = transport.MediaTypeEnvelope
+	}
+
+	i.lock.RLock()
+	packer, handler := i.packer, i.handler
+	if handler != nil {
+		i.wg.Add(1)
+	}
+	i.lock.RUnlock()
+
+	if handler == nil {
+		return nil, errors.Errorf("no agent registered at %s", i.Endpoint())
+	}
+	defer i.wg.Done()
+
+	envelope, err := packer.Unpack(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unpack envelope sent to %s", i.Endpoint())
+	}
+
+	return handler(mediaType, envelope)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// MediaTypeEnvelope media type of packed DIDComm envelopes
+	MediaTypeEnvelope = "application/didcomm-envelope-enc"
+	// MediaTypeJSON media type of plain JSON messages
+	MediaTypeJSON = "application/json"
+)
+
+// Message is a payload exchanged through a transport along with its media type
+type Message struct {
+	// MediaType of the body, transports default to MediaTypeEnvelope if empty
+	MediaType string
+	// Body is read once by the transport, it is streamed when the transport supports it
+	Body io.Reader
+}
+
+// NewMessage creates a message holding the given data
+func NewMessage(mediaType string, data []byte) *Message {
+	return &Message{MediaType: mediaType, Body: bytes.NewReader(data)}
+}
+
+// Bytes reads the whole body of the message
+func (m *Message) Bytes() ([]byte, error) {
+	if m.Body == nil {
+		return nil, nil
+	}
+
+	return ioutil.ReadAll(m.Body)
+}
+
+// MessageOutboundTransport is an outbound transport sending messages of any media type,
+// bodies are read as they are sent so that large payloads aren't buffered
+type MessageOutboundTransport interface {
+	OutboundTransport
+
+	// SendMessage sends the message, the response is nil if the destination didn't send one back
+	SendMessage(ctx context.Context, msg *Message, destination string) (*Message, error)
+}
+
+// SendEnvelope sends the packed envelope through the transport with the envelope media type,
+// the response envelope is nil if the destination didn't send one back
+func SendEnvelope(ctx context.Context, ot OutboundTransport, envelope []byte, destination string) ([]byte, error) {
+	resp, err := AdaptMessage(ot).SendMessage(ctx, NewMessage(MediaTypeEnvelope, envelope), destination)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+
+	return resp.Bytes()
+}
+
+// IsEnvelope tells whether the media type is the one of packed envelopes, transports default to it if empty
+func IsEnvelope(mediaType string) bool {
+	return mediaType == "" || mediaType == MediaTypeEnvelope
+}
+
+// MessageHandler handles inbound messages, the body is read from the transport and is only valid during the call
+type MessageHandler func(msg *Message) error
+
+// AdaptMessage returns the transport if it supports messages, or an adapter for transports which don't.
+// The adapter reads the whole body before sending it as a string, the media type of the response is assumed
+// to be the one of the message sent.
+func AdaptMessage(ot OutboundTransport) MessageOutboundTransport {
+	if mot, ok := ot.(MessageOutboundTransport); ok {
+		return mot
+	}
+
+	return &messageAdapter{ContextOutboundTransport: AdaptContext(ot)}
+}
+
+type messageAdapter struct {
+	ContextOutboundTransport
+}
+
+func (a *messageAdapter) SendMessage(ctx context.Context, msg *Message, destination string) (*Message, error) {
+	var data strings.Builder
+	if msg.Body != nil {
+		if _, err := io.Copy(&data, msg.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := a.SendContext(ctx, data.String(), destination)
+	if err != nil || resp == "" {
+		return nil, err
+	}
+
+	return &Message{MediaType: msg.MediaType, Body: strings.NewReader(resp)}, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Bytes(t *testing.T) {
+	data, err := NewMessage(MediaTypeJSON, []byte(`{"@id":"1"}`)).Bytes()
+	require.NoError(t, err)
+	require.Equal(t, `{"@id":"1"}`, string(data))
+
+	data, err = (&Message{}).Bytes()
+	require.NoError(t, err)
+	require.Empty(t, data)
+}
+
+func TestAdaptMessage(t *testing.T) {
+	t.Run("passes through message transports", func(t *testing.T) {
+		mux := NewOutboundMux()
+		require.Equal(t, mux, AdaptMessage(mux))
+	})
+
+	t.Run("sends the body as a string", func(t *testing.T) {
+		rt := &recordingTransport{response: "response"}
+
+		resp, err := AdaptMessage(rt).SendMessage(context.Background(),
+			&Message{MediaType: MediaTypeJSON, Body: strings.NewReader("data")}, "http://example.com")
+		require.NoError(t, err)
+		require.Equal(t, "data", rt.data)
+		require.Equal(t, MediaTypeJSON, resp.MediaType)
+
+		data, err := resp.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, "response", string(data))
+	})
+
+	t.Run("no response", func(t *testing.T) {
+		rt := &recordingTransport{}
+
+		resp, err := AdaptMessage(rt).SendMessage(context.Background(), &Message{}, "http://example.com")
+		require.NoError(t, err)
+		require.Nil(t, resp)
+		require.Empty(t, rt.data)
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		ft := &failingTransport{err: errors.New("send failed")}
+
+		_, err := AdaptMessage(ft).SendMessage(context.Background(),
+			NewMessage(MediaTypeEnvelope, []byte("data")), "http://example.com")
+		require.EqualError(t, err, "send failed")
+	})
+
+	t.Run("body read error", func(t *testing.T) {
+		ft := &failingTransport{}
+
+		_, err := AdaptMessage(ft).SendMessage(context.Background(),
+			&Message{Body: &failingReader{}}, "http://example.com")
+		require.EqualError(t, err, "read failed")
+		require.Zero(t, ft.calls)
+	})
+}
+
+func TestSendEnvelope(t *testing.T) {
+	rt := &recordingTransport{response: "response"}
+
+	resp, err := SendEnvelope(context.Background(), rt, []byte("envelope"), "http://example.com")
+	require.NoError(t, err)
+	require.Equal(t, "response", string(resp))
+	require.Equal(t, "envelope", rt.data)
+
+	rt.response = ""
+	resp, err = SendEnvelope(context.Background(), rt, []byte("envelope"), "http://example.com")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+
+	_, err = SendEnvelope(context.Background(), &failingTransport{err: errors.New("send failed")},
+		[]byte("envelope"), "http://example.com")
+	require.EqualError(t, err, "send failed")
+
+	require.True(t, IsEnvelope(MediaTypeEnvelope))
+	require.True(t, IsEnvelope(""))
+	require.False(t, IsEnvelope(MediaTypeJSON))
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
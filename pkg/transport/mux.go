@@ -0,0 +1,154 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// NoTransportForSchemeError is returned when no outbound transport is registered
+// for the URL scheme of the destination
+type NoTransportForSchemeError struct {
+	Scheme      string
+	Destination string
+}
+
+// Error returns the scheme without transport
+func (e *NoTransportForSchemeError) Error() string {
+	return fmt.Sprintf("no outbound transport for scheme %q of destination %s", e.Scheme, e.Destination)
+}
+
+// OutboundMux is an outbound transport sending messages with the transport registered
+// for the URL scheme of their destination, such as http, https, ws or wss
+type OutboundMux struct {
+	transports map[string]OutboundTransport
+	lock       sync.RWMutex
+}
+
+// MuxOpt configures the outbound transport multiplexer
+type MuxOpt func(opts *OutboundMux)
+
+// WithSchemeTransport registers the outbound transport for the given URL schemes
+func WithSchemeTransport(ot OutboundTransport, schemes ...string) MuxOpt {
+	return func(opts *OutboundMux) {
+		for _, scheme := range schemes {
+			opts.transports[strings.ToLower(scheme)] = ot
+		}
+	}
+}
+
+// NewOutboundMux creates a new outbound transport multiplexer
+func NewOutboundMux(opts ...MuxOpt) *OutboundMux {
+	mux := &OutboundMux{transports: make(map[string]OutboundTransport)}
+	// Apply options
+	for _, opt := range opts {
+		opt(mux)
+	}
+
+	return mux
+}
+
+// Register registers the outbound transport for the given URL schemes, replacing the previous ones
+func (m *OutboundMux) Register(ot OutboundTransport, schemes ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	WithSchemeTransport(ot, schemes...)(m)
+}
+
+// Send sends the data with the transport of the destination scheme,
+// a NoTransportForSchemeError is returned if there is none
+func (m *OutboundMux) Send(data string, destination string) (string, error) {
+	ot, err := m.transport(destination)
+	if err != nil {
+		return "", err
+	}
+
+	return ot.Send(data, destination)
+}
+
+// SendContext sends the data with the transport of the destination scheme, giving up once the context is done
+func (m *OutboundMux) SendContext(ctx context.Context, data string, destination string) (string, error) {
+	ot, err := m.transport(destination)
+	if err != nil {
+		return "", err
+	}
+
+	return AdaptContext(ot).SendContext(ctx, data, destination)
+}
+
+// SendMessage sends the message with the transport of the destination scheme
+func (m *OutboundMux) SendMessage(ctx context.Context, msg *Message, destination string) (*Message, error) {
+	ot, err := m.transport(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	return AdaptMessage(ot).SendMessage(ctx, msg, destination)
+}
+
+// SelectEndpoint returns the first of the endpoints with a transport for its scheme, such as
+// the first service endpoint of a DID document the agent can send messages to
+func (m *OutboundMux) SelectEndpoint(endpoints ...string) (string, error) {
+	if len(endpoints) == 0 {
+		return "", errors.New("no endpoint to select from")
+	}
+
+	var err error
+	for _, endpoint := range endpoints {
+		if _, err = m.transport(endpoint); err == nil {
+			return endpoint, nil
+		}
+	}
+
+	return "", err
+}
+
+// Close closes the registered transports which can be closed
+func (m *OutboundMux) Close() error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var closeErr error
+	closed := make(map[io.Closer]bool)
+	for _, ot := range m.transports {
+		closer, ok := ot.(io.Closer)
+		if !ok || closed[closer] {
+			continue
+		}
+		closed[closer] = true
+		if err := closer.Close(); err != nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+func (m *OutboundMux) transport(destination string) (OutboundTransport, error) {
+	scheme := ""
+	if u, err := url.Parse(destination); err == nil {
+		scheme = strings.ToLower(u.Scheme)
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ot, ok := m.transports[scheme]
+	if !ok || scheme == "" {
+		return nil, &NoTransportForSchemeError{Scheme: scheme, Destination: destination}
+	}
+
+	return ot, nil
+}
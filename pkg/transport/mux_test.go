@@ -0,0 +1,130 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboundMux(t *testing.T) {
+	httpTransport := &recordingTransport{response: "http"}
+	wsTransport := &recordingTransport{response: "ws"}
+
+	mux := NewOutboundMux(WithSchemeTransport(httpTransport, "http", "https"))
+	mux.Register(wsTransport, "WS", "wss")
+
+	for destination, expected := range map[string]*recordingTransport{
+		"http://example.com/agent":  httpTransport,
+		"HTTPS://example.com/agent": httpTransport,
+		"ws://example.com/agent":    wsTransport,
+		"wss://example.com/agent":   wsTransport,
+	} {
+		resp, err := mux.Send("data", destination)
+		require.NoError(t, err)
+		require.Equal(t, expected.response, resp)
+		require.Equal(t, destination, expected.destination)
+	}
+
+	for _, destination := range []string{"mem://agent", "example.com/agent", "://invalid", ""} {
+		_, err := mux.Send("data", destination)
+		require.Error(t, err)
+		noTransportErr, ok := err.(*NoTransportForSchemeError)
+		require.True(t, ok)
+		require.Equal(t, destination, noTransportErr.Destination)
+	}
+	_, err := mux.Send("data", "mem://agent")
+	require.EqualError(t, err, `no outbound transport for scheme "mem" of destination mem://agent`)
+
+	// transports can be replaced
+	memTransport := &recordingTransport{response: "mem"}
+	mux.Register(memTransport, "mem", "ws")
+	resp, err := mux.Send("data", "ws://example.com/agent")
+	require.NoError(t, err)
+	require.Equal(t, "mem", resp)
+}
+
+func TestOutboundMux_SendContext(t *testing.T) {
+	mux := NewOutboundMux(WithSchemeTransport(&recordingTransport{response: "http"}, "http"))
+
+	resp, err := mux.SendContext(context.Background(), "data", "http://example.com/agent")
+	require.NoError(t, err)
+	require.Equal(t, "http", resp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = mux.SendContext(ctx, "data", "http://example.com/agent")
+	require.Equal(t, context.Canceled, err)
+
+	_, err = mux.SendContext(context.Background(), "data", "mem://agent")
+	require.IsType(t, &NoTransportForSchemeError{}, err)
+}
+
+func TestOutboundMux_SendMessage(t *testing.T) {
+	mux := NewOutboundMux(WithSchemeTransport(&recordingTransport{response: "http"}, "http"))
+
+	resp, err := mux.SendMessage(context.Background(), NewMessage(MediaTypeEnvelope, []byte("data")),
+		"http://example.com/agent")
+	require.NoError(t, err)
+	require.Equal(t, MediaTypeEnvelope, resp.MediaType)
+
+	_, err = mux.SendMessage(context.Background(), NewMessage(MediaTypeEnvelope, []byte("data")), "mem://agent")
+	require.IsType(t, &NoTransportForSchemeError{}, err)
+}
+
+func TestOutboundMux_SelectEndpoint(t *testing.T) {
+	mux := NewOutboundMux(WithSchemeTransport(&recordingTransport{}, "ws"))
+
+	endpoint, err := mux.SelectEndpoint("https://example.com/agent", "ws://example.com/agent")
+	require.NoError(t, err)
+	require.Equal(t, "ws://example.com/agent", endpoint)
+
+	_, err = mux.SelectEndpoint("https://example.com/agent")
+	require.Error(t, err)
+	_, ok := err.(*NoTransportForSchemeError)
+	require.True(t, ok)
+
+	_, err = mux.SelectEndpoint()
+	require.Error(t, err)
+}
+
+func TestOutboundMux_Close(t *testing.T) {
+	closer := &closingTransport{}
+	mux := NewOutboundMux(WithSchemeTransport(closer, "ws", "wss"),
+		WithSchemeTransport(&recordingTransport{}, "http"))
+	require.NoError(t, mux.Close())
+	require.Equal(t, 1, closer.closed)
+
+	closer.err = errors.New("close error")
+	require.Error(t, mux.Close())
+}
+
+type recordingTransport struct {
+	response    string
+	data        string
+	destination string
+}
+
+func (r *recordingTransport) Send(data string, destination string) (string, error) {
+	r.data = data
+	r.destination = destination
+	return r.response, nil
+}
+
+type closingTransport struct {
+	recordingTransport
+	closed int
+	err    error
+}
+
+func (c *closingTransport) Close() error {
+	c.closed++
+	return c.err
+}
@@ -6,6 +6,12 @@ SPDX-License-Identifier: Apache-2.0
 
 package transport
 
+import (
+	"context"
+
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
 // OutboundTransport interface definition for transport layer
 // This is the client side of the agent
 type OutboundTransport interface {
@@ -13,6 +19,22 @@ type OutboundTransport interface {
 	Send(data string, destination string) (string, error)
 }
 
+// InboundMessageHandler handles the messages unpacked by the inbound transport along with the media type they
+// were received with, the returned response is sent back to the sender on the same connection (return route)
+// if not empty
+type InboundMessageHandler func(mediaType string, envelope *pack.Envelope) ([]byte, error)
+
+// InboundTransport interface definition for inbound transports
+// This is the server side of the agent
+type InboundTransport interface {
+	// Start starts receiving envelopes, they are unpacked with the packer then passed to the handler
+	Start(packer pack.Packer, handler InboundMessageHandler) error
+	// Stop stops receiving envelopes, waiting for the ones in progress until the context is done
+	Stop(ctx context.Context) error
+	// Endpoint returns the endpoint other agents send their messages to
+	Endpoint() string
+}
+
 // RequestRouter struct for path and handler function
 type RequestRouter struct {
 	Path        string
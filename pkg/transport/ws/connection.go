@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ws
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// closeTimeout time allowed to send the close message
+const closeTimeout = time.Second
+
+// connection is a websocket carrying envelopes both ways, writes are serialized
+// as websocket connections support one concurrent writer only
+type connection struct {
+	conn      *websocket.Conn
+	writeLock sync.Mutex
+	// idleTimeout the reads fail once nothing was sent or received for this long, never if zero
+	idleTimeout time.Duration
+}
+
+func (c *connection) write(data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if err := c.touch(); err != nil {
+		return err
+	}
+
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// touch pushes back the read deadline of the connection by the idle timeout if set
+func (c *connection) touch() error {
+	if c.idleTimeout == 0 {
+		return nil
+	}
+
+	return c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+}
+
+// shutdown notifies the other side that the connection is going away before closing it
+func (c *connection) shutdown() {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	if err := c.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(closeTimeout)); err != nil {
+		log.Printf("WebSocket Transport - Error sending close message: %v", err)
+	}
+
+	c.close()
+}
+
+func (c *connection) close() {
+	if err := c.conn.Close(); err != nil {
+		log.Printf("WebSocket Transport - Error closing connection: %v", err)
+	}
+}
+
+// readLoop passes the envelopes read from the connection to the handler until the connection fails or is idle,
+// the responses of the handler are sent back on the connection. Envelopes are discarded without handler.
+func (c *connection) readLoop(packer pack.Packer, handler transport.InboundMessageHandler) error {
+	for {
+		if err := c.touch(); err != nil {
+			return err
+		}
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if handler == nil {
+			continue
+		}
+
+		if err := c.handle(packer, handler, data); err != nil {
+			log.Printf("WebSocket Transport - Error handling inbound message: %v", err)
+		}
+	}
+}
+
+func (c *connection) handle(packer pack.Packer, handler transport.InboundMessageHandler, data []byte) error {
+	envelope, err := packer.Unpack(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unpack inbound message")
+	}
+
+	// websocket messages carry no media type, they are envelopes
+	response, err := handler(transport.MediaTypeEnvelope, envelope)
+	if err != nil {
+		return err
+	}
+	if len(response) == 0 {
+		return nil
+	}
+
+	return c.write(response)
+}
+
+// isUnexpectedClose tells whether the other side closed the connection abnormally,
+// reads failing once the connection is closed locally aren't reported
+func isUnexpectedClose(err error) bool {
+	return websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+}
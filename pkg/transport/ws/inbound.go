@@ -0,0 +1,259 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ws
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// defaultInboundPath path of the agent endpoint if not set
+const defaultInboundPath = "/"
+
+// InboundWS is the WebSocket inbound transport, other agents open connections to the agent endpoint and send
+// their envelopes over them. Responses of the message handler are sent back on the same connection.
+type InboundWS struct {
+	addr     string
+	path     string
+	certFile string
+	keyFile  string
+	external string
+	upgrader websocket.Upgrader
+	listener net.Listener
+	server   *http.Server
+	conns    map[*connection]struct{}
+	wg       sync.WaitGroup
+	lock     sync.Mutex
+}
+
+type inboundOpts struct {
+	path     string
+	certFile string
+	keyFile  string
+	external string
+}
+
+// InboundOpt configures the WebSocket inbound transport
+type InboundOpt func(opts *inboundOpts)
+
+// WithInboundPath sets the path of the agent endpoint, "/" by default
+func WithInboundPath(path string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.path = path
+	}
+}
+
+// WithInboundTLS serves the agent endpoint over wss:// with the given certificate and key files
+func WithInboundTLS(certFile, keyFile string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.certFile = certFile
+		opts.keyFile = keyFile
+	}
+}
+
+// WithInboundExternalEndpoint sets the URL other agents reach the agent endpoint at, e.g. behind a proxy,
+// it is returned by Endpoint instead of the listener address. It is mandatory to listen on a wildcard address.
+func WithInboundExternalEndpoint(endpoint string) InboundOpt {
+	return func(opts *inboundOpts) {
+		opts.external = endpoint
+	}
+}
+
+// NewInbound creates a new WebSocket inbound transport listening on the given address once started
+func NewInbound(addr string, opts ...InboundOpt) (*InboundWS, error) {
+	if addr == "" {
+		return nil, errors.New("address is mandatory")
+	}
+
+	inOpts := &inboundOpts{path: defaultInboundPath}
+	// Apply options
+	for _, opt := range opts {
+		opt(inOpts)
+	}
+	if (inOpts.certFile == "") != (inOpts.keyFile == "") {
+		return nil, errors.New("both TLS certificate and key files are mandatory")
+	}
+	if inOpts.external == "" && transport.IsWildcardAddr(addr) {
+		return nil, errors.Errorf("external endpoint is mandatory to listen on wildcard address %s", addr)
+	}
+
+	return &InboundWS{
+		addr:     addr,
+		path:     inOpts.path,
+		certFile: inOpts.certFile,
+		keyFile:  inOpts.keyFile,
+		external: inOpts.external,
+		upgrader: websocket.Upgrader{
+			// agents aren't browsers, requests of any origin are accepted
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}, nil
+}
+
+// Start listens on the address of the transport, the envelopes received on the connections opened by
+// other agents are unpacked with the packer then passed to the handler
+func (i *InboundWS) Start(packer pack.Packer, handler transport.InboundMessageHandler) error {
+	if packer == nil || handler == nil {
+		return errors.New("packer and message handler are mandatory")
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.server != nil {
+		return errors.New("inbound WebSocket transport already started")
+	}
+
+	server := &http.Server{}
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != i.path {
+			http.NotFound(w, r)
+			return
+		}
+		i.serveConnection(server, w, r, packer, handler)
+	})
+	if i.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(i.certFile, i.keyFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load TLS certificate")
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := net.Listen("tcp", i.addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", i.addr)
+	}
+	i.listener = listener
+	i.server = server
+	i.conns = make(map[*connection]struct{})
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+		if err := serve(server, listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("WebSocket Transport - Inbound transport stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// serveConnection upgrades the request to a websocket and reads envelopes from it until it is closed
+func (i *InboundWS) serveConnection(server *http.Server, w http.ResponseWriter, r *http.Request,
+	packer pack.Packer, handler transport.InboundMessageHandler) {
+	wsConn, err := i.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		return
+	}
+	conn := &connection{conn: wsConn}
+
+	i.lock.Lock()
+	// the transport was stopped while upgrading
+	if i.server != server {
+		i.lock.Unlock()
+		conn.close()
+		return
+	}
+	i.conns[conn] = struct{}{}
+	i.wg.Add(1)
+	i.lock.Unlock()
+
+	defer i.wg.Done()
+
+	if err := conn.readLoop(packer, handler); isUnexpectedClose(err) {
+		log.Printf("WebSocket Transport - Inbound connection failed: %v", err)
+	}
+
+	i.lock.Lock()
+	_, open := i.conns[conn]
+	delete(i.conns, conn)
+	i.lock.Unlock()
+
+	if open {
+		conn.close()
+	}
+}
+
+func serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// certificates are already loaded in the TLS config
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
+}
+
+// Stop stops listening, closes the connections opened by other agents and waits for their envelopes
+// in progress until the context is done
+func (i *InboundWS) Stop(ctx context.Context) error {
+	i.lock.Lock()
+	server := i.server
+	conns := i.conns
+	i.server = nil
+	i.listener = nil
+	i.conns = nil
+	i.lock.Unlock()
+
+	if server == nil {
+		return errors.New("inbound WebSocket transport not started")
+	}
+
+	err := server.Shutdown(ctx)
+	// hijacked connections aren't closed by the server
+	for conn := range conns {
+		conn.shutdown()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		i.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "failed to stop inbound WebSocket transport")
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to shutdown inbound WebSocket transport")
+	}
+
+	return nil
+}
+
+// Endpoint returns the URL of the agent endpoint, it is empty until the transport is started
+func (i *InboundWS) Endpoint() string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.listener == nil {
+		return ""
+	}
+	if i.external != "" {
+		return i.external
+	}
+
+	scheme := "ws://"
+	if i.certFile != "" {
+		scheme = "wss://"
+	}
+
+	return scheme + i.listener.Addr().String() + i.path
+}
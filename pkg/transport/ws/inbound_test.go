@@ -0,0 +1,130 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ws
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+)
+
+const certPrefix = "../../../test/fixtures/keys/"
+
+func TestInboundWS(t *testing.T) {
+	inbound, err := NewInbound("localhost:0", WithInboundPath("/agent"))
+	require.NoError(t, err)
+	require.Empty(t, inbound.Endpoint())
+
+	require.NoError(t, inbound.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		if string(envelope.Message) == "no-response" {
+			return nil, nil
+		}
+		return envelope.Message, nil
+	}))
+	require.Error(t, inbound.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+
+	endpoint := inbound.Endpoint()
+	require.True(t, strings.HasPrefix(endpoint, "ws://127.0.0.1:"))
+	require.True(t, strings.HasSuffix(endpoint, "/agent"))
+
+	conn, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
+	require.NoError(t, err)
+
+	// invalid envelopes and empty responses get no reply, the response is sent back on the connection
+	for _, msg := range []string{"invalid", "no-response", "echo"} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+	}
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "echo", string(data))
+
+	// not the agent endpoint
+	_, resp, err := websocket.DefaultDialer.Dial(strings.TrimSuffix(endpoint, "/agent")+"/other", nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// connections are closed on stop
+	require.NoError(t, inbound.Stop(context.Background()))
+	require.Empty(t, inbound.Endpoint())
+	_, _, err = conn.ReadMessage()
+	require.Error(t, err)
+	require.Error(t, inbound.Stop(context.Background()))
+}
+
+func TestInboundWS_TLS(t *testing.T) {
+	inbound, err := NewInbound("localhost:0", WithInboundTLS(certPrefix+"ec-pubCert1.pem", certPrefix+"ec-key1.pem"))
+	require.NoError(t, err)
+	require.NoError(t, inbound.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		return envelope.Message, nil
+	}))
+	defer func() { require.NoError(t, inbound.Stop(context.Background())) }()
+
+	endpoint := inbound.Endpoint()
+	require.True(t, strings.HasPrefix(endpoint, "wss://127.0.0.1:"))
+
+	cert, err := ioutil.ReadFile(certPrefix + "ec-pubCert1.pem")
+	require.NoError(t, err)
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(cert))
+
+	responses := make(chan string, 1)
+	outbound, err := NewOutbound(
+		WithDialer(&websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}),
+		WithInboundMessageHandler(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+			responses <- string(envelope.Message)
+			return nil, nil
+		}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+
+	// the certificate is issued for localhost
+	_, err = outbound.Send("message", strings.Replace(endpoint, "127.0.0.1", "localhost", 1))
+	require.NoError(t, err)
+	require.Equal(t, "message", receive(t, responses))
+}
+
+func TestNewInbound(t *testing.T) {
+	_, err := NewInbound("")
+	require.Error(t, err)
+
+	_, err = NewInbound("localhost:0", WithInboundTLS(certPrefix+"ec-pubCert1.pem", ""))
+	require.Error(t, err)
+
+	handler := func(string, *pack.Envelope) ([]byte, error) { return nil, nil }
+
+	inbound, err := NewInbound("localhost:0", WithInboundTLS("badpath", "badpath"))
+	require.NoError(t, err)
+	require.Error(t, inbound.Start(&mockPacker{}, handler))
+
+	inbound, err = NewInbound("invalid-address")
+	require.NoError(t, err)
+	require.Error(t, inbound.Start(&mockPacker{}, handler))
+	require.Error(t, inbound.Start(nil, handler))
+	require.Error(t, inbound.Start(&mockPacker{}, nil))
+}
+
+func TestInbound_ExternalEndpoint(t *testing.T) {
+	_, err := NewInbound(":0")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "external endpoint is mandatory")
+
+	inbound, err := NewInbound(":0", WithInboundExternalEndpoint("wss://agent.example.com/didcomm"))
+	require.NoError(t, err)
+	require.Empty(t, inbound.Endpoint())
+
+	require.NoError(t, inbound.Start(&mockPacker{}, func(string, *pack.Envelope) ([]byte, error) { return nil, nil }))
+	require.Equal(t, "wss://agent.example.com/didcomm", inbound.Endpoint())
+	require.NoError(t, inbound.Stop(context.Background()))
+}
@@ -0,0 +1,301 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ws
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const (
+	defaultReconnectInterval = time.Second
+	maxReconnectInterval     = time.Minute
+	defaultIdleTimeout       = 5 * time.Minute
+	// maxReconnectAttempts attempts to reopen a failed connection before giving up on the endpoint
+	maxReconnectAttempts = 10
+)
+
+// OutboundWS is the WebSocket outbound transport for ws:// and wss:// endpoints, it keeps one connection open
+// per endpoint and sends all the messages for that endpoint over it. Agents which can't expose an endpoint
+// receive their inbound messages on the connections they opened. Connections nothing was sent or received on
+// for the idle timeout are closed.
+type OutboundWS struct {
+	dialer            *websocket.Dialer
+	packer            pack.Packer
+	handler           transport.InboundMessageHandler
+	reconnectInterval time.Duration
+	reconnectAttempts int
+	idleTimeout       time.Duration
+	conns             map[string]*connection
+	done              chan struct{}
+	wg                sync.WaitGroup
+	lock              sync.Mutex
+	closed            bool
+}
+
+type outboundOpts struct {
+	dialer            *websocket.Dialer
+	packer            pack.Packer
+	handler           transport.InboundMessageHandler
+	reconnectInterval time.Duration
+	idleTimeout       time.Duration
+}
+
+// OutboundOpt configures the WebSocket outbound transport
+type OutboundOpt func(opts *outboundOpts)
+
+// WithDialer sets the dialer opening the connections, such as one with a TLS config for wss:// endpoints
+func WithDialer(dialer *websocket.Dialer) OutboundOpt {
+	return func(opts *outboundOpts) {
+		opts.dialer = dialer
+	}
+}
+
+// WithInboundMessageHandler sets the handler of the messages received on the connections opened
+// by the transport, they are unpacked with the packer first. Connections closed unexpectedly are reopened
+// so that inbound messages keep coming, the transport gives up after 10 attempts.
+// Received messages are discarded if not set.
+func WithInboundMessageHandler(packer pack.Packer, handler transport.InboundMessageHandler) OutboundOpt {
+	return func(opts *outboundOpts) {
+		opts.packer = packer
+		opts.handler = handler
+	}
+}
+
+// WithReconnectInterval sets the delay before reopening a failed connection, it doubles after
+// every failed attempt up to a minute. Defaults to a second.
+func WithReconnectInterval(interval time.Duration) OutboundOpt {
+	return func(opts *outboundOpts) {
+		opts.reconnectInterval = interval
+	}
+}
+
+// WithIdleTimeout sets the time after which connections nothing was sent or received on are closed,
+// they are reopened by the next message sent. Defaults to 5 minutes.
+func WithIdleTimeout(timeout time.Duration) OutboundOpt {
+	return func(opts *outboundOpts) {
+		opts.idleTimeout = timeout
+	}
+}
+
+// NewOutbound creates a new WebSocket outbound transport
+func NewOutbound(opts ...OutboundOpt) (*OutboundWS, error) {
+	outOpts := &outboundOpts{
+		dialer:            websocket.DefaultDialer,
+		reconnectInterval: defaultReconnectInterval,
+		idleTimeout:       defaultIdleTimeout,
+	}
+	// Apply options
+	for _, opt := range opts {
+		opt(outOpts)
+	}
+	if (outOpts.packer == nil) != (outOpts.handler == nil) {
+		return nil, errors.New("both packer and inbound message handler are mandatory")
+	}
+	if outOpts.dialer == nil || outOpts.reconnectInterval <= 0 || outOpts.idleTimeout <= 0 {
+		return nil, errors.New("invalid dialer, reconnect interval or idle timeout")
+	}
+
+	return &OutboundWS{
+		dialer:            outOpts.dialer,
+		packer:            outOpts.packer,
+		handler:           outOpts.handler,
+		reconnectInterval: outOpts.reconnectInterval,
+		reconnectAttempts: maxReconnectAttempts,
+		idleTimeout:       outOpts.idleTimeout,
+		conns:             make(map[string]*connection),
+		done:              make(chan struct{}),
+	}, nil
+}
+
+// Send sends the data over the connection to the endpoint, opening it if needed. The connection is
+// reopened once if the write fails as the other agent may have closed it. Responses arrive as inbound
+// messages so the returned response is always empty.
+func (o *OutboundWS) Send(data string, endpoint string) (string, error) {
+	return o.SendContext(context.Background(), data, endpoint)
+}
+
+// SendContext sends the data as Send does, giving up opening the connection once the context is done
+func (o *OutboundWS) SendContext(ctx context.Context, data string, endpoint string) (string, error) {
+	_, err := o.SendMessage(ctx, transport.NewMessage(transport.MediaTypeEnvelope, []byte(data)), endpoint)
+	return "", err
+}
+
+// SendMessage sends the envelope of the message body as Send does. WebSocket messages carry no media type,
+// the other agent expects envelopes only. The response is always nil as responses arrive as inbound messages.
+func (o *OutboundWS) SendMessage(ctx context.Context, msg *transport.Message,
+	endpoint string) (*transport.Message, error) {
+	if !transport.IsEnvelope(msg.MediaType) {
+		return nil, errors.Errorf("unsupported media type %s, the WebSocket transport sends envelopes only",
+			msg.MediaType)
+	}
+	if err := validateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read message to %s", endpoint)
+	}
+
+	conn, err := o.connection(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = conn.write(data); err != nil {
+		o.drop(endpoint, conn)
+
+		conn, err = o.connection(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if err = conn.write(data); err != nil {
+			o.drop(endpoint, conn)
+			return nil, errors.Wrapf(err, "failed to send message to %s", endpoint)
+		}
+	}
+
+	return nil, nil
+}
+
+// Close closes the connections and waits for their read loops to end, messages can't be sent afterwards
+func (o *OutboundWS) Close() error {
+	o.lock.Lock()
+	if o.closed {
+		o.lock.Unlock()
+		return nil
+	}
+	o.closed = true
+	close(o.done)
+	conns := o.conns
+	o.conns = make(map[string]*connection)
+	o.lock.Unlock()
+
+	for _, conn := range conns {
+		conn.shutdown()
+	}
+	o.wg.Wait()
+
+	return nil
+}
+
+// connection returns the open connection to the endpoint or dials a new one until the context is done
+func (o *OutboundWS) connection(ctx context.Context, endpoint string) (*connection, error) {
+	o.lock.Lock()
+	conn, ok := o.conns[endpoint]
+	closed := o.closed
+	o.lock.Unlock()
+	if closed {
+		return nil, errors.New("WebSocket transport is closed")
+	}
+	if ok {
+		return conn, nil
+	}
+
+	// dial without holding the lock so that other endpoints aren't blocked
+	wsConn, _, err := o.dialer.DialContext(ctx, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", endpoint)
+	}
+	conn = &connection{conn: wsConn, idleTimeout: o.idleTimeout}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.closed {
+		conn.close()
+		return nil, errors.New("WebSocket transport is closed")
+	}
+	// another sender connected in the meantime
+	if existing, ok := o.conns[endpoint]; ok {
+		conn.close()
+		return existing, nil
+	}
+
+	o.conns[endpoint] = conn
+	o.wg.Add(1)
+	go o.read(endpoint, conn)
+
+	return conn, nil
+}
+
+// read handles the messages received on the connection until it fails or is idle, the connection is reopened
+// if it was closed unexpectedly and there is an inbound message handler
+func (o *OutboundWS) read(endpoint string, conn *connection) {
+	defer o.wg.Done()
+
+	err := conn.readLoop(o.packer, o.handler)
+	unexpected := isUnexpectedClose(err)
+	if unexpected {
+		log.Printf("WebSocket Transport - Connection to %s failed: %v", endpoint, err)
+	}
+	o.drop(endpoint, conn)
+
+	if unexpected && o.handler != nil {
+		o.reconnect(endpoint)
+	}
+}
+
+// reconnect reopens the connection to the endpoint with an exponential backoff,
+// giving up once the transport is closed or after the maximum number of attempts
+func (o *OutboundWS) reconnect(endpoint string) {
+	interval := o.reconnectInterval
+	for attempt := 0; attempt < o.reconnectAttempts; attempt++ {
+		select {
+		case <-o.done:
+			return
+		case <-time.After(interval):
+		}
+
+		if _, err := o.connection(context.Background(), endpoint); err == nil {
+			return
+		}
+
+		interval *= 2
+		if interval > maxReconnectInterval {
+			interval = maxReconnectInterval
+		}
+	}
+
+	log.Printf("WebSocket Transport - Gave up reconnecting to %s", endpoint)
+}
+
+// drop forgets the connection and closes it if it is still the one of the endpoint,
+// it was already closed otherwise
+func (o *OutboundWS) drop(endpoint string, conn *connection) {
+	o.lock.Lock()
+	current := o.conns[endpoint] == conn
+	if current {
+		delete(o.conns, endpoint)
+	}
+	o.lock.Unlock()
+
+	if current {
+		conn.close()
+	}
+}
+
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return errors.Wrapf(err, "invalid endpoint %s", endpoint)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return errors.Errorf("unsupported scheme %s, the WebSocket transport sends to ws and wss endpoints", u.Scheme)
+	}
+
+	return nil
+}
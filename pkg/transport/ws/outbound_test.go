@@ -0,0 +1,317 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const receiveTimeout = 5 * time.Second
+
+func TestOutboundWS(t *testing.T) {
+	inbound, received := startInbound(t, "localhost:0")
+	endpoint := inbound.Endpoint()
+
+	responses := make(chan string, 10)
+	outbound, err := NewOutbound(WithInboundMessageHandler(&mockPacker{},
+		func(_ string, envelope *pack.Envelope) ([]byte, error) {
+			responses <- string(envelope.Message)
+			return nil, nil
+		}), WithReconnectInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+
+	// messages share the connection and responses arrive on it
+	for _, msg := range []string{"first", "second"} {
+		respData, err := outbound.Send(msg, endpoint)
+		require.NoError(t, err)
+		require.Empty(t, respData)
+		require.Equal(t, msg, receive(t, received))
+		require.Equal(t, "response:"+msg, receive(t, responses))
+	}
+	inbound.lock.Lock()
+	require.Len(t, inbound.conns, 1)
+	inbound.lock.Unlock()
+
+	// the connection is reopened once it failed unexpectedly
+	failed := openConnection(outbound, endpoint)
+	inbound.lock.Lock()
+	for conn := range inbound.conns {
+		require.NoError(t, conn.conn.UnderlyingConn().Close())
+	}
+	inbound.lock.Unlock()
+	waitFor(t, func() bool {
+		conn := openConnection(outbound, endpoint)
+		return conn != nil && conn != failed
+	})
+
+	_, err = outbound.Send("third", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "third", receive(t, received))
+	require.Equal(t, "response:third", receive(t, responses))
+
+	// but not once the other agent closed it
+	require.NoError(t, inbound.Stop(context.Background()))
+	inbound, received = startInbound(t, hostPort(t, endpoint))
+	defer func() { require.NoError(t, inbound.Stop(context.Background())) }()
+	time.Sleep(100 * time.Millisecond)
+	inbound.lock.Lock()
+	require.Empty(t, inbound.conns)
+	inbound.lock.Unlock()
+
+	_, err = outbound.Send("fourth", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "fourth", receive(t, received))
+}
+
+func TestOutboundWS_ReconnectAttempts(t *testing.T) {
+	// the first connection fails right away, the other ones can't be opened
+	var lock sync.Mutex
+	attempts := 0
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		attempts++
+		first := attempts == 1
+		lock.Unlock()
+		if !first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			_ = conn.UnderlyingConn().Close()
+		}
+	}))
+	defer server.Close()
+	endpoint := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	outbound, err := NewOutbound(WithInboundMessageHandler(&mockPacker{},
+		func(_ string, envelope *pack.Envelope) ([]byte, error) {
+			return nil, nil
+		}), WithReconnectInterval(time.Millisecond))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+	outbound.reconnectAttempts = 3
+
+	_, err = outbound.Send("msg", endpoint)
+	require.NoError(t, err)
+
+	// the transport gives up after the maximum number of attempts
+	waitFor(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return attempts == 4
+	})
+	time.Sleep(100 * time.Millisecond)
+	lock.Lock()
+	require.Equal(t, 4, attempts)
+	lock.Unlock()
+}
+
+func TestOutboundWS_IdleTimeout(t *testing.T) {
+	inbound, received := startInbound(t, "localhost:0")
+	defer func() { require.NoError(t, inbound.Stop(context.Background())) }()
+	endpoint := inbound.Endpoint()
+
+	outbound, err := NewOutbound(WithInboundMessageHandler(&mockPacker{},
+		func(_ string, envelope *pack.Envelope) ([]byte, error) {
+			return nil, nil
+		}), WithReconnectInterval(10*time.Millisecond), WithIdleTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+
+	_, err = outbound.Send("first", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "first", receive(t, received))
+
+	// the idle connection is closed and not reopened
+	waitFor(t, func() bool {
+		return openConnection(outbound, endpoint) == nil
+	})
+	waitFor(t, func() bool {
+		inbound.lock.Lock()
+		defer inbound.lock.Unlock()
+		return len(inbound.conns) == 0
+	})
+	time.Sleep(100 * time.Millisecond)
+	require.Nil(t, openConnection(outbound, endpoint))
+
+	// until the next message
+	_, err = outbound.Send("second", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "second", receive(t, received))
+}
+
+// openConnection returns the open connection of the outbound transport to the endpoint, nil if none
+func openConnection(outbound *OutboundWS, endpoint string) *connection {
+	outbound.lock.Lock()
+	defer outbound.lock.Unlock()
+
+	return outbound.conns[endpoint]
+}
+
+func TestOutboundWS_ReconnectOnSend(t *testing.T) {
+	inbound, received := startInbound(t, "localhost:0")
+	endpoint := inbound.Endpoint()
+
+	// without inbound message handler, connections are only reopened when sending
+	outbound, err := NewOutbound()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+
+	_, err = outbound.Send("first", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "first", receive(t, received))
+
+	require.NoError(t, inbound.Stop(context.Background()))
+	waitFor(t, func() bool {
+		outbound.lock.Lock()
+		defer outbound.lock.Unlock()
+		return len(outbound.conns) == 0
+	})
+
+	_, err = outbound.Send("lost", endpoint)
+	require.Error(t, err)
+
+	inbound, received = startInbound(t, hostPort(t, endpoint))
+	defer func() { require.NoError(t, inbound.Stop(context.Background())) }()
+
+	_, err = outbound.Send("second", endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "second", receive(t, received))
+}
+
+func TestOutboundWS_SendMessage(t *testing.T) {
+	inbound, received := startInbound(t, "localhost:0")
+	defer func() { require.NoError(t, inbound.Stop(context.Background())) }()
+
+	outbound, err := NewOutbound()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, outbound.Close()) }()
+
+	resp, err := outbound.SendMessage(context.Background(),
+		transport.NewMessage(transport.MediaTypeEnvelope, []byte("envelope")), inbound.Endpoint())
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.Equal(t, "envelope", receive(t, received))
+
+	// websocket messages carry no media type, only envelopes are sent
+	_, err = outbound.SendMessage(context.Background(),
+		transport.NewMessage(transport.MediaTypeJSON, []byte("{}")), inbound.Endpoint())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported media type")
+
+	// connections aren't opened once the context is done
+	other, err := NewOutbound()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, other.Close()) }()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = other.SendContext(ctx, "envelope", inbound.Endpoint())
+	require.Error(t, err)
+}
+
+func TestOutboundWS_Errors(t *testing.T) {
+	outbound, err := NewOutbound()
+	require.NoError(t, err)
+
+	_, err = outbound.Send("msg", "http://localhost:8080")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported scheme")
+
+	_, err = outbound.Send("msg", "://invalid")
+	require.Error(t, err)
+
+	_, err = outbound.Send("msg", "ws://localhost:0/unreachable")
+	require.Error(t, err)
+
+	require.NoError(t, outbound.Close())
+	require.NoError(t, outbound.Close())
+	_, err = outbound.Send("msg", "ws://localhost:8080")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "closed")
+
+	_, err = NewOutbound(WithInboundMessageHandler(&mockPacker{}, nil))
+	require.Error(t, err)
+	_, err = NewOutbound(WithDialer(nil))
+	require.Error(t, err)
+	_, err = NewOutbound(WithReconnectInterval(0))
+	require.Error(t, err)
+	_, err = NewOutbound(WithIdleTimeout(0))
+	require.Error(t, err)
+	_, err = NewOutbound(WithDialer(&websocket.Dialer{}))
+	require.NoError(t, err)
+}
+
+// startInbound starts an inbound transport answering "response:<message>" to the messages it receives
+func startInbound(t *testing.T, addr string) (*InboundWS, chan string) {
+	inbound, err := NewInbound(addr)
+	require.NoError(t, err)
+
+	received := make(chan string, 10)
+	require.NoError(t, inbound.Start(&mockPacker{}, func(_ string, envelope *pack.Envelope) ([]byte, error) {
+		received <- string(envelope.Message)
+		return []byte("response:" + string(envelope.Message)), nil
+	}))
+
+	return inbound, received
+}
+
+func hostPort(t *testing.T, endpoint string) string {
+	u, err := url.Parse(endpoint)
+	require.NoError(t, err)
+	return u.Host
+}
+
+func receive(t *testing.T, messages chan string) string {
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(receiveTimeout):
+		require.Fail(t, "message not received")
+		return ""
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(receiveTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			require.Fail(t, "condition not met")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// mockPacker unpacks envelopes to their content, "invalid" envelopes fail
+type mockPacker struct{}
+
+func (m *mockPacker) Pack(payload []byte, senderKey string, recipientKeys []string) ([]byte, error) {
+	return payload, nil
+}
+
+func (m *mockPacker) Unpack(envelope []byte) (*pack.Envelope, error) {
+	if string(envelope) == "invalid" {
+		return nil, errors.New("invalid envelope")
+	}
+	return &pack.Envelope{Message: envelope}, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trustping
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+// ErrTimeout is returned by Ping when the ping response isn't received in time
+var ErrTimeout = errors.New("trust ping timed out")
+
+// Ping pings the other party of the connection and returns the round-trip latency,
+// ErrTimeout is returned if the ping response isn't received within the timeout of the service
+func (s *Service) Ping(connectionID string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.PingContext(ctx, connectionID)
+}
+
+// PingContext pings the other party of the connection and returns the round-trip latency, giving up once the
+// context is done. ErrTimeout is returned if the context deadline is exceeded.
+func (s *Service) PingContext(ctx context.Context, connectionID string) (time.Duration, error) {
+	record, err := s.store.GetConnectionRecord(connectionID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "no connection found for connection id %s", connectionID)
+	}
+	if record.ServiceEndpoint == "" || len(record.RecipientKeys) == 0 {
+		return 0, errors.Errorf("connection %s has no service endpoint to ping", connectionID)
+	}
+
+	start := time.Now()
+	ping := &didexchange.Ping{
+		Type:              trustPing,
+		ID:                uuid.New().String(),
+		ResponseRequested: true,
+		Timing:            &didexchange.Time{OutTime: start.UTC().Format(time.RFC3339Nano)},
+		// the response sent separately is accepted too
+		Transport: &didexchange.Transport{ReturnRoute: didexchange.ReturnRouteAll},
+	}
+	envelope, err := s.pack(ping, record.MyDID, record.EnvelopeFormat, record.RecipientKeys)
+	if err != nil {
+		return 0, err
+	}
+
+	waiter := s.await(ping.ID)
+	defer s.cancel(ping.ID)
+
+	if err := s.send(ctx, envelope, record.ServiceEndpoint); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-waiter:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return 0, timeoutError(ctx, ctx.Err())
+	}
+}
+
+// send sends the ping envelope to the destination and handles the reply received on the return route if any
+func (s *Service) send(ctx context.Context, envelope []byte, destination string) error {
+	reply, err := transport.SendEnvelope(ctx, s.transport, envelope, destination)
+	if err != nil {
+		return timeoutError(ctx, err)
+	}
+	// the response comes back on the return route unless the other party sends it separately
+	if len(reply) == 0 {
+		return nil
+	}
+
+	return s.receiveReply(reply)
+}
+
+// await registers the ping with the given ID as pending, the returned channel receives its response
+func (s *Service) await(pingID string) <-chan *didexchange.PingResponse {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	waiter := make(chan *didexchange.PingResponse, 1)
+	s.pending[pingID] = waiter
+	return waiter
+}
+
+// cancel stops waiting for the response to the ping with the given ID
+func (s *Service) cancel(pingID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pending, pingID)
+}
+
+// receiveReply unpacks the reply received on the return route and handles it as an inbound message
+func (s *Service) receiveReply(reply []byte) error {
+	envelope, err := s.packer.Unpack(reply)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unpack trust ping reply")
+	}
+
+	_, err = s.HandleInboundEnvelope(envelope)
+	return err
+}
+
+// timeoutError returns ErrTimeout if the context deadline is exceeded, the given error otherwise
+func timeoutError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+
+	return err
+}
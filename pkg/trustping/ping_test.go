@@ -0,0 +1,217 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trustping
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	mockagent "github.com/trustbloc/aries-framework-go/pkg/mocks/agent"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/legacy"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+	"github.com/trustbloc/aries-framework-go/pkg/transport/mem"
+)
+
+const (
+	aliceConnection = "alice-connection"
+	bobConnection   = "bob-connection"
+)
+
+// newService creates the trust ping service of the agent
+func newService(t *testing.T, a *mockagent.Agent, ot transport.OutboundTransport, opts ...ServiceOpt) *Service {
+	opts = append([]ServiceOpt{WithPacker(a.Packer), WithDIDProvider(a.DIDProvider)}, opts...)
+	svc, err := NewService(ot, a.Store, opts...)
+	require.NoError(t, err)
+
+	return svc
+}
+
+// asyncTransport passes the replies of the other party to the handler separately instead of returning them
+type asyncTransport struct {
+	transport.OutboundTransport
+	handler func(reply []byte)
+}
+
+func (a *asyncTransport) Send(data string, destination string) (string, error) {
+	reply, err := a.OutboundTransport.Send(data, destination)
+	if err == nil && reply != "" {
+		go a.handler([]byte(reply))
+	}
+	return "", err
+}
+
+func TestService_Ping(t *testing.T) {
+	hub := mem.NewHub()
+	alice := mockagent.New(t)
+	aliceSvc := newService(t, alice, hub.Outbound())
+	defer alice.Serve(t, hub, "alice", aliceSvc.HandleInboundEnvelope)()
+
+	t.Run("return route", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		alice.ConnectTo(t, bobConnection, bob, "mem://bob", "")
+
+		latency, err := bobSvc.Ping(aliceConnection)
+		require.NoError(t, err)
+		require.True(t, latency > 0)
+		require.Empty(t, bobSvc.pending)
+	})
+
+	t.Run("connection made through the DID exchange", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+		defer bob.Serve(t, hub, "bob", bobSvc.HandleInboundEnvelope)()
+		aliceConnID, bobConnID := mockagent.Connect(t, alice, bob, "mem://alice", "mem://bob")
+
+		// both sides of the connection reach the other party, the inviter too
+		_, err := bobSvc.Ping(bobConnID)
+		require.NoError(t, err)
+		_, err = aliceSvc.Ping(aliceConnID)
+		require.NoError(t, err)
+	})
+
+	t.Run("separate response", func(t *testing.T) {
+		async := &asyncTransport{OutboundTransport: hub.Outbound()}
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, async)
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		alice.ConnectTo(t, bobConnection, bob, "mem://bob", "")
+		// the ping times out if the response can't be handled
+		async.handler = func(reply []byte) {
+			if envelope, err := bob.Packer.Unpack(reply); err == nil {
+				_, _ = bobSvc.HandleInboundEnvelope(envelope)
+			}
+		}
+
+		latency, err := bobSvc.Ping(aliceConnection)
+		require.NoError(t, err)
+		require.True(t, latency > 0)
+	})
+
+	t.Run("JWE envelope", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", pack.FormatJWE)
+		alice.ConnectTo(t, bobConnection, bob, "mem://bob", "")
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.NoError(t, err)
+
+		// the packer must support the envelope format of the connection
+		bob = mockagent.New(t)
+		bobSvc = newService(t, bob, hub.Outbound(), WithPacker(legacy.New(nil)))
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", pack.FormatJWE)
+		_, err = bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+	})
+}
+
+func TestService_PingErrors(t *testing.T) {
+	hub := mem.NewHub()
+	alice := mockagent.New(t)
+	defer alice.Serve(t, hub, "alice", newService(t, alice, hub.Outbound()).HandleInboundEnvelope)()
+
+	t.Run("timeout", func(t *testing.T) {
+		// the ping is sent but never responded to
+		ot := mock.NewRecordingTransport()
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, ot, WithTimeout(20*time.Millisecond))
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.Equal(t, ErrTimeout, err)
+		require.Len(t, ot.Sent(mock.ToDestination("mem://alice")), 1)
+		require.Empty(t, bobSvc.pending)
+
+		// the response is requested on the return route
+		sent, err := ot.Last()
+		require.NoError(t, err)
+		unpacked, err := sent.Unpack(alice.Packer)
+		require.NoError(t, err)
+		ping := &didexchange.Ping{}
+		require.NoError(t, unpacked.Decode(ping))
+		require.True(t, ping.ResponseRequested)
+		require.Equal(t, didexchange.ReturnRouteAll, ping.Transport.ReturnRoute)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, mock.NewRecordingTransport())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := bobSvc.PingContext(ctx, aliceConnection)
+		require.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		ot := mock.NewRecordingTransport().FailAny(mock.AnyCall, errors.New("unreachable"))
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, ot)
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.EqualError(t, err, "unreachable")
+	})
+
+	t.Run("invalid reply", func(t *testing.T) {
+		ot := mock.NewRecordingTransport().RespondAny(mock.AnyCall, "not an envelope")
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, ot)
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+	})
+
+	t.Run("no service endpoint", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+		require.NoError(t, bob.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+			ConnectionID: aliceConnection,
+			MyDID:        bob.DID.DID,
+		}))
+
+		_, err := bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+	})
+
+	t.Run("no DID to ping from", func(t *testing.T) {
+		bob := mockagent.New(t)
+		bobSvc := newService(t, bob, hub.Outbound())
+		bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+		record, err := bob.Store.GetConnectionRecord(aliceConnection)
+		require.NoError(t, err)
+
+		record.MyDID = "did:example:unknown"
+		require.NoError(t, bob.Store.SaveConnectionRecord(record))
+		_, err = bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+
+		record.MyDID = ""
+		require.NoError(t, bob.Store.SaveConnectionRecord(record))
+		_, err = bobSvc.Ping(aliceConnection)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,294 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trustping
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	didprovider "github.com/trustbloc/aries-framework-go/pkg/did/core/provider"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	"github.com/trustbloc/aries-framework-go/pkg/pack/packers"
+	"github.com/trustbloc/aries-framework-go/pkg/transport"
+)
+
+const (
+	// ServiceName name of the trust ping protocol service
+	ServiceName = "trust_ping"
+
+	// MsgTypePrefix type prefix of the trust ping protocol messages
+	MsgTypePrefix = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/trust_ping/1.0/"
+
+	// DefaultTimeout is the time Ping waits for the ping response if no timeout is given
+	DefaultTimeout = 10 * time.Second
+
+	trustPing         = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/trust_ping/1.0/ping"
+	trustPingResponse = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/trust_ping/1.0/ping_response"
+)
+
+// Service trust ping protocol service (RFC 0048), pings the other party of a connection and responds to the
+// pings received. Ping responses are authcrypted for the sender of the ping, they are sent back on the return
+// route if the ping requests it (~transport decorator) or to the service endpoint of its connection otherwise.
+type Service struct {
+	transport   transport.OutboundTransport
+	store       *connection.ConnectionStore
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	timeout     time.Duration
+	pending     map[string]chan *didexchange.PingResponse
+	lock        sync.Mutex
+}
+
+// serviceOpts holds the options for the trust ping protocol service
+type serviceOpts struct {
+	packer      pack.Packer
+	didProvider didprovider.Provider
+	timeout     time.Duration
+}
+
+// ServiceOpt is a trust ping protocol service option
+type ServiceOpt func(opts *serviceOpts)
+
+// WithPacker the trust ping messages are packed with the given packer,
+// legacy RFC 0019 and JWE envelopes are supported by default
+func WithPacker(packer pack.Packer) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.packer = packer
+	}
+}
+
+// WithDIDProvider the pings are authcrypted with the verkey of our DID of the connection held by the DID provider
+func WithDIDProvider(didProvider didprovider.Provider) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.didProvider = didProvider
+	}
+}
+
+// WithTimeout Ping gives up waiting for the ping response after the given timeout instead of DefaultTimeout
+func WithTimeout(timeout time.Duration) ServiceOpt {
+	return func(opts *serviceOpts) {
+		opts.timeout = timeout
+	}
+}
+
+type header struct {
+	Type string `json:"@type,omitempty"`
+}
+
+// NewService creates a new trust ping protocol service pinging the connections of the given store
+func NewService(transport transport.OutboundTransport, store *connection.ConnectionStore,
+	opts ...ServiceOpt) (*Service, error) {
+	if transport == nil || store == nil {
+		return nil, errors.New("transport and connection store are mandatory")
+	}
+
+	svcOpts := &serviceOpts{timeout: DefaultTimeout}
+	// Apply options
+	for _, opt := range opts {
+		opt(svcOpts)
+	}
+	if svcOpts.packer == nil {
+		svcOpts.packer = packers.NewDefault(svcOpts.didProvider)
+	}
+
+	return &Service{
+		transport:   transport,
+		store:       store,
+		packer:      svcOpts.packer,
+		didProvider: svcOpts.didProvider,
+		timeout:     svcOpts.timeout,
+		pending:     make(map[string]chan *didexchange.PingResponse),
+	}, nil
+}
+
+// Name returns the name of the trust ping protocol service
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// MsgTypes returns the message types accepted by the trust ping protocol service
+func (s *Service) MsgTypes() []string {
+	return []string{MsgTypePrefix}
+}
+
+// HandleInbound handles the ping or ping response, pings can't be responded to without their envelope
+func (s *Service) HandleInbound(payload []byte) error {
+	_, err := s.HandleInboundEnvelope(&pack.Envelope{Message: payload})
+	return err
+}
+
+// HandleInboundEnvelope returns the ping response packed for the sender of the ping if it requested one,
+// ping responses are passed to the pending Ping of their thread
+func (s *Service) HandleInboundEnvelope(envelope *pack.Envelope) ([]byte, error) {
+	received := time.Now()
+	msgHeader := &header{}
+	if err := json.Unmarshal(envelope.Message, msgHeader); err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Trust Ping Message Error")
+	}
+
+	switch msgHeader.Type {
+	case trustPing:
+		// a response is requested unless stated otherwise
+		ping := &didexchange.Ping{ResponseRequested: true}
+		if err := json.Unmarshal(envelope.Message, ping); err != nil {
+			return nil, errors.Wrapf(err, "Unmarshal Trust Ping Error")
+		}
+		if !ping.ResponseRequested {
+			return nil, nil
+		}
+		return s.respond(ping, envelope, received)
+	case trustPingResponse:
+		response := &didexchange.PingResponse{}
+		if err := json.Unmarshal(envelope.Message, response); err != nil {
+			return nil, errors.Wrapf(err, "Unmarshal Trust Ping Response Error")
+		}
+		return nil, s.receiveResponse(response)
+	default:
+		return nil, errors.Errorf("unrecognized msgType: %s", msgHeader.Type)
+	}
+}
+
+// HandleOutbound packs the ping for the destination and sends it without waiting for a response,
+// it is authcrypted with the verkey of our DID of the connection holding the recipient keys of the destination
+func (s *Service) HandleOutbound(msg interface{}, destination *dispatcher.Destination) error {
+	if destination == nil || destination.ServiceEndpoint == "" {
+		return errors.New("destination service endpoint is mandatory")
+	}
+
+	ping, ok := msg.(*didexchange.Ping)
+	if !ok {
+		return errors.Errorf("unsupported trust ping message %T", msg)
+	}
+	if ping.ID == "" {
+		ping.ID = uuid.New().String()
+	}
+	ping.Type = trustPing
+
+	record, err := s.store.GetConnectionRecordByTheirKey(destination.RecipientKeys...)
+	if err != nil {
+		return errors.Wrapf(err, "no connection found to ping %s", destination.ServiceEndpoint)
+	}
+
+	envelope, err := s.pack(ping, record.MyDID, record.EnvelopeFormat, destination.RecipientKeys)
+	if err != nil {
+		return err
+	}
+
+	_, err = transport.SendEnvelope(context.Background(), s.transport, envelope, destination.ServiceEndpoint)
+	return err
+}
+
+// respond returns the response to the ping authcrypted for its sender if it requested a return route,
+// the response is sent to the service endpoint of the connection of the sender otherwise. Only pings
+// received on completed connections are responded to.
+func (s *Service) respond(ping *didexchange.Ping, envelope *pack.Envelope, received time.Time) ([]byte, error) {
+	if envelope.SenderKey == "" {
+		return nil, errors.New("can't respond to an anonymous trust ping")
+	}
+
+	record, err := s.store.GetConnectionRecordByTheirKey(envelope.SenderKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no connection found to respond to %s", envelope.SenderKey)
+	}
+	if record.State != connection.StateIDCompleted {
+		return nil, errors.Errorf("connection %s to respond to %s isn't completed", record.ConnectionID,
+			envelope.SenderKey)
+	}
+
+	response := &didexchange.PingResponse{
+		Type:   trustPingResponse,
+		ID:     uuid.New().String(),
+		Thread: &didexchange.Thread{ID: ping.ID},
+		Timing: &didexchange.Time{
+			InTime:  received.UTC().Format(time.RFC3339Nano),
+			OutTime: time.Now().UTC().Format(time.RFC3339Nano),
+		},
+	}
+	if !returnRoute(ping.Transport) {
+		return nil, s.sendResponse(response, record)
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Trust Ping Response Error")
+	}
+
+	packed, err := s.packer.Pack(responseJSON, envelope.RecipientKey, []string{envelope.SenderKey})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pack trust ping response")
+	}
+
+	return packed, nil
+}
+
+// sendResponse sends the response to the service endpoint of the connection of the ping sender
+func (s *Service) sendResponse(response *didexchange.PingResponse, record *connection.ConnectionRecord) error {
+	if record.ServiceEndpoint == "" || len(record.RecipientKeys) == 0 {
+		return errors.Errorf("connection %s has no service endpoint to respond to", record.ConnectionID)
+	}
+
+	envelope, err := s.pack(response, record.MyDID, record.EnvelopeFormat, record.RecipientKeys)
+	if err != nil {
+		return err
+	}
+
+	_, err = transport.SendEnvelope(context.Background(), s.transport, envelope, record.ServiceEndpoint)
+	return err
+}
+
+// pack packs the message for the recipient keys in the envelope format of the connection,
+// it is authcrypted with the verkey of our DID of the connection
+func (s *Service) pack(msg interface{}, myDID, format string, recipientKeys []string) ([]byte, error) {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Marshal Trust Ping Message Error")
+	}
+
+	senderKey, err := connection.SenderKey(s.didProvider, myDID)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := pack.PackWithFormat(s.packer, format, msgJSON, senderKey, recipientKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pack trust ping message")
+	}
+
+	return envelope, nil
+}
+
+// returnRoute tells whether the ~transport decorator asks for the response on the return route
+func returnRoute(decorator *didexchange.Transport) bool {
+	return decorator != nil &&
+		(decorator.ReturnRoute == didexchange.ReturnRouteAll || decorator.ReturnRoute == didexchange.ReturnRouteThread)
+}
+
+// receiveResponse passes the response to the Ping waiting for it
+func (s *Service) receiveResponse(response *didexchange.PingResponse) error {
+	if response.Thread == nil || response.Thread.ID == "" {
+		return errors.New("trust ping response thread id is mandatory")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	waiter, ok := s.pending[response.Thread.ID]
+	if !ok {
+		return errors.Errorf("no trust ping pending for thread id %s", response.Thread.ID)
+	}
+	delete(s.pending, response.Thread.ID)
+	waiter <- response
+
+	return nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package trustping
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/aries-framework-go/pkg/connection"
+	"github.com/trustbloc/aries-framework-go/pkg/dispatcher"
+	mock "github.com/trustbloc/aries-framework-go/pkg/mocks"
+	mockagent "github.com/trustbloc/aries-framework-go/pkg/mocks/agent"
+	"github.com/trustbloc/aries-framework-go/pkg/models/didexchange"
+	"github.com/trustbloc/aries-framework-go/pkg/pack"
+	memstore "github.com/trustbloc/aries-framework-go/pkg/storage/mem"
+)
+
+func TestNewService(t *testing.T) {
+	store, err := connection.NewConnectionStore(memstore.NewStore())
+	require.NoError(t, err)
+
+	svc, err := NewService(mock.NewRecordingTransport(), store)
+	require.NoError(t, err)
+	require.Equal(t, ServiceName, svc.Name())
+	require.Equal(t, []string{MsgTypePrefix}, svc.MsgTypes())
+	require.Equal(t, DefaultTimeout, svc.timeout)
+	require.NotNil(t, svc.packer)
+
+	_, err = NewService(nil, store)
+	require.Error(t, err)
+	_, err = NewService(mock.NewRecordingTransport(), nil)
+	require.Error(t, err)
+}
+
+func TestService_HandleInbound(t *testing.T) {
+	aliceTransport := mock.NewRecordingTransport()
+	alice := mockagent.New(t)
+	aliceSvc := newService(t, alice, aliceTransport)
+	bob := mockagent.New(t)
+	aliceKey, bobKey := alice.VerKey(), bob.VerKey()
+
+	t.Run("ping", func(t *testing.T) {
+		ping := &pack.Envelope{
+			Message:      []byte(`{"@type":"` + trustPing + `","@id":"ping-id","~transport":{"return_route":"all"}}`),
+			SenderKey:    bobKey,
+			RecipientKey: aliceKey,
+		}
+
+		// alice only responds on a completed connection to bob
+		_, err := aliceSvc.HandleInboundEnvelope(ping)
+		require.Error(t, err)
+		require.NoError(t, alice.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+			ConnectionID:  "bob-connection",
+			State:         connection.StateIDAbandoned,
+			MyDID:         alice.DID.DID,
+			RecipientKeys: []string{bobKey},
+		}))
+		_, err = aliceSvc.HandleInboundEnvelope(ping)
+		require.Error(t, err)
+
+		alice.ConnectTo(t, "bob-connection", bob, "https://bob.example.com", "")
+		reply, err := aliceSvc.HandleInboundEnvelope(ping)
+		require.NoError(t, err)
+
+		// the response is authcrypted by alice for bob
+		envelope, err := bob.Packer.Unpack(reply)
+		require.NoError(t, err)
+		require.Equal(t, aliceKey, envelope.SenderKey)
+
+		response := &didexchange.PingResponse{}
+		require.NoError(t, json.Unmarshal(envelope.Message, response))
+		require.Equal(t, trustPingResponse, response.Type)
+		require.NotEmpty(t, response.ID)
+		require.Equal(t, "ping-id", response.Thread.ID)
+		require.NotEmpty(t, response.Timing.InTime)
+		require.NotEmpty(t, response.Timing.OutTime)
+	})
+
+	t.Run("ping without return route", func(t *testing.T) {
+		ping := &pack.Envelope{
+			Message:      []byte(`{"@type":"` + trustPing + `","@id":"ping-id","~transport":{"return_route":"none"}}`),
+			SenderKey:    bobKey,
+			RecipientKey: aliceKey,
+		}
+
+		// the response is sent to the service endpoint of the connection to bob
+		reply, err := aliceSvc.HandleInboundEnvelope(ping)
+		require.NoError(t, err)
+		require.Empty(t, reply)
+
+		sent, err := aliceTransport.Last(mock.ToDestination("https://bob.example.com"))
+		require.NoError(t, err)
+		envelope, err := bob.Packer.Unpack([]byte(sent.Data))
+		require.NoError(t, err)
+		require.Equal(t, aliceKey, envelope.SenderKey)
+		response := &didexchange.PingResponse{}
+		require.NoError(t, json.Unmarshal(envelope.Message, response))
+		require.Equal(t, "ping-id", response.Thread.ID)
+
+		// pings without ~transport decorator don't request a return route either
+		ping.Message = []byte(`{"@type":"` + trustPing + `","@id":"ping-id-2"}`)
+		reply, err = aliceSvc.HandleInboundEnvelope(ping)
+		require.NoError(t, err)
+		require.Empty(t, reply)
+		require.Len(t, aliceTransport.Sent(), 2)
+
+		// nor does a connection without service endpoint
+		require.NoError(t, alice.Store.SaveConnectionRecord(&connection.ConnectionRecord{
+			ConnectionID:  "bob-connection",
+			State:         connection.StateIDCompleted,
+			MyDID:         alice.DID.DID,
+			RecipientKeys: []string{bobKey},
+		}))
+		_, err = aliceSvc.HandleInboundEnvelope(ping)
+		require.Error(t, err)
+	})
+
+	t.Run("ping without response requested", func(t *testing.T) {
+		reply, err := aliceSvc.HandleInboundEnvelope(&pack.Envelope{
+			Message:   []byte(`{"@type":"` + trustPing + `","@id":"ping-id","response_requested":false}`),
+			SenderKey: bobKey,
+		})
+		require.NoError(t, err)
+		require.Empty(t, reply)
+	})
+
+	t.Run("anonymous ping", func(t *testing.T) {
+		require.Error(t, aliceSvc.HandleInbound([]byte(`{"@type":"`+trustPing+`","@id":"ping-id"}`)))
+		require.NoError(t, aliceSvc.HandleInbound(
+			[]byte(`{"@type":"`+trustPing+`","@id":"ping-id","response_requested":false}`)))
+	})
+
+	t.Run("ping response", func(t *testing.T) {
+		waiter := aliceSvc.await("ping-id")
+		require.NoError(t, aliceSvc.HandleInbound(
+			[]byte(`{"@type":"`+trustPingResponse+`","~thread":{"@thid":"ping-id"}}`)))
+		require.Equal(t, "ping-id", (<-waiter).Thread.ID)
+
+		// no ping pending anymore
+		require.Error(t, aliceSvc.HandleInbound(
+			[]byte(`{"@type":"`+trustPingResponse+`","~thread":{"@thid":"ping-id"}}`)))
+		require.Error(t, aliceSvc.HandleInbound([]byte(`{"@type":"`+trustPingResponse+`"}`)))
+	})
+
+	t.Run("invalid messages", func(t *testing.T) {
+		require.Error(t, aliceSvc.HandleInbound([]byte("not json")))
+		require.Error(t, aliceSvc.HandleInbound([]byte(`{"@type":"`+MsgTypePrefix+`unknown"}`)))
+		require.Error(t, aliceSvc.HandleInbound([]byte(`{"@type":"`+trustPing+`","response_requested":"yes"}`)))
+		require.Error(t, aliceSvc.HandleInbound([]byte(`{"@type":"`+trustPingResponse+`","~thread":"ping-id"}`)))
+	})
+}
+
+func TestService_HandleOutbound(t *testing.T) {
+	ot := mock.NewRecordingTransport()
+	alice := mockagent.New(t)
+	bob := mockagent.New(t)
+	bobSvc := newService(t, bob, ot)
+	destination := &dispatcher.Destination{
+		ServiceEndpoint: "https://alice.example.com",
+		RecipientKeys:   []string{alice.VerKey()},
+	}
+
+	// bob has no connection to alice to ping from
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Ping{}, destination))
+
+	bob.ConnectTo(t, aliceConnection, alice, "mem://alice", "")
+	require.NoError(t, bobSvc.HandleOutbound(&didexchange.Ping{Comment: "hi"}, destination))
+
+	sent, err := ot.Last(mock.ToDestination(destination.ServiceEndpoint))
+	require.NoError(t, err)
+	envelope, err := alice.Packer.Unpack([]byte(sent.Data))
+	require.NoError(t, err)
+	require.Equal(t, bob.VerKey(), envelope.SenderKey)
+	unpacked, err := sent.Unpack(alice.Packer)
+	require.NoError(t, err)
+	require.Equal(t, trustPing, unpacked.Type())
+
+	ping := &didexchange.Ping{}
+	require.NoError(t, unpacked.Decode(ping))
+	require.NotEmpty(t, ping.ID)
+	require.Equal(t, "hi", ping.Comment)
+	require.False(t, ping.ResponseRequested)
+
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Request{}, destination))
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Ping{}, nil))
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Ping{}, &dispatcher.Destination{}))
+	require.Error(t, bobSvc.HandleOutbound(&didexchange.Ping{},
+		&dispatcher.Destination{ServiceEndpoint: destination.ServiceEndpoint}))
+}